@@ -16,11 +16,26 @@ import (
 )
 
 type AddOrder struct {
-	Pair              orderbook.Pair `json:"pair"`
-	Quantity          uint64         `json:"quantity"`
-	Side              bool           `json:"side"`
-	Price             uint64         `json:"price"`
-	BlockExpiryWindow uint64         `json:"blockExpiryWindow"`
+	Pair              orderbook.Pair        `json:"pair"`
+	Quantity          uint64                `json:"quantity"`
+	Side              bool                  `json:"side"`
+	Price             uint64                `json:"price"`
+	BlockExpiryWindow uint64                `json:"blockExpiryWindow"`
+	TimeInForce       orderbook.TimeInForce `json:"timeInForce"`
+	SelfTradePrevention orderbook.SelfTradePrevention `json:"selfTradePrevention"`
+	TriggerPrice      uint64                `json:"triggerPrice"`
+	DisplayQuantity   uint64                `json:"displayQuantity"`
+	TimeExpiry        int64                 `json:"timeExpiry"`
+
+	// A market order fills up to either its worst price or its max slippage
+	// in basis points from the mid price, or its trigger price if it is a
+	// stop. Neither allows the default max slippage.
+	WorstPrice  uint64 `json:"worstPrice"`
+	MaxSlippage uint64 `json:"maxSlippage"`
+
+	// A market buy can instead spend a quote amount, buying whatever quantity
+	// it fills for.
+	QuoteAmount uint64 `json:"quoteAmount"`
 }
 
 func (ao *AddOrder) MaxUnits(r chain.Rules) uint64 {
@@ -32,23 +47,46 @@ func (ao *AddOrder) ValidRange(r chain.Rules) (start int64, end int64) {
 }
 
 func (ao *AddOrder) amount(obm *orderbook.OrderbookManager, blockHeight uint64) (uint64, ids.ID) {
+	if ao.QuoteAmount > 0 {
+		return ao.QuoteAmount, ao.Pair.QuoteTokenID
+	}
 	isFilled := false
 	getAmount := orderbook.GetAmountFn(ao.Side, isFilled, ao.Pair)
 	price := ao.Price
 	if price == 0 {
-		mid := obm.GetOrderbook(ao.Pair).GetMidPriceBlk(blockHeight)
-		// 10% max slippage
-		price = mid + mid/10
+		price = ao.MarketPrice(obm, blockHeight)
 	}
 	amt, tokenID := getAmount(ao.Quantity, price)
 	return amt, tokenID
 }
 
+// MarketPrice is the worst price a market order fills at, which it is
+// collateralised for.
+func (ao *AddOrder) MarketPrice(obm *orderbook.OrderbookManager, blockHeight uint64) uint64 {
+	if ao.WorstPrice > 0 {
+		return ao.WorstPrice
+	}
+	// A stop market order executes around its trigger price rather than
+	// the current mid price.
+	ref := ao.TriggerPrice
+	if ref == 0 {
+		ref = obm.ViewOrderbook(ao.Pair).GetMidPriceBlk(blockHeight)
+	}
+	maxSlippage := ao.MaxSlippage
+	if maxSlippage == 0 {
+		maxSlippage = consts.DefaultMaxSlippage
+	}
+	return orderbook.SlippagePrice(ao.Side, ref, maxSlippage)
+}
+
 func (ao *AddOrder) StateKeys(auth chain.Auth, txID ids.ID) [][]byte {
 	user := auth.PublicKey()
 	return [][]byte{
 		storage.BalanceKey(user, ao.Pair.BaseTokenID),
 		storage.BalanceKey(user, ao.Pair.QuoteTokenID),
+		storage.SettledKey(user, ao.Pair.BaseTokenID),
+		storage.SettledKey(user, ao.Pair.QuoteTokenID),
+		storage.PairKey(ao.Pair),
 	}
 }
 
@@ -59,7 +97,7 @@ func (ao *AddOrder) Fee(timestamp int64, blockHeight uint64, auth chain.Auth, me
 	}
 	user := auth.PublicKey()
 	amt, _ := ao.amount(obm, blockHeight)
-	return obm.GetOrderbook(ao.Pair).GetFee(user, timestamp, amt)
+	return obm.ViewOrderbook(ao.Pair).GetFee(user, ao.Side, timestamp, amt)
 }
 
 func (ao *AddOrder) Token(memoryState any) (tokenID ids.ID) {
@@ -86,17 +124,72 @@ func (ao *AddOrder) Execute(
 	user := auth.PublicKey()
 	var baseBalance uint64
 	var quoteBalance uint64
-	if ao.Quantity == 0 {
+	if ao.Quantity == 0 && ao.QuoteAmount == 0 {
 		err = errors.New("amount cannot be zero")
 		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
 	}
+	if ao.Quantity > 0 && ao.QuoteAmount > 0 {
+		err = errors.New("set either a quantity or a quote amount")
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if ao.QuoteAmount > 0 && (!ao.Side || ao.Price > 0 || ao.TriggerPrice > 0) {
+		err = errors.New("quote amount requires a market buy")
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if !ao.TimeInForce.Valid() {
+		err = errors.New("invalid time in force")
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if !ao.SelfTradePrevention.Valid() {
+		err = errors.New("invalid self-trade prevention")
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if ao.DisplayQuantity > 0 && (ao.Price == 0 || utils.BalanceToQuantity(ao.DisplayQuantity) == 0 || ao.DisplayQuantity > ao.Quantity) {
+		err = errors.New("invalid display quantity")
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if ao.Price == 0 && ao.TimeInForce != orderbook.GoodTillCancel {
+		err = errors.New("time in force requires a limit price")
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if ao.Price > 0 && (ao.WorstPrice > 0 || ao.MaxSlippage > 0) {
+		err = errors.New("worst price and max slippage require a market order")
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if ao.WorstPrice > 0 && ao.MaxSlippage > 0 {
+		err = errors.New("set either a worst price or a max slippage")
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if ao.MaxSlippage >= consts.BasisPoints {
+		err = errors.New("max slippage must be below 100%")
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if err = checkExpiry(r, timestamp, ao.BlockExpiryWindow, ao.TimeExpiry); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	var info *orderbook.PairInfo
+	if info, err = getListing(ctx, db, ao.Pair); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if err = checkListing(info, ao.Pair, ao.Price, ao.Quantity); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if ao.QuoteAmount > 0 && ao.QuoteAmount < info.MinNotional {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(ErrBelowMinNotional)}, nil
+	}
+	if ao.TriggerPrice%info.TickSize != 0 || ao.WorstPrice%info.TickSize != 0 {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(ErrOffTick)}, nil
+	}
+	if ao.DisplayQuantity%info.LotSize != 0 {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(ErrOffLot)}, nil
+	}
 	if baseBalance, err = storage.PullPendingBalance(ctx, db, obm, user, ao.Pair.BaseTokenID, blockHeight); err != nil {
 		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
 	}
 	if quoteBalance, err = storage.PullPendingBalance(ctx, db, obm, user, ao.Pair.QuoteTokenID, blockHeight); err != nil {
 		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
 	}
-	if ao.Price == 0 && obm.GetOrderbook(ao.Pair).GetMidPriceBlk(blockHeight) == 0 {
+	if ao.Price == 0 && ao.TriggerPrice == 0 && ao.WorstPrice == 0 && obm.ViewOrderbook(ao.Pair).GetMidPriceBlk(blockHeight) == 0 {
 		err = errors.New("mid-price cannot be zero")
 		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
 	}
@@ -121,16 +214,32 @@ func (ao *AddOrder) Marshal(p *codec.Packer) {
 	p.PackBool(ao.Side)
 	p.PackUint64(ao.Price)
 	p.PackUint64(ao.BlockExpiryWindow)
+	p.PackByte(byte(ao.TimeInForce))
+	p.PackByte(byte(ao.SelfTradePrevention))
+	p.PackUint64(ao.TriggerPrice)
+	p.PackUint64(ao.DisplayQuantity)
+	p.PackInt64(ao.TimeExpiry)
+	p.PackUint64(ao.WorstPrice)
+	p.PackUint64(ao.MaxSlippage)
+	p.PackUint64(ao.QuoteAmount)
 }
 
 func UnmarshalAddOrder(p *codec.Packer, _ *warp.Message) (chain.Action, error) {
 	var ao AddOrder
 	p.UnpackID(true, &ao.Pair.BaseTokenID)
 	p.UnpackID(true, &ao.Pair.QuoteTokenID)
-	ao.Quantity = p.UnpackUint64(true)
+	ao.Quantity = p.UnpackUint64(false)
 	ao.Side = p.UnpackBool()
 	ao.Price = p.UnpackUint64(false)
 	ao.BlockExpiryWindow = p.UnpackUint64(false)
+	ao.TimeInForce = orderbook.TimeInForce(p.UnpackByte())
+	ao.SelfTradePrevention = orderbook.SelfTradePrevention(p.UnpackByte())
+	ao.TriggerPrice = p.UnpackUint64(false)
+	ao.DisplayQuantity = p.UnpackUint64(false)
+	ao.TimeExpiry = p.UnpackInt64(false)
+	ao.WorstPrice = p.UnpackUint64(false)
+	ao.MaxSlippage = p.UnpackUint64(false)
+	ao.QuoteAmount = p.UnpackUint64(false)
 	if ao.BlockExpiryWindow == 0 {
 		ao.BlockExpiryWindow = consts.EvictionBlockWindow
 	}
@@ -0,0 +1,197 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
+	"github.com/jaimi-io/clobvm/consts"
+	"github.com/jaimi-io/clobvm/orderbook"
+	"github.com/jaimi-io/clobvm/storage"
+	"github.com/jaimi-io/clobvm/utils"
+	"github.com/jaimi-io/hypersdk/chain"
+	"github.com/jaimi-io/hypersdk/codec"
+	hutils "github.com/jaimi-io/hypersdk/utils"
+)
+
+var ErrTooManyBatchOrders = fmt.Errorf("batch cannot hold more than %d orders", consts.MaxBatchOrders)
+
+// BatchAdd is a limit order added by a BatchOrders action.
+type BatchAdd struct {
+	Quantity    uint64                `json:"quantity"`
+	Side        bool                  `json:"side"`
+	Price       uint64                `json:"price"`
+	TimeInForce orderbook.TimeInForce `json:"timeInForce"`
+}
+
+// BatchOrders cancels and adds limit orders on one pair in a single tx. When
+// accepted, the cancels are applied first and then the adds, each in the
+// order given.
+type BatchOrders struct {
+	Pair              orderbook.Pair `json:"pair"`
+	Cancels           []ids.ID       `json:"cancels"`
+	Adds              []BatchAdd     `json:"adds"`
+	BlockExpiryWindow uint64         `json:"blockExpiryWindow"`
+	TimeExpiry        int64          `json:"timeExpiry"`
+}
+
+// BatchOrderID returns the ID of the [i]th order added by the BatchOrders tx
+// [txID].
+func BatchOrderID(txID ids.ID, i int) ids.ID {
+	return txID.Prefix(uint64(i))
+}
+
+func (bo *BatchOrders) MaxUnits(r chain.Rules) uint64 {
+	return uint64(len(bo.Cancels) + len(bo.Adds))
+}
+
+func (bo *BatchOrders) ValidRange(r chain.Rules) (start int64, end int64) {
+	return -1, -1
+}
+
+func (bo *BatchOrders) StateKeys(auth chain.Auth, _ ids.ID) [][]byte {
+	user := auth.PublicKey()
+	return [][]byte{
+		storage.BalanceKey(user, bo.Pair.BaseTokenID),
+		storage.BalanceKey(user, bo.Pair.QuoteTokenID),
+		storage.SettledKey(user, bo.Pair.BaseTokenID),
+		storage.SettledKey(user, bo.Pair.QuoteTokenID),
+		storage.PairKey(bo.Pair),
+	}
+}
+
+// Fee is CancelOrder's flat fee for each cancel and add, so a larger batch
+// costs more to get into a block. Adds can lock either token of the pair, so
+// their taker fees are charged with their collateral in Execute.
+func (bo *BatchOrders) Fee(timestamp int64, blockHeight uint64, auth chain.Auth, memoryState any) (amount uint64) {
+	if n := uint64(len(bo.Cancels) + len(bo.Adds)); n > 1 {
+		return n
+	}
+	return 1
+}
+
+func (bo *BatchOrders) Token(memoryState any) (tokenID ids.ID) {
+	return bo.Pair.QuoteTokenID
+}
+
+func (bo *BatchOrders) Execute(
+	ctx context.Context,
+	r chain.Rules,
+	db chain.Database,
+	timestamp int64,
+	auth chain.Auth,
+	txID ids.ID,
+	warpVerified bool,
+	memoryState any,
+	blockHeight uint64,
+) (result *chain.Result, err error) {
+	obm := memoryState.(*orderbook.OrderbookManager)
+	user := auth.PublicKey()
+	var baseBalance uint64
+	var quoteBalance uint64
+	if len(bo.Cancels)+len(bo.Adds) == 0 {
+		err = errors.New("batch cannot be empty")
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if len(bo.Cancels)+len(bo.Adds) > consts.MaxBatchOrders {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(ErrTooManyBatchOrders)}, nil
+	}
+	if err = checkExpiry(r, timestamp, bo.BlockExpiryWindow, bo.TimeExpiry); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	for _, add := range bo.Adds {
+		if add.Quantity == 0 || add.Price == 0 || !add.TimeInForce.Valid() {
+			err = errors.New("invalid batch order")
+			return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+		}
+	}
+	if len(bo.Adds) > 0 {
+		var info *orderbook.PairInfo
+		if info, err = getListing(ctx, db, bo.Pair); err != nil {
+			return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+		}
+		for _, add := range bo.Adds {
+			if err = checkListing(info, bo.Pair, add.Price, add.Quantity); err != nil {
+				return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+			}
+		}
+	}
+	if baseBalance, err = storage.PullPendingBalance(ctx, db, obm, user, bo.Pair.BaseTokenID, blockHeight); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if quoteBalance, err = storage.PullPendingBalance(ctx, db, obm, user, bo.Pair.QuoteTokenID, blockHeight); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+
+	// Any add that cannot be collateralised fails the whole tx, which rolls
+	// back the adds already debited.
+	ob := obm.ViewOrderbook(bo.Pair)
+	for _, add := range bo.Adds {
+		isFilled := false
+		getAmount := orderbook.GetAmountFn(add.Side, isFilled, bo.Pair)
+		amount, tokenID := getAmount(add.Quantity, add.Price)
+		amount += ob.GetFee(user, add.Side, timestamp, amount)
+		var decBalance uint64
+		if decBalance, err = storage.DecBalance(ctx, db, user, tokenID, amount); err != nil {
+			return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+		}
+		if tokenID == bo.Pair.BaseTokenID {
+			baseBalance = decBalance
+		} else {
+			quoteBalance = decBalance
+		}
+	}
+	output := utils.PackUpdatedBalance(user, baseBalance, user, quoteBalance)
+	return &chain.Result{Success: true, Units: 0, Output: output}, nil
+}
+
+func (bo *BatchOrders) Marshal(p *codec.Packer) {
+	p.PackID(bo.Pair.BaseTokenID)
+	p.PackID(bo.Pair.QuoteTokenID)
+	p.PackInt(len(bo.Cancels))
+	for _, orderID := range bo.Cancels {
+		p.PackID(orderID)
+	}
+	p.PackInt(len(bo.Adds))
+	for _, add := range bo.Adds {
+		p.PackUint64(add.Quantity)
+		p.PackBool(add.Side)
+		p.PackUint64(add.Price)
+		p.PackByte(byte(add.TimeInForce))
+	}
+	p.PackUint64(bo.BlockExpiryWindow)
+	p.PackInt64(bo.TimeExpiry)
+}
+
+func UnmarshalBatchOrders(p *codec.Packer, _ *warp.Message) (chain.Action, error) {
+	var bo BatchOrders
+	p.UnpackID(true, &bo.Pair.BaseTokenID)
+	p.UnpackID(true, &bo.Pair.QuoteTokenID)
+	numCancels := p.UnpackInt(false)
+	if numCancels > consts.MaxBatchOrders {
+		return nil, ErrTooManyBatchOrders
+	}
+	bo.Cancels = make([]ids.ID, numCancels)
+	for i := range bo.Cancels {
+		p.UnpackID(false, &bo.Cancels[i])
+	}
+	numAdds := p.UnpackInt(false)
+	if numCancels+numAdds > consts.MaxBatchOrders {
+		return nil, ErrTooManyBatchOrders
+	}
+	bo.Adds = make([]BatchAdd, numAdds)
+	for i := range bo.Adds {
+		bo.Adds[i].Quantity = p.UnpackUint64(true)
+		bo.Adds[i].Side = p.UnpackBool()
+		bo.Adds[i].Price = p.UnpackUint64(true)
+		bo.Adds[i].TimeInForce = orderbook.TimeInForce(p.UnpackByte())
+	}
+	bo.BlockExpiryWindow = p.UnpackUint64(false)
+	bo.TimeExpiry = p.UnpackInt64(false)
+	if bo.BlockExpiryWindow == 0 {
+		bo.BlockExpiryWindow = consts.EvictionBlockWindow
+	}
+	return &bo, p.Err()
+}
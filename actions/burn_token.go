@@ -0,0 +1,101 @@
+package actions
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/math"
+	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
+	"github.com/jaimi-io/clobvm/orderbook"
+	"github.com/jaimi-io/clobvm/storage"
+	"github.com/jaimi-io/clobvm/utils"
+	"github.com/jaimi-io/hypersdk/chain"
+	"github.com/jaimi-io/hypersdk/codec"
+	hutils "github.com/jaimi-io/hypersdk/utils"
+)
+
+// BurnToken destroys [Amount] of the sender's balance of a token created with
+// CreateToken, reducing its supply.
+type BurnToken struct {
+	TokenID ids.ID `json:"tokenID"`
+	Amount  uint64 `json:"amount"`
+}
+
+func (bt *BurnToken) MaxUnits(r chain.Rules) uint64 {
+	return 1
+}
+
+func (bt *BurnToken) ValidRange(r chain.Rules) (start int64, end int64) {
+	return -1, -1
+}
+
+func (bt *BurnToken) StateKeys(auth chain.Auth, _ ids.ID) [][]byte {
+	user := auth.PublicKey()
+	return [][]byte{
+		storage.TokenKey(bt.TokenID),
+		storage.BalanceKey(user, bt.TokenID),
+		storage.SettledKey(user, bt.TokenID),
+	}
+}
+
+func (bt *BurnToken) Fee(timestamp int64, blockHeight uint64, auth chain.Auth, memoryState any) (amount uint64) {
+	return 1
+}
+
+func (bt *BurnToken) Token(memoryState any) (tokenID ids.ID) {
+	return bt.TokenID
+}
+
+func (bt *BurnToken) Execute(
+	ctx context.Context,
+	r chain.Rules,
+	db chain.Database,
+	timestamp int64,
+	auth chain.Auth,
+	txID ids.ID,
+	warpVerified bool,
+	memoryState any,
+	blockHeight uint64,
+) (result *chain.Result, err error) {
+	obm := memoryState.(*orderbook.OrderbookManager)
+	user := auth.PublicKey()
+	var balance uint64
+	if bt.Amount == 0 {
+		err = errors.New("amount cannot be zero")
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	info, err := storage.GetToken(ctx, db, bt.TokenID)
+	if err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if info == nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(ErrTokenNotFound)}, nil
+	}
+	if _, err = storage.PullPendingBalance(ctx, db, obm, user, bt.TokenID, blockHeight); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if balance, err = storage.DecBalance(ctx, db, user, bt.TokenID, bt.Amount); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if info.Supply, err = math.Sub(info.Supply, bt.Amount); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if err = storage.SetToken(ctx, db, bt.TokenID, info); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	output := utils.PackUpdatedBalance(user, balance, user, balance)
+	return &chain.Result{Success: true, Units: 0, Output: output}, nil
+}
+
+func (bt *BurnToken) Marshal(p *codec.Packer) {
+	p.PackID(bt.TokenID)
+	p.PackUint64(bt.Amount)
+}
+
+func UnmarshalBurnToken(p *codec.Packer, _ *warp.Message) (chain.Action, error) {
+	var bt BurnToken
+	p.UnpackID(true, &bt.TokenID)
+	bt.Amount = p.UnpackUint64(true)
+	return &bt, p.Err()
+}
@@ -31,6 +31,8 @@ func (co *CancelOrder) StateKeys(auth chain.Auth, _ ids.ID) [][]byte {
 	return [][]byte{
 		storage.BalanceKey(user, co.Pair.BaseTokenID),
 		storage.BalanceKey(user, co.Pair.QuoteTokenID),
+		storage.SettledKey(user, co.Pair.BaseTokenID),
+		storage.SettledKey(user, co.Pair.QuoteTokenID),
 	}
 }
 
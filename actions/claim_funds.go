@@ -0,0 +1,117 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
+	"github.com/jaimi-io/clobvm/consts"
+	"github.com/jaimi-io/clobvm/orderbook"
+	"github.com/jaimi-io/clobvm/storage"
+	"github.com/jaimi-io/hypersdk/chain"
+	"github.com/jaimi-io/hypersdk/codec"
+	hutils "github.com/jaimi-io/hypersdk/utils"
+)
+
+var (
+	ErrTooManyClaimTokens = fmt.Errorf("claim cannot hold more than %d tokens", consts.MaxClaimTokens)
+	ErrNothingToClaim     = errors.New("nothing to claim")
+)
+
+// ClaimFunds moves everything claimable of the sender's pending funds in
+// [TokenIDs] into their balances, without having to trade or transfer.
+type ClaimFunds struct {
+	TokenIDs []ids.ID `json:"tokenIDs"`
+}
+
+func (cf *ClaimFunds) MaxUnits(r chain.Rules) uint64 {
+	return 1
+}
+
+func (cf *ClaimFunds) ValidRange(r chain.Rules) (start int64, end int64) {
+	return -1, -1
+}
+
+func (cf *ClaimFunds) StateKeys(auth chain.Auth, _ ids.ID) [][]byte {
+	user := auth.PublicKey()
+	keys := make([][]byte, 0, 2*len(cf.TokenIDs))
+	for _, tokenID := range cf.TokenIDs {
+		keys = append(keys, storage.BalanceKey(user, tokenID), storage.SettledKey(user, tokenID))
+	}
+	return keys
+}
+
+func (cf *ClaimFunds) Fee(timestamp int64, blockHeight uint64, auth chain.Auth, memoryState any) (amount uint64) {
+	return 1
+}
+
+func (cf *ClaimFunds) Token(memoryState any) (tokenID ids.ID) {
+	if len(cf.TokenIDs) == 0 {
+		return ids.Empty
+	}
+	return cf.TokenIDs[0]
+}
+
+func (cf *ClaimFunds) Execute(
+	ctx context.Context,
+	r chain.Rules,
+	db chain.Database,
+	timestamp int64,
+	auth chain.Auth,
+	txID ids.ID,
+	warpVerified bool,
+	memoryState any,
+	blockHeight uint64,
+) (result *chain.Result, err error) {
+	obm := memoryState.(*orderbook.OrderbookManager)
+	user := auth.PublicKey()
+	if len(cf.TokenIDs) == 0 {
+		err = errors.New("no tokens to claim")
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if len(cf.TokenIDs) > consts.MaxClaimTokens {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(ErrTooManyClaimTokens)}, nil
+	}
+	seen := make(map[ids.ID]struct{}, len(cf.TokenIDs))
+	for _, tokenID := range cf.TokenIDs {
+		if _, ok := seen[tokenID]; ok {
+			err = errors.New("duplicate claim token")
+			return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+		}
+		seen[tokenID] = struct{}{}
+	}
+	var claimed bool
+	for _, tokenID := range cf.TokenIDs {
+		var balance uint64
+		if balance, err = storage.PullPendingBalance(ctx, db, obm, user, tokenID, blockHeight); err != nil {
+			return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+		}
+		claimed = claimed || balance > 0
+	}
+	if !claimed {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(ErrNothingToClaim)}, nil
+	}
+	return &chain.Result{Success: true, Units: 0}, nil
+}
+
+func (cf *ClaimFunds) Marshal(p *codec.Packer) {
+	p.PackInt(len(cf.TokenIDs))
+	for _, tokenID := range cf.TokenIDs {
+		p.PackID(tokenID)
+	}
+}
+
+func UnmarshalClaimFunds(p *codec.Packer, _ *warp.Message) (chain.Action, error) {
+	var cf ClaimFunds
+	numTokens := p.UnpackInt(false)
+	if numTokens > consts.MaxClaimTokens {
+		return nil, ErrTooManyClaimTokens
+	}
+	cf.TokenIDs = make([]ids.ID, numTokens)
+	for i := range cf.TokenIDs {
+		p.UnpackID(true, &cf.TokenIDs[i])
+	}
+	return &cf, p.Err()
+}
@@ -0,0 +1,134 @@
+package actions
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
+	"github.com/jaimi-io/clobvm/orderbook"
+	"github.com/jaimi-io/clobvm/storage"
+	"github.com/jaimi-io/hypersdk/chain"
+	"github.com/jaimi-io/hypersdk/codec"
+	hutils "github.com/jaimi-io/hypersdk/utils"
+)
+
+var (
+	ErrPairExists        = errors.New("pair is already listed by another account")
+	ErrNotBaseTokenOwner = errors.New("only the owner of the base token can list a pair")
+)
+
+// CreatePair lists a pair in the registry so orders can be placed on it. Only
+// the owner of the base token can list it, so a pair cannot be squatted on
+// and halted by whoever lists it first; tokens without an owner can only be
+// listed at genesis. The owner can send CreatePair again to update its
+// parameters or status, e.g. to halt trading.
+type CreatePair struct {
+	Pair        orderbook.Pair       `json:"pair"`
+	TickSize    uint64               `json:"tickSize"`
+	LotSize     uint64               `json:"lotSize"`
+	MinNotional uint64               `json:"minNotional"`
+	Status      orderbook.PairStatus `json:"status"`
+}
+
+func (cp *CreatePair) MaxUnits(r chain.Rules) uint64 {
+	return 1
+}
+
+func (cp *CreatePair) ValidRange(r chain.Rules) (start int64, end int64) {
+	return -1, -1
+}
+
+func (cp *CreatePair) StateKeys(auth chain.Auth, _ ids.ID) [][]byte {
+	user := auth.PublicKey()
+	return [][]byte{
+		storage.BalanceKey(user, cp.Pair.QuoteTokenID),
+		storage.PairKey(cp.Pair),
+		storage.TokenKey(cp.Pair.BaseTokenID),
+		storage.TokenKey(cp.Pair.QuoteTokenID),
+	}
+}
+
+func (cp *CreatePair) Fee(timestamp int64, blockHeight uint64, auth chain.Auth, memoryState any) (amount uint64) {
+	return 1
+}
+
+func (cp *CreatePair) Token(memoryState any) (tokenID ids.ID) {
+	return cp.Pair.QuoteTokenID
+}
+
+func (cp *CreatePair) Execute(
+	ctx context.Context,
+	r chain.Rules,
+	db chain.Database,
+	timestamp int64,
+	auth chain.Auth,
+	txID ids.ID,
+	warpVerified bool,
+	memoryState any,
+	blockHeight uint64,
+) (result *chain.Result, err error) {
+	user := auth.PublicKey()
+	if cp.Pair.BaseTokenID == cp.Pair.QuoteTokenID {
+		err = errors.New("base and quote tokens must differ")
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if cp.TickSize == 0 || cp.LotSize == 0 {
+		err = errors.New("tick and lot sizes cannot be zero")
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if !cp.Status.Valid() {
+		err = errors.New("invalid pair status")
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	for _, tokenID := range []ids.ID{cp.Pair.BaseTokenID, cp.Pair.QuoteTokenID} {
+		token, err := storage.GetToken(ctx, db, tokenID)
+		if err != nil {
+			return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+		}
+		if token == nil {
+			return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(ErrTokenNotFound)}, nil
+		}
+		if tokenID == cp.Pair.BaseTokenID && token.Owner != user {
+			return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(ErrNotBaseTokenOwner)}, nil
+		}
+	}
+	info, err := storage.GetPair(ctx, db, cp.Pair)
+	if err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if info != nil && info.Creator != user {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(ErrPairExists)}, nil
+	}
+	info = &orderbook.PairInfo{
+		Creator:     user,
+		TickSize:    cp.TickSize,
+		LotSize:     cp.LotSize,
+		MinNotional: cp.MinNotional,
+		Status:      cp.Status,
+	}
+	if err = storage.SetPair(ctx, db, cp.Pair, info); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	return &chain.Result{Success: true, Units: 0}, nil
+}
+
+func (cp *CreatePair) Marshal(p *codec.Packer) {
+	p.PackID(cp.Pair.BaseTokenID)
+	p.PackID(cp.Pair.QuoteTokenID)
+	p.PackUint64(cp.TickSize)
+	p.PackUint64(cp.LotSize)
+	p.PackUint64(cp.MinNotional)
+	p.PackByte(byte(cp.Status))
+}
+
+func UnmarshalCreatePair(p *codec.Packer, _ *warp.Message) (chain.Action, error) {
+	var cp CreatePair
+	p.UnpackID(true, &cp.Pair.BaseTokenID)
+	p.UnpackID(true, &cp.Pair.QuoteTokenID)
+	cp.TickSize = p.UnpackUint64(true)
+	cp.LotSize = p.UnpackUint64(true)
+	cp.MinNotional = p.UnpackUint64(false)
+	cp.Status = orderbook.PairStatus(p.UnpackByte())
+	return &cp, p.Err()
+}
@@ -0,0 +1,96 @@
+package actions
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
+	"github.com/jaimi-io/clobvm/consts"
+	"github.com/jaimi-io/clobvm/genesis"
+	"github.com/jaimi-io/clobvm/storage"
+	"github.com/jaimi-io/hypersdk/chain"
+	"github.com/jaimi-io/hypersdk/codec"
+	hutils "github.com/jaimi-io/hypersdk/utils"
+)
+
+var (
+	ErrInvalidSymbol   = errors.New("symbol must be 1 to 8 characters")
+	ErrInvalidDecimals = errors.New("decimals exceed balance decimals")
+)
+
+// CreateToken creates a token owned by the sender with no supply. The token's
+// ID is the ID of the tx that created it. Like other actions that create
+// state, it charges a fee, which is paid in the native token since the new
+// token has no supply yet.
+type CreateToken struct {
+	Symbol    string `json:"symbol"`
+	Decimals  uint8  `json:"decimals"`
+	MaxSupply uint64 `json:"maxSupply"` // 0 is uncapped
+}
+
+func (ct *CreateToken) MaxUnits(r chain.Rules) uint64 {
+	return 1
+}
+
+func (ct *CreateToken) ValidRange(r chain.Rules) (start int64, end int64) {
+	return -1, -1
+}
+
+func (ct *CreateToken) StateKeys(auth chain.Auth, txID ids.ID) [][]byte {
+	return [][]byte{
+		storage.TokenKey(txID),
+		storage.BalanceKey(auth.PublicKey(), genesis.NativeTokenID),
+	}
+}
+
+func (ct *CreateToken) Fee(timestamp int64, blockHeight uint64, auth chain.Auth, memoryState any) (amount uint64) {
+	return 1
+}
+
+func (ct *CreateToken) Token(memoryState any) (tokenID ids.ID) {
+	return genesis.NativeTokenID
+}
+
+func (ct *CreateToken) Execute(
+	ctx context.Context,
+	r chain.Rules,
+	db chain.Database,
+	timestamp int64,
+	auth chain.Auth,
+	txID ids.ID,
+	warpVerified bool,
+	memoryState any,
+	blockHeight uint64,
+) (result *chain.Result, err error) {
+	if len(ct.Symbol) == 0 || len(ct.Symbol) > consts.MaxSymbolSize {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(ErrInvalidSymbol)}, nil
+	}
+	if ct.Decimals > consts.BalanceDecimals {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(ErrInvalidDecimals)}, nil
+	}
+	info := &storage.TokenInfo{
+		Symbol:    ct.Symbol,
+		Decimals:  ct.Decimals,
+		MaxSupply: ct.MaxSupply,
+		Owner:     auth.PublicKey(),
+	}
+	if err = storage.SetToken(ctx, db, txID, info); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	return &chain.Result{Success: true, Units: 0}, nil
+}
+
+func (ct *CreateToken) Marshal(p *codec.Packer) {
+	p.PackString(ct.Symbol)
+	p.PackByte(ct.Decimals)
+	p.PackUint64(ct.MaxSupply)
+}
+
+func UnmarshalCreateToken(p *codec.Packer, _ *warp.Message) (chain.Action, error) {
+	var ct CreateToken
+	ct.Symbol = p.UnpackString(true)
+	ct.Decimals = p.UnpackByte()
+	ct.MaxSupply = p.UnpackUint64(false)
+	return &ct, p.Err()
+}
@@ -0,0 +1,33 @@
+package actions
+
+import (
+	"errors"
+
+	"github.com/jaimi-io/clobvm/genesis"
+	"github.com/jaimi-io/hypersdk/chain"
+)
+
+var (
+	ErrBlockExpiryWindowTooLarge = errors.New("block expiry window exceeds maximum")
+	ErrInvalidTimeExpiry         = errors.New("time expiry must be in the future")
+	ErrTimeExpiryTooLate         = errors.New("time expiry exceeds maximum window")
+)
+
+// checkExpiry enforces the expiry limits of the genesis rules on an order
+// submitted at [timestamp]. A [timeExpiry] of 0 means the order only expires
+// after [blockWindow] blocks.
+func checkExpiry(r chain.Rules, timestamp int64, blockWindow uint64, timeExpiry int64) error {
+	if v, ok := r.FetchCustom(genesis.MaxBlockExpiryWindowKey); ok && blockWindow > v.(uint64) {
+		return ErrBlockExpiryWindowTooLarge
+	}
+	if timeExpiry == 0 {
+		return nil
+	}
+	if timeExpiry <= timestamp {
+		return ErrInvalidTimeExpiry
+	}
+	if v, ok := r.FetchCustom(genesis.MaxTimeExpiryWindowKey); ok && timeExpiry-timestamp > v.(int64) {
+		return ErrTimeExpiryTooLate
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package actions
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/math"
+	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
+	"github.com/jaimi-io/clobvm/genesis"
+	"github.com/jaimi-io/clobvm/orderbook"
+	"github.com/jaimi-io/clobvm/storage"
+	"github.com/jaimi-io/clobvm/utils"
+	"github.com/jaimi-io/hypersdk/chain"
+	"github.com/jaimi-io/hypersdk/codec"
+	"github.com/jaimi-io/hypersdk/crypto"
+	hutils "github.com/jaimi-io/hypersdk/utils"
+)
+
+var (
+	ErrTokenNotFound     = errors.New("token not found")
+	ErrNotTokenOwner     = errors.New("only the token owner can mint")
+	ErrMaxSupplyExceeded = errors.New("mint exceeds max supply")
+)
+
+// MintToken lets the owner of a token create [Amount] of it for [To].
+type MintToken struct {
+	To      crypto.PublicKey `json:"to"`
+	TokenID ids.ID           `json:"tokenID"`
+	Amount  uint64           `json:"amount"`
+}
+
+func (mt *MintToken) MaxUnits(r chain.Rules) uint64 {
+	return 1
+}
+
+func (mt *MintToken) ValidRange(r chain.Rules) (start int64, end int64) {
+	return -1, -1
+}
+
+func (mt *MintToken) StateKeys(auth chain.Auth, _ ids.ID) [][]byte {
+	user := auth.PublicKey()
+	return [][]byte{
+		storage.TokenKey(mt.TokenID),
+		storage.BalanceKey(user, mt.TokenID),
+		storage.SettledKey(user, mt.TokenID),
+		storage.BalanceKey(mt.To, mt.TokenID),
+		storage.SettledKey(mt.To, mt.TokenID),
+		storage.BalanceKey(user, genesis.NativeTokenID),
+	}
+}
+
+// Fee is paid in the native token as the owner may not hold the token before
+// its first mint.
+func (mt *MintToken) Fee(timestamp int64, blockHeight uint64, auth chain.Auth, memoryState any) (amount uint64) {
+	return 1
+}
+
+func (mt *MintToken) Token(memoryState any) (tokenID ids.ID) {
+	return genesis.NativeTokenID
+}
+
+func (mt *MintToken) Execute(
+	ctx context.Context,
+	r chain.Rules,
+	db chain.Database,
+	timestamp int64,
+	auth chain.Auth,
+	txID ids.ID,
+	warpVerified bool,
+	memoryState any,
+	blockHeight uint64,
+) (result *chain.Result, err error) {
+	user := auth.PublicKey()
+	obm := memoryState.(*orderbook.OrderbookManager)
+	var userBalance uint64
+	var toBalance uint64
+	if mt.Amount == 0 {
+		err = errors.New("amount cannot be zero")
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	info, err := storage.GetToken(ctx, db, mt.TokenID)
+	if err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if info == nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(ErrTokenNotFound)}, nil
+	}
+	if info.Owner != user {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(ErrNotTokenOwner)}, nil
+	}
+	if info.Supply, err = math.Add64(info.Supply, mt.Amount); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if info.MaxSupply > 0 && info.Supply > info.MaxSupply {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(ErrMaxSupplyExceeded)}, nil
+	}
+	if err = storage.SetToken(ctx, db, mt.TokenID, info); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	for _, pk := range []crypto.PublicKey{user, mt.To} {
+		if _, err = storage.PullPendingBalance(ctx, db, obm, pk, mt.TokenID, blockHeight); err != nil {
+			return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+		}
+	}
+	if toBalance, err = storage.IncBalance(ctx, db, mt.To, mt.TokenID, mt.Amount); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if _, userBalance, err = storage.GetBalance(ctx, db, user, mt.TokenID); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	output := utils.PackUpdatedBalance(user, userBalance, mt.To, toBalance)
+	return &chain.Result{Success: true, Units: 0, Output: output}, nil
+}
+
+func (mt *MintToken) Marshal(p *codec.Packer) {
+	p.PackPublicKey(mt.To)
+	p.PackID(mt.TokenID)
+	p.PackUint64(mt.Amount)
+}
+
+func UnmarshalMintToken(p *codec.Packer, _ *warp.Message) (chain.Action, error) {
+	var mt MintToken
+	p.UnpackPublicKey(true, &mt.To)
+	p.UnpackID(true, &mt.TokenID)
+	mt.Amount = p.UnpackUint64(true)
+	return &mt, p.Err()
+}
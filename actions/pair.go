@@ -0,0 +1,53 @@
+package actions
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jaimi-io/clobvm/orderbook"
+	"github.com/jaimi-io/clobvm/storage"
+	"github.com/jaimi-io/hypersdk/chain"
+)
+
+var (
+	ErrPairNotListed    = errors.New("pair is not listed")
+	ErrPairHalted       = errors.New("pair is halted")
+	ErrOffTick          = errors.New("price is not a multiple of the tick size")
+	ErrOffLot           = errors.New("quantity is not a multiple of the lot size")
+	ErrBelowMinNotional = errors.New("order value is below the minimum notional")
+)
+
+// getListing returns the registry listing of [pair] if it accepts orders.
+func getListing(ctx context.Context, db chain.Database, pair orderbook.Pair) (*orderbook.PairInfo, error) {
+	info, err := storage.GetPair(ctx, db, pair)
+	if err != nil {
+		return nil, err
+	}
+	if info == nil {
+		return nil, ErrPairNotListed
+	}
+	if info.Status != orderbook.PairActive {
+		return nil, ErrPairHalted
+	}
+	return info, nil
+}
+
+// checkListing enforces the tick size, lot size and minimum notional of
+// [info] on an order of [quantity] at [price]. Market orders have no price, so
+// only their lot size is checked.
+func checkListing(info *orderbook.PairInfo, pair orderbook.Pair, price uint64, quantity uint64) error {
+	if price%info.TickSize != 0 {
+		return ErrOffTick
+	}
+	if quantity%info.LotSize != 0 {
+		return ErrOffLot
+	}
+	if price == 0 {
+		return nil
+	}
+	getAmount := orderbook.GetAmountFn(true, false, pair)
+	if notional, _ := getAmount(quantity, price); notional < info.MinNotional {
+		return ErrBelowMinNotional
+	}
+	return nil
+}
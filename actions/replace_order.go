@@ -0,0 +1,153 @@
+package actions
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
+	"github.com/jaimi-io/clobvm/orderbook"
+	"github.com/jaimi-io/clobvm/storage"
+	"github.com/jaimi-io/clobvm/utils"
+	"github.com/jaimi-io/hypersdk/chain"
+	"github.com/jaimi-io/hypersdk/codec"
+	hutils "github.com/jaimi-io/hypersdk/utils"
+)
+
+// ReplaceOrder amends the price and quantity of a resting order. The
+// replacement is collateralised in full like an AddOrder; once accepted the
+// old order's collateral, or the replacement's if the order is only reduced,
+// is returned through pending funds.
+//
+// A [Price] of 0 keeps the order's price and only reduces it to [Quantity].
+// Nothing is locked for it beyond the base fee, and only the reduction is
+// returned. It does nothing if the order has since fallen below [Quantity].
+type ReplaceOrder struct {
+	Pair     orderbook.Pair `json:"pair"`
+	OrderID  ids.ID         `json:"orderID"`
+	Quantity uint64         `json:"quantity"`
+	Side     bool           `json:"side"`
+	Price    uint64         `json:"price"`
+}
+
+func (ro *ReplaceOrder) MaxUnits(r chain.Rules) uint64 {
+	return 1
+}
+
+func (ro *ReplaceOrder) ValidRange(r chain.Rules) (start int64, end int64) {
+	return -1, -1
+}
+
+func (ro *ReplaceOrder) amount() (uint64, ids.ID) {
+	isFilled := false
+	getAmount := orderbook.GetAmountFn(ro.Side, isFilled, ro.Pair)
+	return getAmount(ro.Quantity, ro.Price)
+}
+
+func (ro *ReplaceOrder) StateKeys(auth chain.Auth, txID ids.ID) [][]byte {
+	user := auth.PublicKey()
+	return [][]byte{
+		storage.BalanceKey(user, ro.Pair.BaseTokenID),
+		storage.BalanceKey(user, ro.Pair.QuoteTokenID),
+		storage.SettledKey(user, ro.Pair.BaseTokenID),
+		storage.SettledKey(user, ro.Pair.QuoteTokenID),
+		storage.PairKey(ro.Pair),
+	}
+}
+
+// reduceOnly reports whether the replacement only sizes the order down at its
+// current price.
+func (ro *ReplaceOrder) reduceOnly() bool {
+	return ro.Price == 0
+}
+
+func (ro *ReplaceOrder) Fee(timestamp int64, blockHeight uint64, auth chain.Auth, memoryState any) (amount uint64) {
+	if ro.reduceOnly() {
+		return 1
+	}
+	obm := memoryState.(*orderbook.OrderbookManager)
+	if obm == nil {
+		return 0
+	}
+	user := auth.PublicKey()
+	amt, _ := ro.amount()
+	return obm.ViewOrderbook(ro.Pair).GetFee(user, ro.Side, timestamp, amt)
+}
+
+func (ro *ReplaceOrder) Token(memoryState any) (tokenID ids.ID) {
+	if ro.reduceOnly() {
+		return ro.Pair.QuoteTokenID
+	}
+	_, tokenID = ro.amount()
+	return tokenID
+}
+
+func (ro *ReplaceOrder) Execute(
+	ctx context.Context,
+	r chain.Rules,
+	db chain.Database,
+	timestamp int64,
+	auth chain.Auth,
+	txID ids.ID,
+	warpVerified bool,
+	memoryState any,
+	blockHeight uint64,
+) (result *chain.Result, err error) {
+	obm := memoryState.(*orderbook.OrderbookManager)
+	user := auth.PublicKey()
+	var baseBalance uint64
+	var quoteBalance uint64
+	if ro.Quantity == 0 {
+		err = errors.New("amount cannot be zero")
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	var info *orderbook.PairInfo
+	if info, err = getListing(ctx, db, ro.Pair); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if err = checkListing(info, ro.Pair, ro.Price, ro.Quantity); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if baseBalance, err = storage.PullPendingBalance(ctx, db, obm, user, ro.Pair.BaseTokenID, blockHeight); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if quoteBalance, err = storage.PullPendingBalance(ctx, db, obm, user, ro.Pair.QuoteTokenID, blockHeight); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if ro.reduceOnly() {
+		output := utils.PackUpdatedBalance(user, baseBalance, user, quoteBalance)
+		return &chain.Result{Success: true, Units: 0, Output: output}, nil
+	}
+	amount, tokenID := ro.amount()
+	var decBalance uint64
+	if decBalance, err = storage.DecBalance(ctx, db, user, tokenID, amount); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if tokenID == ro.Pair.BaseTokenID {
+		baseBalance = decBalance
+	} else {
+		quoteBalance = decBalance
+	}
+	output := utils.PackUpdatedBalance(user, baseBalance, user, quoteBalance)
+	return &chain.Result{Success: true, Units: 0, Output: output}, nil
+}
+
+func (ro *ReplaceOrder) Marshal(p *codec.Packer) {
+	p.PackID(ro.Pair.BaseTokenID)
+	p.PackID(ro.Pair.QuoteTokenID)
+	p.PackID(ro.OrderID)
+	p.PackUint64(ro.Quantity)
+	p.PackBool(ro.Side)
+	p.PackUint64(ro.Price)
+}
+
+func UnmarshalReplaceOrder(p *codec.Packer, _ *warp.Message) (chain.Action, error) {
+	var ro ReplaceOrder
+	p.UnpackID(true, &ro.Pair.BaseTokenID)
+	p.UnpackID(true, &ro.Pair.QuoteTokenID)
+	p.UnpackID(true, &ro.OrderID)
+	ro.Quantity = p.UnpackUint64(true)
+	ro.Side = p.UnpackBool()
+	ro.Price = p.UnpackUint64(false)
+	return &ro, p.Err()
+}
@@ -0,0 +1,120 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
+	"github.com/jaimi-io/clobvm/consts"
+	"github.com/jaimi-io/clobvm/orderbook"
+	"github.com/jaimi-io/clobvm/storage"
+	"github.com/jaimi-io/hypersdk/chain"
+	"github.com/jaimi-io/hypersdk/codec"
+	"github.com/jaimi-io/hypersdk/crypto"
+	hutils "github.com/jaimi-io/hypersdk/utils"
+)
+
+var (
+	ErrTooManySettleUsers = fmt.Errorf("settlement cannot hold more than %d users", consts.MaxSettleUsers)
+	ErrNothingToSettle    = errors.New("nothing to settle")
+)
+
+// SettleFunds moves the matured pending funds of [TokenID] into the balances
+// of [Users]. Every action touching a balance already pulls its owner's
+// matured funds first; this settles users who have not sent one since.
+// Anyone can settle for anyone, since the funds only ever go to their owners.
+type SettleFunds struct {
+	TokenID ids.ID             `json:"tokenID"`
+	Users   []crypto.PublicKey `json:"users"`
+}
+
+func (sf *SettleFunds) MaxUnits(r chain.Rules) uint64 {
+	return 1
+}
+
+func (sf *SettleFunds) ValidRange(r chain.Rules) (start int64, end int64) {
+	return -1, -1
+}
+
+func (sf *SettleFunds) StateKeys(auth chain.Auth, _ ids.ID) [][]byte {
+	// The fee is paid from the sender's balance of the settled token
+	keys := make([][]byte, 0, 2*len(sf.Users)+1)
+	keys = append(keys, storage.BalanceKey(auth.PublicKey(), sf.TokenID))
+	for _, user := range sf.Users {
+		keys = append(keys, storage.BalanceKey(user, sf.TokenID), storage.SettledKey(user, sf.TokenID))
+	}
+	return keys
+}
+
+func (sf *SettleFunds) Fee(timestamp int64, blockHeight uint64, auth chain.Auth, memoryState any) (amount uint64) {
+	return 1
+}
+
+func (sf *SettleFunds) Token(memoryState any) (tokenID ids.ID) {
+	return sf.TokenID
+}
+
+func (sf *SettleFunds) Execute(
+	ctx context.Context,
+	r chain.Rules,
+	db chain.Database,
+	timestamp int64,
+	auth chain.Auth,
+	txID ids.ID,
+	warpVerified bool,
+	memoryState any,
+	blockHeight uint64,
+) (result *chain.Result, err error) {
+	obm := memoryState.(*orderbook.OrderbookManager)
+	if len(sf.Users) == 0 {
+		err = errors.New("no users to settle")
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
+	if len(sf.Users) > consts.MaxSettleUsers {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(ErrTooManySettleUsers)}, nil
+	}
+	seen := make(map[crypto.PublicKey]struct{}, len(sf.Users))
+	for _, user := range sf.Users {
+		if _, ok := seen[user]; ok {
+			err = errors.New("duplicate settle user")
+			return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+		}
+		seen[user] = struct{}{}
+	}
+	var settled bool
+	for _, user := range sf.Users {
+		var balance uint64
+		if balance, err = storage.PullPendingBalance(ctx, db, obm, user, sf.TokenID, blockHeight); err != nil {
+			return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+		}
+		settled = settled || balance > 0
+	}
+	if !settled {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(ErrNothingToSettle)}, nil
+	}
+	return &chain.Result{Success: true, Units: 0}, nil
+}
+
+func (sf *SettleFunds) Marshal(p *codec.Packer) {
+	p.PackID(sf.TokenID)
+	p.PackInt(len(sf.Users))
+	for _, user := range sf.Users {
+		p.PackPublicKey(user)
+	}
+}
+
+func UnmarshalSettleFunds(p *codec.Packer, _ *warp.Message) (chain.Action, error) {
+	var sf SettleFunds
+	p.UnpackID(true, &sf.TokenID)
+	numUsers := p.UnpackInt(false)
+	if numUsers > consts.MaxSettleUsers {
+		return nil, ErrTooManySettleUsers
+	}
+	sf.Users = make([]crypto.PublicKey, numUsers)
+	for i := range sf.Users {
+		p.UnpackPublicKey(true, &sf.Users[i])
+	}
+	return &sf, p.Err()
+}
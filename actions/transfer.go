@@ -33,7 +33,9 @@ func (t *Transfer) StateKeys(auth chain.Auth, _ ids.ID) [][]byte {
 	user := auth.PublicKey()
 	return [][]byte{
 		storage.BalanceKey(user, t.TokenID),
+		storage.SettledKey(user, t.TokenID),
 		storage.BalanceKey(t.To, t.TokenID),
+		storage.SettledKey(t.To, t.TokenID),
 	}
 }
 
@@ -70,6 +72,9 @@ func (t *Transfer) Execute(
 	if baseBalance, err = storage.DecBalance(ctx, db, user, t.TokenID, t.Amount); err != nil {
 		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
 	}
+	if _, err = storage.PullPendingBalance(ctx, db, obm, t.To, t.TokenID, blockHeight); err != nil {
+		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
+	}
 	if quoteBalance, err = storage.IncBalance(ctx, db, t.To, t.TokenID, t.Amount); err != nil {
 		return &chain.Result{Success: false, Units: 0, Output: hutils.ErrBytes(err)}, nil
 	}
@@ -3,11 +3,15 @@ package cmd
 import (
 	"context"
 	"errors"
+	"time"
 
+	"github.com/ava-labs/avalanchego/ids"
 	"github.com/jaimi-io/clobvm/actions"
 	cmdc "github.com/jaimi-io/clobvm/cmd/clob-cli/consts"
 	"github.com/jaimi-io/clobvm/consts"
 	"github.com/jaimi-io/clobvm/orderbook"
+	"github.com/jaimi-io/hypersdk/crypto"
+	"github.com/jaimi-io/hypersdk/utils"
 	"github.com/spf13/cobra"
 )
 
@@ -108,11 +112,44 @@ var addOrderCmd = &cobra.Command{
 			return err
 		}
 
+		worstPrice, maxSlippage, err := promptMarketLimit(price)
+		if err != nil {
+			return err
+		}
+
 		blockExpiryWindow, err := promptOptional("blockExpiryWindow")
 		if err != nil {
 			return err
 		}
 
+		// seconds until the order expires, 0 expires by block window only
+		timeExpiryWindow, err := promptOptional("timeExpiryWindow")
+		if err != nil {
+			return err
+		}
+		var timeExpiry int64
+		if timeExpiryWindow > 0 {
+			timeExpiry = time.Now().Unix() + int64(timeExpiryWindow)
+		}
+
+		// 0 = good till cancel, 1 = post only, 2 = immediate or cancel, 3 = fill or kill
+		timeInForce, err := promptOptional("timeInForce")
+		if err != nil {
+			return err
+		}
+
+		// 0 = allow self-trades, 1 = cancel newest, 2 = cancel oldest, 3 = cancel both, 4 = decrement and cancel
+		selfTradePrevention, err := promptOptional("selfTradePrevention")
+		if err != nil {
+			return err
+		}
+
+		// 0 shows the full quantity
+		displayQuantity, err := promptAmount("display quantity", consts.BalanceDecimals)
+		if err != nil {
+			return err
+		}
+
 		// Confirm action
 		cont, err := promptContinue()
 		if !cont || err != nil {
@@ -134,6 +171,12 @@ var addOrderCmd = &cobra.Command{
 			Price: price,
 			Side: side,
 			BlockExpiryWindow: uint64(blockExpiryWindow),
+			TimeInForce: orderbook.TimeInForce(timeInForce),
+			SelfTradePrevention: orderbook.SelfTradePrevention(selfTradePrevention),
+			DisplayQuantity: displayQuantity,
+			TimeExpiry: timeExpiry,
+			WorstPrice: worstPrice,
+			MaxSlippage: maxSlippage,
 		}, authFactory)
 		if err != nil {
 			return err
@@ -166,16 +209,30 @@ var marketOrderCmd = &cobra.Command{
 			}
 		}
 		
+		// 0 spends a quote amount instead, buys only
 		quantity, err := promptAmount("quantity", consts.BalanceDecimals)
 		if err != nil {
 			return err
 		}
 
+		var quoteAmount uint64
+		if quantity == 0 {
+			quoteAmount, err = promptAmount("quote amount", consts.BalanceDecimals)
+			if err != nil {
+				return err
+			}
+		}
+
 		side, err := promptBool("side")
 		if err != nil {
 			return err
 		}
 
+		worstPrice, maxSlippage, err := promptMarketLimit(0)
+		if err != nil {
+			return err
+		}
+
 		// Confirm action
 		cont, err := promptContinue()
 		if !cont || err != nil {
@@ -195,6 +252,170 @@ var marketOrderCmd = &cobra.Command{
 			},
 			Quantity: quantity,
 			Side: side,
+			WorstPrice: worstPrice,
+			MaxSlippage: maxSlippage,
+			QuoteAmount: quoteAmount,
+		}, authFactory)
+		if err != nil {
+			return err
+		}
+		if err := submit(ctx); err != nil {
+			return err
+		}
+		return nil
+	},
+}
+
+var stopOrderCmd = &cobra.Command{
+	Use: "add-stop-order",
+	RunE: func(*cobra.Command, []string) error {
+		ctx := context.Background()
+		_, _, authFactory, cli, tcli, err := defaultActor()
+		if err != nil {
+			return err
+		}
+		baseTokenID, quoteTokenID := getTokens()
+		if cmdc.GetPair {
+			baseTokenID, err = promptToken("base")
+			if err != nil {
+				return err
+			}
+
+			quoteTokenID, err = promptToken("quote")
+			if err != nil {
+				return err
+			}
+		}
+
+		quantity, err := promptAmount("quantity", consts.BalanceDecimals)
+		if err != nil {
+			return err
+		}
+
+		side, err := promptBool("side")
+		if err != nil {
+			return err
+		}
+
+		triggerPrice, err := promptAmount("trigger price", consts.PriceDecimals)
+		if err != nil {
+			return err
+		}
+
+		// 0 enters a market order once triggered
+		price, err := promptAmount("price", consts.PriceDecimals)
+		if err != nil {
+			return err
+		}
+
+		worstPrice, maxSlippage, err := promptMarketLimit(price)
+		if err != nil {
+			return err
+		}
+
+		blockExpiryWindow, err := promptOptional("blockExpiryWindow")
+		if err != nil {
+			return err
+		}
+
+		// Confirm action
+		cont, err := promptContinue()
+		if !cont || err != nil {
+			return err
+		}
+
+		parser, err := tcli.Parser(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Generate transaction
+		submit, _, _, err := cli.GenerateTransaction(ctx, parser, nil, &actions.AddOrder{
+			Pair: orderbook.Pair{
+				BaseTokenID: baseTokenID,
+				QuoteTokenID: quoteTokenID,
+			},
+			Quantity: quantity,
+			Price: price,
+			Side: side,
+			BlockExpiryWindow: uint64(blockExpiryWindow),
+			TriggerPrice: triggerPrice,
+			WorstPrice: worstPrice,
+			MaxSlippage: maxSlippage,
+		}, authFactory)
+		if err != nil {
+			return err
+		}
+		if err := submit(ctx); err != nil {
+			return err
+		}
+		return nil
+	},
+}
+
+var replaceOrderCmd = &cobra.Command{
+	Use: "replace-order",
+	RunE: func(*cobra.Command, []string) error {
+		ctx := context.Background()
+		_, _, authFactory, cli, tcli, err := defaultActor()
+		if err != nil {
+			return err
+		}
+		baseTokenID, quoteTokenID := getTokens()
+		if cmdc.GetPair {
+			baseTokenID, err = promptToken("base")
+			if err != nil {
+				return err
+			}
+
+			quoteTokenID, err = promptToken("quote")
+			if err != nil {
+				return err
+			}
+		}
+
+		orderID, err := promptID("orderID")
+		if err != nil {
+			return err
+		}
+
+		quantity, err := promptAmount("quantity", consts.BalanceDecimals)
+		if err != nil {
+			return err
+		}
+
+		side, err := promptBool("side")
+		if err != nil {
+			return err
+		}
+
+		// A price of 0 keeps the order's price and only reduces its size
+		price, err := promptAmount("price", consts.PriceDecimals)
+		if err != nil {
+			return err
+		}
+
+		// Confirm action
+		cont, err := promptContinue()
+		if !cont || err != nil {
+			return err
+		}
+
+		parser, err := tcli.Parser(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Generate transaction
+		submit, _, _, err := cli.GenerateTransaction(ctx, parser, nil, &actions.ReplaceOrder{
+			Pair: orderbook.Pair{
+				BaseTokenID: baseTokenID,
+				QuoteTokenID: quoteTokenID,
+			},
+			OrderID: orderID,
+			Quantity: quantity,
+			Side: side,
+			Price: price,
 		}, authFactory)
 		if err != nil {
 			return err
@@ -309,3 +530,352 @@ var cancelAllOrderCmd = &cobra.Command{
 		return nil
 	},
 }
+
+var createPairCmd = &cobra.Command{
+	Use: "create-pair",
+	RunE: func(*cobra.Command, []string) error {
+		ctx := context.Background()
+		_, _, authFactory, cli, tcli, err := defaultActor()
+		if err != nil {
+			return err
+		}
+		baseTokenID, quoteTokenID := getTokens()
+		if cmdc.GetPair {
+			baseTokenID, err = promptToken("base")
+			if err != nil {
+				return err
+			}
+
+			quoteTokenID, err = promptToken("quote")
+			if err != nil {
+				return err
+			}
+		}
+
+		tickSize, err := promptAmount("tick size", consts.PriceDecimals)
+		if err != nil {
+			return err
+		}
+
+		lotSize, err := promptAmount("lot size", consts.BalanceDecimals)
+		if err != nil {
+			return err
+		}
+
+		minNotional, err := promptAmount("min notional", consts.BalanceDecimals)
+		if err != nil {
+			return err
+		}
+
+		status := orderbook.PairActive
+		halted, err := promptBool("halted")
+		if err != nil {
+			return err
+		}
+		if halted {
+			status = orderbook.PairHalted
+		}
+
+		// Confirm action
+		cont, err := promptContinue()
+		if !cont || err != nil {
+			return err
+		}
+
+		parser, err := tcli.Parser(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Generate transaction
+		submit, _, _, err := cli.GenerateTransaction(ctx, parser, nil, &actions.CreatePair{
+			Pair: orderbook.Pair{
+				BaseTokenID: baseTokenID,
+				QuoteTokenID: quoteTokenID,
+			},
+			TickSize: tickSize,
+			LotSize: lotSize,
+			MinNotional: minNotional,
+			Status: status,
+		}, authFactory)
+		if err != nil {
+			return err
+		}
+		if err := submit(ctx); err != nil {
+			return err
+		}
+		return nil
+	},
+}
+
+var createTokenCmd = &cobra.Command{
+	Use: "create-token",
+	RunE: func(*cobra.Command, []string) error {
+		ctx := context.Background()
+		_, _, authFactory, cli, tcli, err := defaultActor()
+		if err != nil {
+			return err
+		}
+
+		symbol, err := promptString("symbol")
+		if err != nil {
+			return err
+		}
+
+		decimals, err := promptInt("decimals")
+		if err != nil {
+			return err
+		}
+
+		// 0 leaves the supply uncapped
+		maxSupply, err := promptAmount("max supply", consts.BalanceDecimals)
+		if err != nil {
+			return err
+		}
+
+		// Confirm action
+		cont, err := promptContinue()
+		if !cont || err != nil {
+			return err
+		}
+
+		parser, err := tcli.Parser(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Generate transaction
+		submit, tx, _, err := cli.GenerateTransaction(ctx, parser, nil, &actions.CreateToken{
+			Symbol: symbol,
+			Decimals: uint8(decimals),
+			MaxSupply: maxSupply,
+		}, authFactory)
+		if err != nil {
+			return err
+		}
+		if err := submit(ctx); err != nil {
+			return err
+		}
+		utils.Outf("{{yellow}}tokenID:{{/}} %s\n", tx.ID())
+		return nil
+	},
+}
+
+var mintTokenCmd = &cobra.Command{
+	Use: "mint-token",
+	RunE: func(*cobra.Command, []string) error {
+		ctx := context.Background()
+		_, _, authFactory, cli, tcli, err := defaultActor()
+		if err != nil {
+			return err
+		}
+		tokenID, err := promptToken("")
+		if err != nil {
+			return err
+		}
+
+		// Select recipient
+		recipient, err := promptAddress("recipient")
+		if err != nil {
+			return err
+		}
+
+		// Select amount
+		amount, err := promptAmount("amount", consts.BalanceDecimals)
+		if err != nil {
+			return err
+		}
+
+		// Confirm action
+		cont, err := promptContinue()
+		if !cont || err != nil {
+			return err
+		}
+
+		parser, err := tcli.Parser(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Generate transaction
+		submit, _, _, err := cli.GenerateTransaction(ctx, parser, nil, &actions.MintToken{
+			To: recipient,
+			TokenID: tokenID,
+			Amount: amount,
+		}, authFactory)
+		if err != nil {
+			return err
+		}
+		if err := submit(ctx); err != nil {
+			return err
+		}
+		return nil
+	},
+}
+
+var burnTokenCmd = &cobra.Command{
+	Use: "burn-token",
+	RunE: func(*cobra.Command, []string) error {
+		ctx := context.Background()
+		_, _, authFactory, cli, tcli, err := defaultActor()
+		if err != nil {
+			return err
+		}
+		tokenID, err := promptToken("")
+		if err != nil {
+			return err
+		}
+
+		// Select amount
+		amount, err := promptAmount("amount", consts.BalanceDecimals)
+		if err != nil {
+			return err
+		}
+
+		// Confirm action
+		cont, err := promptContinue()
+		if !cont || err != nil {
+			return err
+		}
+
+		parser, err := tcli.Parser(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Generate transaction
+		submit, _, _, err := cli.GenerateTransaction(ctx, parser, nil, &actions.BurnToken{
+			TokenID: tokenID,
+			Amount: amount,
+		}, authFactory)
+		if err != nil {
+			return err
+		}
+		if err := submit(ctx); err != nil {
+			return err
+		}
+		return nil
+	},
+}
+
+var claimFundsCmd = &cobra.Command{
+	Use: "claim-funds",
+	RunE: func(*cobra.Command, []string) error {
+		ctx := context.Background()
+		_, key, authFactory, cli, tcli, err := defaultActor()
+		if err != nil {
+			return err
+		}
+
+		// Claim every token with funds claimable by the next block
+		funds, blockHeight, err := tcli.AllPendingFunds(ctx, key.PublicKey())
+		if err != nil {
+			return err
+		}
+		var tokenIDs []ids.ID
+		seen := make(map[ids.ID]struct{})
+		for _, f := range funds {
+			if _, ok := seen[f.TokenID]; ok || f.ClaimableHeight > blockHeight+1 {
+				continue
+			}
+			seen[f.TokenID] = struct{}{}
+			tokenIDs = append(tokenIDs, f.TokenID)
+		}
+		if len(tokenIDs) == 0 {
+			utils.Outf("{{yellow}}nothing to claim{{/}}\n")
+			return nil
+		}
+		if len(tokenIDs) > consts.MaxClaimTokens {
+			tokenIDs = tokenIDs[:consts.MaxClaimTokens]
+		}
+		for _, tokenID := range tokenIDs {
+			utils.Outf("{{yellow}}claiming:{{/}} %s\n", tokenID)
+		}
+
+		// Confirm action
+		cont, err := promptContinue()
+		if !cont || err != nil {
+			return err
+		}
+
+		parser, err := tcli.Parser(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Generate transaction
+		submit, _, _, err := cli.GenerateTransaction(ctx, parser, nil, &actions.ClaimFunds{
+			TokenIDs: tokenIDs,
+		}, authFactory)
+		if err != nil {
+			return err
+		}
+		if err := submit(ctx); err != nil {
+			return err
+		}
+		return nil
+	},
+}
+
+var settleFundsCmd = &cobra.Command{
+	Use: "settle-funds",
+	RunE: func(*cobra.Command, []string) error {
+		ctx := context.Background()
+		_, _, authFactory, cli, tcli, err := defaultActor()
+		if err != nil {
+			return err
+		}
+
+		// Settle every user with funds claimable by the next block, one
+		// transaction per token and batch of users
+		funds, _, err := tcli.MaturedFunds(ctx)
+		if err != nil {
+			return err
+		}
+		var settlements []*actions.SettleFunds
+		byToken := make(map[ids.ID]*actions.SettleFunds)
+		for _, f := range funds {
+			user, err := crypto.ParseAddress(consts.HRP, f.User)
+			if err != nil {
+				return err
+			}
+			sf, ok := byToken[f.TokenID]
+			if !ok || len(sf.Users) == consts.MaxSettleUsers {
+				sf = &actions.SettleFunds{TokenID: f.TokenID}
+				byToken[f.TokenID] = sf
+				settlements = append(settlements, sf)
+			}
+			sf.Users = append(sf.Users, user)
+		}
+		if len(settlements) == 0 {
+			utils.Outf("{{yellow}}nothing to settle{{/}}\n")
+			return nil
+		}
+		for _, sf := range settlements {
+			utils.Outf("{{yellow}}settling:{{/}} %s {{yellow}}for{{/}} %d users\n", sf.TokenID, len(sf.Users))
+		}
+
+		// Confirm action
+		cont, err := promptContinue()
+		if !cont || err != nil {
+			return err
+		}
+
+		parser, err := tcli.Parser(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Generate transactions
+		for _, sf := range settlements {
+			submit, _, _, err := cli.GenerateTransaction(ctx, parser, nil, sf, authFactory)
+			if err != nil {
+				return err
+			}
+			if err := submit(ctx); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
@@ -23,8 +23,18 @@ func init() {
 		prometheusCmd,
 		spamCmd,
 		pendingFundsCmd,
+		allPendingFundsCmd,
 		volumesCmd,
 		midPriceCmd,
+		orderbookRootCmd,
+		openOrdersCmd,
+		orderStatusCmd,
+		tradesCmd,
+		candlesCmd,
+		marketDataCmd,
+		pairCmd,
+		tokenCmd,
+		feesCmd,
 	)
 
 	rootCmd.PersistentFlags().BoolVar(&consts.GetPair, "get-pair", false, "get pair from user input")
@@ -54,8 +64,16 @@ func init() {
 		transferCmd,
 		addOrderCmd,
 		cancelOrderCmd,
+		replaceOrderCmd,
 		marketOrderCmd,
+		stopOrderCmd,
 		cancelAllOrderCmd,
+		createPairCmd,
+		createTokenCmd,
+		mintTokenCmd,
+		burnTokenCmd,
+		claimFundsCmd,
+		settleFundsCmd,
 	)
 
 	spamCmd.AddCommand(
@@ -18,6 +18,7 @@ import (
 	"github.com/jaimi-io/clobvm/actions"
 	"github.com/jaimi-io/clobvm/auth"
 	"github.com/jaimi-io/clobvm/cmd/clob-cli/consts"
+	vconsts "github.com/jaimi-io/clobvm/consts"
 	"github.com/jaimi-io/clobvm/orderbook"
 	trpc "github.com/jaimi-io/clobvm/rpc"
 	"github.com/jaimi-io/clobvm/utils"
@@ -130,11 +131,8 @@ func simulateMarketOrderer(issuer *txIssuer, parser chain.Parser, factory *auth.
 	marketOrder.Add(1)
 }
 
-func cancelAllOrders(issuer *txIssuer, parser chain.Parser, factory *auth.E25519Factory, tm *timeModifier) {
-	_, tx, _, err := issuer.c.GenerateTransactionManual(parser, nil, &actions.CancelOrder{
-		Pair:     pair,
-		OrderID: ids.GenerateTestID(),
-	}, factory, 0, tm)
+func issueBatchOrders(issuer *txIssuer, parser chain.Parser, factory *auth.E25519Factory, tm *timeModifier, batch *actions.BatchOrders) {
+	_, tx, _, err := issuer.c.GenerateTransactionManual(parser, nil, batch, factory, 0, tm)
 	if err != nil {
 		hutils.Outf("{{orange}}failed to generate:{{/}} %v\n", err)
 		return
@@ -150,10 +148,13 @@ func cancelAllOrders(issuer *txIssuer, parser chain.Parser, factory *auth.E25519
 	sent.Add(1)
 }
 
+// simulateMarketMaker requotes in as few BatchOrders txs as possible, with
+// the cancel of the previous quotes leading the first batch.
 func simulateMarketMaker(issuer *txIssuer, parser chain.Parser, factory *auth.E25519Factory, tm *timeModifier, localMidPrice uint64, i int, mm *marketMaker) {
 	toUpdate := mm.UpdateParams(localMidPrice)
+	batch := &actions.BatchOrders{Pair: pair, BlockExpiryWindow: vconsts.EvictionBlockWindow}
 	if toUpdate {
-		cancelAllOrders(issuer, parser, factory, tm)
+		batch.Cancels = []ids.ID{ids.Empty}
 	}
 	for k := 0; k < 2 * numTransactions; k++ {
 		side := (k+i)%2 == 0
@@ -164,26 +165,18 @@ func simulateMarketMaker(issuer *txIssuer, parser chain.Parser, factory *auth.E2
 			price = mm.sellPrices[k/2]
 		}
 		mm.orderCounter += 1
-		_, tx, _, err := issuer.c.GenerateTransactionManual(parser, nil, &actions.AddOrder{
-			Pair:     pair,
+		batch.Adds = append(batch.Adds, actions.BatchAdd{
 			Quantity: marketMakerQuantity,
 			Price:    price,
 			Side:     side,
-			// ensure txs are unique
-		}, factory, 0, tm)
-		if err != nil {
-			hutils.Outf("{{orange}}failed to generate:{{/}} %v\n", err)
-			continue
+		})
+		if len(batch.Cancels)+len(batch.Adds) == vconsts.MaxBatchOrders {
+			issueBatchOrders(issuer, parser, factory, tm, batch)
+			batch = &actions.BatchOrders{Pair: pair, BlockExpiryWindow: vconsts.EvictionBlockWindow}
 		}
-		if err := issuer.d.RegisterTx(tx); err != nil {
-			hutils.Outf("{{orange}}failed to register:{{/}} %v\n", err)
-			continue
-		}
-		issuer.l.Lock()
-		issuer.outstandingTxs++
-		issuer.l.Unlock()
-		inflight.Add(1)
-		sent.Add(1)
+	}
+	if len(batch.Cancels)+len(batch.Adds) > 0 {
+		issueBatchOrders(issuer, parser, factory, tm, batch)
 	}
 }
 
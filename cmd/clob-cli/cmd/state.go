@@ -3,11 +3,15 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"time"
 
 	cmdc "github.com/jaimi-io/clobvm/cmd/clob-cli/consts"
 	"github.com/jaimi-io/clobvm/consts"
 	"github.com/jaimi-io/clobvm/orderbook"
+	crpc "github.com/jaimi-io/clobvm/rpc"
+	cutils "github.com/jaimi-io/clobvm/utils"
 	"github.com/jaimi-io/hypersdk/crypto"
+	"github.com/jaimi-io/hypersdk/pubsub"
 	"github.com/jaimi-io/hypersdk/utils"
 	"github.com/spf13/cobra"
 )
@@ -34,12 +38,14 @@ var balanceCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		bal, err := cli.Balance(ctx, crypto.Address("clob", addr), tokenID)
+		bal, pending, claimable, err := cli.Balance(ctx, crypto.Address("clob", addr), tokenID)
 		if err != nil {
 			return err
 		}
 		format := "balance: %." + fmt.Sprint(consts.BalanceDecimals) + "f\n"
 		fmt.Printf(format, bal)
+		format = "pending: %." + fmt.Sprint(consts.BalanceDecimals) + "f (claimable: %." + fmt.Sprint(consts.BalanceDecimals) + "f)\n"
+		fmt.Printf(format, pending, claimable)
 		return nil
 	},
 }
@@ -111,8 +117,10 @@ var allOrdersCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
-		fmt.Printf("sell side: %s\n", sellSide)
-		fmt.Printf("buy side: %s\n", buySide)
+		fmt.Println("sell side:")
+		printOrders(sellSide)
+		fmt.Println("buy side:")
+		printOrders(buySide)
 		return nil
 	},
 }
@@ -154,6 +162,37 @@ var pendingFundsCmd = &cobra.Command{
 	},
 }
 
+var allPendingFundsCmd = &cobra.Command{
+	Use: "all-pending",
+	RunE: func(*cobra.Command, []string) error {
+		ctx := context.Background()
+		_, key, _, _, cli, err := defaultActor()
+		if err != nil {
+			return err
+		}
+
+		addr := key.PublicKey()
+
+		if cmdc.GetAddress {
+			addr, err = promptAddress("address")
+			if err != nil {
+				return err
+			}
+		}
+
+		funds, blockHeight, err := cli.AllPendingFunds(ctx, addr)
+		if err != nil {
+			return err
+		}
+		format := "%s: %." + fmt.Sprint(consts.BalanceDecimals) + "f claimable at block height %d\n"
+		for _, f := range funds {
+			fmt.Printf(format, f.TokenID, f.Balance, f.ClaimableHeight)
+		}
+		fmt.Printf("at block height: %d\n", blockHeight)
+		return nil
+	},
+}
+
 var volumesCmd = &cobra.Command{
 	Use: "volumes",
 	RunE: func(*cobra.Command, []string) error {
@@ -183,11 +222,444 @@ var volumesCmd = &cobra.Command{
 			return err
 		}
 		
-		volumes, err := cli.Volumes(ctx, pair, numPriceLevels)
+		buySide, sellSide, err := cli.Volumes(ctx, pair, numPriceLevels)
+		if err != nil {
+			return err
+		}
+		printVolumes(buySide, sellSide)
+		return nil
+	},
+}
+
+var orderbookRootCmd = &cobra.Command{
+	Use: "orderbook-root",
+	RunE: func(*cobra.Command, []string) error {
+		ctx := context.Background()
+		chainID, _, _, _, cli, err := defaultActor()
+		if err != nil {
+			return err
+		}
+
+		blockHeight, err := promptInt("block height (0 for latest)")
+		if err != nil {
+			return err
+		}
+
+		// Resolve the height on the first node so every node is compared at
+		// the same block.
+		root, resBlockHeight, err := cli.OrderbookRoot(ctx, uint64(blockHeight))
+		if err != nil {
+			return err
+		}
+		uris := cmdc.URIS
+		if len(uris) == 0 {
+			uris = []string{cmdc.URI}
+		}
+		fmt.Printf("block height: %d\n", resBlockHeight)
+		for _, uri := range uris {
+			nodeRoot, _, err := crpc.NewRPCClient(uri, chainID).OrderbookRoot(ctx, resBlockHeight)
+			switch {
+			case err != nil:
+				utils.Outf("{{yellow}}%s{{/}} error: %v\n", uri, err)
+			case nodeRoot != root:
+				utils.Outf("{{red}}%s{{/}} root: %s\n", uri, nodeRoot)
+			default:
+				utils.Outf("{{green}}%s{{/}} root: %s\n", uri, nodeRoot)
+			}
+		}
+		return nil
+	},
+}
+var openOrdersCmd = &cobra.Command{
+	Use: "open-orders",
+	RunE: func(*cobra.Command, []string) error {
+		ctx := context.Background()
+		_, key, _, _, cli, err := defaultActor()
+		if err != nil {
+			return err
+		}
+
+		addr := key.PublicKey()
+
+		if cmdc.GetAddress {
+			addr, err = promptAddress("address")
+			if err != nil {
+				return err
+			}
+		}
+
+		baseTokenID, quoteTokenID := getTokens()
+		if cmdc.GetPair {
+			baseTokenID, err = promptToken("base")
+			if err != nil {
+				return err
+			}
+
+			quoteTokenID, err = promptToken("quote")
+			if err != nil {
+				return err
+			}
+		}
+
+		pair := orderbook.Pair{BaseTokenID: baseTokenID, QuoteTokenID: quoteTokenID}
+
+		orders, err := cli.OpenOrders(ctx, crypto.Address("clob", addr), pair)
+		if err != nil {
+			return err
+		}
+		for _, order := range orders {
+			printOrderStatus(order)
+		}
+		return nil
+	},
+}
+
+var orderStatusCmd = &cobra.Command{
+	Use: "order-status",
+	RunE: func(*cobra.Command, []string) error {
+		ctx := context.Background()
+		_, _, _, _, cli, err := defaultActor()
+		if err != nil {
+			return err
+		}
+
+		orderID, err := promptID("orderID")
+		if err != nil {
+			return err
+		}
+
+		order, err := cli.OrderStatus(ctx, orderID)
+		if err != nil {
+			return err
+		}
+		printOrderStatus(order)
+		return nil
+	},
+}
+
+func printOrderStatus(order crpc.OrderStatus) {
+	side := "sell"
+	if order.Side {
+		side = "buy"
+	}
+	format := "%s %s %s P: %." + fmt.Sprint(consts.PriceDecimals) + "f Q: %." + fmt.Sprint(consts.QuantityDecimals) + "f/%." + fmt.Sprint(consts.QuantityDecimals) + "f fee: %dbps expiry: %d\n"
+	fmt.Printf(format, order.ID, order.Status, side, order.Price, order.Quantity, order.OriginalQuantity, order.FeeRate, order.BlockExpiry)
+}
+
+var tradesCmd = &cobra.Command{
+	Use: "trades",
+	RunE: func(*cobra.Command, []string) error {
+		ctx := context.Background()
+		_, _, _, _, cli, err := defaultActor()
+		if err != nil {
+			return err
+		}
+
+		baseTokenID, quoteTokenID := getTokens()
+		if cmdc.GetPair {
+			baseTokenID, err = promptToken("base")
+			if err != nil {
+				return err
+			}
+
+			quoteTokenID, err = promptToken("quote")
+			if err != nil {
+				return err
+			}
+		}
+
+		// Trades of every user unless an address is asked for
+		var address string
+		if cmdc.GetAddress {
+			addr, err := promptAddress("address")
+			if err != nil {
+				return err
+			}
+			address = crypto.Address("clob", addr)
+		}
+
+		startHeight, err := promptInt("start block height")
+		if err != nil {
+			return err
+		}
+
+		limit, err := promptInt("max trades")
 		if err != nil {
 			return err
 		}
-		utils.Outf(volumes)
+
+		pair := orderbook.Pair{BaseTokenID: baseTokenID, QuoteTokenID: quoteTokenID}
+
+		trades, next, err := cli.Trades(ctx, pair, address, crpc.TradeCursor{BlockHeight: uint64(startHeight)}, limit)
+		if err != nil {
+			return err
+		}
+		format := "%d/%d %s P: %." + fmt.Sprint(consts.PriceDecimals) + "f Q: %." + fmt.Sprint(consts.QuantityDecimals) + "f maker: %s taker: %s\n"
+		for _, trade := range trades {
+			side := "sell"
+			if trade.TakerSide {
+				side = "buy"
+			}
+			fmt.Printf(format, trade.BlockHeight, trade.Index, side, trade.Price, trade.Quantity, trade.MakerOrderID, trade.TakerOrderID)
+		}
+		if next != nil {
+			fmt.Printf("more trades from: %d/%d\n", next.BlockHeight, next.Index)
+		}
 		return nil
 	},
-}
\ No newline at end of file
+}
+
+var candlesCmd = &cobra.Command{
+	Use: "candles",
+	RunE: func(*cobra.Command, []string) error {
+		ctx := context.Background()
+		_, _, _, _, cli, err := defaultActor()
+		if err != nil {
+			return err
+		}
+
+		baseTokenID, quoteTokenID := getTokens()
+		if cmdc.GetPair {
+			baseTokenID, err = promptToken("base")
+			if err != nil {
+				return err
+			}
+
+			quoteTokenID, err = promptToken("quote")
+			if err != nil {
+				return err
+			}
+		}
+
+		// 60 = 1m, 300 = 5m, 3600 = 1h, 86400 = 1d
+		interval, err := promptInt("interval seconds")
+		if err != nil {
+			return err
+		}
+
+		// minutes of history to show
+		lookback, err := promptInt("lookback minutes")
+		if err != nil {
+			return err
+		}
+
+		pair := orderbook.Pair{BaseTokenID: baseTokenID, QuoteTokenID: quoteTokenID}
+		start := time.Now().Unix() - int64(lookback)*60
+
+		candles, err := cli.Candles(ctx, pair, int64(interval), start, 0)
+		if err != nil {
+			return err
+		}
+		price := "%." + fmt.Sprint(consts.PriceDecimals) + "f"
+		format := "%s O: " + price + " H: " + price + " L: " + price + " C: " + price + " V: %." + fmt.Sprint(consts.QuantityDecimals) + "f (%d)\n"
+		for _, c := range candles {
+			start := time.Unix(c.Start, 0).UTC().Format(time.RFC3339)
+			fmt.Printf(format, start, c.Open, c.High, c.Low, c.Close, c.Volume, c.NumTrades)
+		}
+		return nil
+	},
+}
+
+var marketDataCmd = &cobra.Command{
+	Use: "market-data",
+	RunE: func(*cobra.Command, []string) error {
+		ctx := context.Background()
+		baseTokenID, quoteTokenID := getTokens()
+		if cmdc.GetPair {
+			var err error
+			baseTokenID, err = promptToken("base")
+			if err != nil {
+				return err
+			}
+
+			quoteTokenID, err = promptToken("quote")
+			if err != nil {
+				return err
+			}
+		}
+		pair := orderbook.Pair{BaseTokenID: baseTokenID, QuoteTokenID: quoteTokenID}
+
+		mcli, err := crpc.NewMarketDataClient(cmdc.URI, 8_192, pubsub.MaxReadMessageSize)
+		if err != nil {
+			return err
+		}
+		defer mcli.Close()
+		if err := mcli.Subscribe(pair); err != nil {
+			return err
+		}
+
+		price := "%." + fmt.Sprint(consts.PriceDecimals) + "f"
+		quantity := "%." + fmt.Sprint(consts.QuantityDecimals) + "f"
+		for {
+			snapshot, update, err := mcli.Listen(ctx)
+			if err != nil {
+				return err
+			}
+			if snapshot != nil {
+				utils.Outf("{{yellow}}snapshot{{/}} seq: %d height: %d\n", snapshot.Sequence, snapshot.BlockHeight)
+				printLevels(snapshot.Levels)
+				continue
+			}
+			utils.Outf("{{yellow}}update{{/}} seq: %d height: %d\n", update.Sequence, update.BlockHeight)
+			printLevels(update.Levels)
+			for _, trade := range update.Trades {
+				side := "sell"
+				if trade.TakerSide {
+					side = "buy"
+				}
+				fmt.Printf("trade %s P: "+price+" Q: "+quantity+"\n", side, cutils.DisplayPrice(trade.Price), cutils.DisplayQuantity(trade.Quantity))
+			}
+			for _, order := range update.Orders {
+				fmt.Printf("order %s %s\n", order.ID, order.Status)
+			}
+		}
+	},
+}
+
+var pairCmd = &cobra.Command{
+	Use: "pair",
+	RunE: func(*cobra.Command, []string) error {
+		ctx := context.Background()
+		_, _, _, _, cli, err := defaultActor()
+		if err != nil {
+			return err
+		}
+
+		baseTokenID, quoteTokenID := getTokens()
+		if cmdc.GetPair {
+			baseTokenID, err = promptToken("base")
+			if err != nil {
+				return err
+			}
+
+			quoteTokenID, err = promptToken("quote")
+			if err != nil {
+				return err
+			}
+		}
+
+		info, err := cli.Pair(ctx, orderbook.Pair{BaseTokenID: baseTokenID, QuoteTokenID: quoteTokenID})
+		if err != nil {
+			return err
+		}
+		if !info.Listed {
+			utils.Outf("{{red}}pair is not listed{{/}}\n")
+			return nil
+		}
+		utils.Outf("{{yellow}}status:{{/}} %s {{yellow}}creator:{{/}} %s\n", info.Status, info.Creator)
+		utils.Outf(
+			"{{yellow}}tick size:{{/}} %."+fmt.Sprint(consts.PriceDecimals)+"f {{yellow}}lot size:{{/}} %."+fmt.Sprint(consts.BalanceDecimals)+"f {{yellow}}min notional:{{/}} %."+fmt.Sprint(consts.BalanceDecimals)+"f\n",
+			info.TickSize,
+			info.LotSize,
+			info.MinNotional,
+		)
+		return nil
+	},
+}
+
+var feesCmd = &cobra.Command{
+	Use: "fees",
+	RunE: func(*cobra.Command, []string) error {
+		ctx := context.Background()
+		_, _, _, _, cli, err := defaultActor()
+		if err != nil {
+			return err
+		}
+
+		baseTokenID, quoteTokenID := getTokens()
+		if cmdc.GetPair {
+			baseTokenID, err = promptToken("base")
+			if err != nil {
+				return err
+			}
+
+			quoteTokenID, err = promptToken("quote")
+			if err != nil {
+				return err
+			}
+		}
+
+		fees, err := cli.Fees(ctx, orderbook.Pair{BaseTokenID: baseTokenID, QuoteTokenID: quoteTokenID})
+		if err != nil {
+			return err
+		}
+		if len(fees.Recipient) == 0 {
+			utils.Outf("{{yellow}}recipient:{{/}} none (fees are burned)\n")
+		} else {
+			utils.Outf("{{yellow}}recipient:{{/}} %s\n", fees.Recipient)
+		}
+		utils.Outf(
+			"{{yellow}}base collected:{{/}} %."+fmt.Sprint(consts.BalanceDecimals)+"f {{yellow}}quote collected:{{/}} %."+fmt.Sprint(consts.BalanceDecimals)+"f\n",
+			fees.Base,
+			fees.Quote,
+		)
+		return nil
+	},
+}
+
+var tokenCmd = &cobra.Command{
+	Use: "token",
+	RunE: func(*cobra.Command, []string) error {
+		ctx := context.Background()
+		_, _, _, _, cli, err := defaultActor()
+		if err != nil {
+			return err
+		}
+
+		tokenID, err := promptToken("")
+		if err != nil {
+			return err
+		}
+
+		info, err := cli.Token(ctx, tokenID)
+		if err != nil {
+			return err
+		}
+		if !info.Exists {
+			utils.Outf("{{red}}token does not exist{{/}}\n")
+			return nil
+		}
+		utils.Outf("{{yellow}}symbol:{{/}} %s {{yellow}}decimals:{{/}} %d {{yellow}}owner:{{/}} %s\n", info.Symbol, info.Decimals, info.Owner)
+		utils.Outf(
+			"{{yellow}}supply:{{/}} %."+fmt.Sprint(consts.BalanceDecimals)+"f {{yellow}}max supply:{{/}} %."+fmt.Sprint(consts.BalanceDecimals)+"f\n",
+			info.Supply,
+			info.MaxSupply,
+		)
+		return nil
+	},
+}
+
+func printOrders(levels []crpc.PriceLevel) {
+	format := "%." + fmt.Sprint(consts.PriceDecimals) + "f: %s %." + fmt.Sprint(consts.QuantityDecimals) + "f\n"
+	for _, level := range levels {
+		for _, order := range level.Orders {
+			fmt.Printf(format, level.Price, order.ID, order.Quantity)
+		}
+	}
+}
+
+// printVolumes prints the sell side above the buy side, with the best prices
+// of each side meeting in the middle.
+func printVolumes(buySide []crpc.PriceLevel, sellSide []crpc.PriceLevel) {
+	format := "%." + fmt.Sprint(consts.PriceDecimals) + "f : %." + fmt.Sprint(consts.QuantityDecimals) + "f (%d)"
+	for i := len(sellSide) - 1; i >= 0; i-- {
+		level := sellSide[i]
+		utils.Outf("{{red}}"+format+"{{/}}\n", level.Price, level.Quantity, level.NumOrders)
+	}
+	for _, level := range buySide {
+		utils.Outf("{{green}}"+format+"{{/}}\n", level.Price, level.Quantity, level.NumOrders)
+	}
+}
+
+// printLevels prints streamed levels, a zero quantity meaning the level was
+// removed.
+func printLevels(levels []orderbook.Level) {
+	format := "%." + fmt.Sprint(consts.PriceDecimals) + "f : %." + fmt.Sprint(consts.QuantityDecimals) + "f"
+	for _, level := range levels {
+		colour := "{{red}}"
+		if level.Side {
+			colour = "{{green}}"
+		}
+		utils.Outf(colour+format+"{{/}}\n", cutils.DisplayPrice(level.Price), cutils.DisplayQuantity(level.Quantity))
+	}
+}
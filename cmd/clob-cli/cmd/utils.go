@@ -14,6 +14,7 @@ import (
 	"github.com/jaimi-io/clobvm/actions"
 	"github.com/jaimi-io/clobvm/auth"
 	"github.com/jaimi-io/clobvm/cmd/clob-cli/consts"
+	vconsts "github.com/jaimi-io/clobvm/consts"
 	crpc "github.com/jaimi-io/clobvm/rpc"
 	trpc "github.com/jaimi-io/clobvm/rpc"
 	"github.com/jaimi-io/hypersdk/chain"
@@ -109,6 +110,10 @@ func promptToken(label string) (ids.ID, error) {
 		return ids.Empty, err
 	}
 	token = strings.TrimSpace(token)
+	// Tokens created on-chain are named by their ID
+	if tokenID, err := ids.FromString(token); err == nil {
+		return tokenID, nil
+	}
 	c := make([]byte, 32)
 	copy(c, []byte(token))
 	tokenID, err := ids.ToID(c)
@@ -291,6 +296,22 @@ func promptOptional(
 	return strconv.Atoi(rawAmount)
 }
 
+// promptMarketLimit asks a market order, one without a [price], for the worst
+// price it fills at or failing that its max slippage.
+func promptMarketLimit(price uint64) (uint64, uint64, error) {
+	if price > 0 {
+		return 0, 0, nil
+	}
+	// 0 limits the order by max slippage instead
+	worstPrice, err := promptAmount("worst price", vconsts.PriceDecimals)
+	if err != nil || worstPrice > 0 {
+		return worstPrice, 0, err
+	}
+	// basis points, empty for the default of 10%
+	maxSlippage, err := promptOptional("maxSlippage")
+	return 0, uint64(maxSlippage), err
+}
+
 func getTokens() (ids.ID, ids.ID) {
 	avaxID, _ := ids.FromString("VmwmdfVNQLiP1zJWmhaHipksKBAHmDZH5rZvdfCQfQ9peNx8a")
 	usdcID, _ := ids.FromString("eaX7nEYVKiiFLEvRYQWmHixL9nwC1jFxsa1R75ipEchWBMKiG")
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"math"
+	"os"
+	"path"
+
+	ametrics "github.com/ava-labs/avalanchego/api/metrics"
+	"github.com/jaimi-io/clobvm/controller"
+	"github.com/jaimi-io/clobvm/genesis"
+	"github.com/jaimi-io/clobvm/metrics"
+	"github.com/jaimi-io/clobvm/orderbook"
+	"github.com/jaimi-io/clobvm/storage"
+	"github.com/jaimi-io/clobvm/utils"
+	"github.com/jaimi-io/hypersdk/codec"
+	"github.com/jaimi-io/hypersdk/pebble"
+	hutils "github.com/jaimi-io/hypersdk/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	chainDataDir string
+	genesisFile  string
+	replayFrom   uint64
+	replayTo     uint64
+)
+
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Rebuild the orderbook from accepted blocks in a chain data dir",
+	RunE:  replayFunc,
+}
+
+func init() {
+	replayCmd.Flags().StringVar(&chainDataDir, "chain-data-dir", "", "chain data directory of a stopped node")
+	replayCmd.Flags().StringVar(&genesisFile, "genesis-file", "", "genesis file of the chain (default genesis if empty)")
+	replayCmd.Flags().Uint64Var(&replayFrom, "from", 0, "height of the snapshot to start from (0 for genesis)")
+	replayCmd.Flags().Uint64Var(&replayTo, "to", 0, "height to stop at (0 for last stored block)")
+	_ = replayCmd.MarkFlagRequired("chain-data-dir")
+	rootCmd.AddCommand(replayCmd)
+}
+
+func packManager(obm *orderbook.OrderbookManager) []byte {
+	p := codec.NewWriter(math.MaxInt)
+	obm.Marshal(p)
+	return p.Bytes()
+}
+
+func replayFunc(*cobra.Command, []string) error {
+	var genesisBytes []byte
+	if len(genesisFile) > 0 {
+		var err error
+		genesisBytes, err = os.ReadFile(genesisFile)
+		if err != nil {
+			return err
+		}
+	}
+	g, err := genesis.New(genesisBytes, nil)
+	if err != nil {
+		return err
+	}
+	fees := controller.FeeSchedule(g.GetRules())
+	pendingWindow := controller.PendingWindow(g.GetRules())
+
+	cfg := pebble.NewDefaultConfig()
+	blockDB, err := pebble.New(path.Join(chainDataDir, "block"), cfg)
+	if err != nil {
+		return err
+	}
+	defer blockDB.Close()
+	orderbookDB, err := pebble.New(path.Join(chainDataDir, "orderbook"), cfg)
+	if err != nil {
+		return err
+	}
+	defer orderbookDB.Close()
+
+	m, err := metrics.NewMetrics(ametrics.NewMultiGatherer())
+	if err != nil {
+		return err
+	}
+	obm, blockHeight, err := controller.Replay(context.Background(), blockDB, orderbookDB, fees, pendingWindow, replayFrom, replayTo, m)
+	if err != nil {
+		return err
+	}
+	hutils.Outf("{{yellow}}replayed blocks:{{/}} %d..%d\n", replayFrom+1, blockHeight)
+	root := obm.Root()
+	hutils.Outf("{{yellow}}orderbook root:{{/}} %s\n", root)
+	if storedRoot, err := storage.GetRoot(orderbookDB, blockHeight); err == nil && storedRoot != root {
+		hutils.Outf("{{red}}replayed root diverges from stored root %s{{/}}\n", storedRoot)
+	}
+	for _, pair := range obm.Pairs() {
+		ob := obm.GetOrderbook(pair)
+		hutils.Outf(
+			"{{cyan}}%s/%s{{/}} orders: %d mid price: %f\n",
+			pair.BaseTokenID,
+			pair.QuoteTokenID,
+			ob.Len(),
+			utils.DisplayPrice(ob.GetMidPrice()),
+		)
+	}
+
+	checkpoint, checkpointHeight, err := storage.GetCheckpoint(orderbookDB, fees, pendingWindow)
+	if err != nil {
+		return err
+	}
+	switch {
+	case checkpoint == nil:
+		hutils.Outf("{{yellow}}no checkpoint to compare against{{/}}\n")
+	case checkpointHeight != blockHeight:
+		hutils.Outf("{{yellow}}checkpoint is at height %d, skipping comparison{{/}}\n", checkpointHeight)
+	case bytes.Equal(packManager(checkpoint), packManager(obm)):
+		hutils.Outf("{{green}}replayed orderbook matches checkpoint at height %d{{/}}\n", blockHeight)
+	default:
+		hutils.Outf("{{red}}replayed orderbook diverges from checkpoint at height %d{{/}}\n", blockHeight)
+	}
+	return nil
+}
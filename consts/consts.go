@@ -3,18 +3,32 @@ package consts
 import "time"
 
 const (
-	EvictionBlockWindow = uint64(1000)
-	PendingBlockWindow  = uint64(7)
-	ExecHistoryWindow   = 100 // s
+	EvictionBlockWindow   = uint64(1000)
+	PendingBlockWindow    = uint64(7)
+	MaxPendingBlockWindow = uint64(1_000)
+	SnapshotBlockInterval = uint64(1024)
+	MaxBatchOrders        = 32
+	MaxClaimTokens        = 32
+	MaxSettleUsers        = 64
+	MaxTradesPageSize     = 1_000
+	MaxCandlesPageSize    = 1_000
+	MaxSymbolSize         = 8
+	ExecHistoryWindow     = 100 // s
 
 	BalanceDecimals  = 9
 	QuantityDecimals = 5
 	PriceDecimals    = 4
+	BasisPoints      = uint64(10_000)
+
+	DefaultMaxSlippage = uint64(1_000) // bps, 10%
+
+	NativeTokenSymbol = "AVAX" // genesis token fees of token actions are paid in
 
 	Day                     = time.Hour * 24
 	NumExecutionHistoryDays = 30
 
 	JSONRPCEndpoint = "/clobapi"
+	MarketDataEndpoint = "/clobws"
 	Name            = "clobvm"
 	HRP						  = "clob"
 )
\ No newline at end of file
@@ -2,22 +2,22 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	ametrics "github.com/ava-labs/avalanchego/api/metrics"
 	"github.com/ava-labs/avalanchego/database"
-	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/snow"
 	"github.com/ava-labs/avalanchego/snow/engine/common"
 	"github.com/ava-labs/avalanchego/trace"
 	"github.com/ava-labs/avalanchego/version"
-	"github.com/jaimi-io/clobvm/actions"
 	"github.com/jaimi-io/clobvm/consts"
 	"github.com/jaimi-io/clobvm/genesis"
 	"github.com/jaimi-io/clobvm/metrics"
 	"github.com/jaimi-io/clobvm/orderbook"
 	"github.com/jaimi-io/clobvm/registry"
 	"github.com/jaimi-io/clobvm/rpc"
+	"github.com/jaimi-io/clobvm/storage"
 	"github.com/jaimi-io/hypersdk/config"
 	"github.com/jaimi-io/hypersdk/crypto"
 
@@ -29,11 +29,14 @@ import (
 	hrpc "github.com/jaimi-io/hypersdk/rpc"
 	"github.com/jaimi-io/hypersdk/utils"
 	"github.com/jaimi-io/hypersdk/vm"
+	"go.uber.org/zap"
 )
 
 type Controller struct {
 	inner *vm.VM
 	orderbookManager *orderbook.OrderbookManager
+	orderbookDB database.Database
+	marketData *rpc.MarketDataServer
 
 	metrics *metrics.Metrics
 
@@ -81,7 +84,6 @@ func (c *Controller) Initialize(
 	if err != nil {
 		return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
-	c.orderbookManager = orderbook.NewOrderbookManager()
 	bcfg := builder.DefaultTimeConfig()
 	bcfg.PreferredBlocksPerSecond = 3
 	build := builder.NewTime(inner, bcfg)
@@ -110,6 +112,36 @@ func (c *Controller) Initialize(
 	if err != nil {
 		return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
+	orderbookPath, err := utils.InitSubDirectory(snowCtx.ChainDataDir, "orderbook")
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+	c.orderbookDB, err = pebble.New(orderbookPath, cfg)
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+	fees := FeeSchedule(c.genesis.GetRules())
+	pendingWindow := PendingWindow(c.genesis.GetRules())
+	obm, checkpointHeight, err := storage.GetCheckpoint(c.orderbookDB, fees, pendingWindow)
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+	if obm != nil {
+		c.orderbookManager = obm
+		inner.Logger().Info("restored orderbook checkpoint", zap.Uint64("height", checkpointHeight))
+	} else {
+		c.orderbookManager = orderbook.NewOrderbookManager(fees, pendingWindow)
+	}
+	// Blocks accepted after the checkpoint, e.g. right before a crash, must
+	// be applied before the book can serve or verify anything
+	catchUpHeight, err := CatchUp(context.Background(), c.orderbookManager, blockDB, c.orderbookDB, checkpointHeight, c.metrics)
+	if err != nil {
+		return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, err
+	}
+	if catchUpHeight > checkpointHeight {
+		inner.Logger().Info("caught up orderbook", zap.Uint64("from", checkpointHeight), zap.Uint64("to", catchUpHeight))
+		checkpointHeight = catchUpHeight
+	}
 	apis := map[string]*common.HTTPHandler{}
 	jsonRPCHandler, err := hrpc.NewJSONRPCHandler(
 		"clobvm",
@@ -120,6 +152,13 @@ func (c *Controller) Initialize(
 		return nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, err
 	}
 	apis[consts.JSONRPCEndpoint] = jsonRPCHandler
+	listed := func(pair orderbook.Pair) bool {
+		info, err := storage.GetPairFromState(context.Background(), inner.ReadState, pair)
+		return err == nil && info != nil
+	}
+	marketData, pubsubServer := rpc.NewMarketDataServer(inner.Logger(), c.orderbookManager.Levels(), checkpointHeight, c.config.GetStreamingBacklogSize(), listed)
+	c.marketData = marketData
+	apis[consts.MarketDataEndpoint] = hrpc.NewWebSocketHandler(pubsubServer)
 	inner.Logger().Info("Returning from controller.Initialize")
 	return c.config, c.genesis, build, gossip, blockDB, stateDB, apis, registry.ActionRegistry, registry.AuthRegistry, c.orderbookManager, err
 }
@@ -128,62 +167,90 @@ func (c *Controller) Rules(t int64) chain.Rules {
 	return c.genesis.GetRules()
 }
 
+// FeeSchedule reads the fee schedule of the chain from [r].
+func FeeSchedule(r chain.Rules) *orderbook.FeeSchedule {
+	fees := orderbook.DefaultFeeSchedule()
+	if v, ok := r.FetchCustom(genesis.FeeTiersKey); ok {
+		fees.Tiers = v.([]orderbook.Fee)
+	}
+	if v, ok := r.FetchCustom(genesis.ExecutionHistoryDaysKey); ok {
+		fees.HistoryDays = v.(int)
+	}
+	if v, ok := r.FetchCustom(genesis.FeeTokenPolicyKey); ok {
+		fees.Policy = v.(orderbook.FeePolicy)
+	}
+	if v, ok := r.FetchCustom(genesis.FeeRecipientKey); ok {
+		fees.Recipient = v.(crypto.PublicKey)
+	}
+	return fees
+}
+
+// PendingWindow reads how many blocks fills and refunds stay pending from [r].
+func PendingWindow(r chain.Rules) uint64 {
+	if v, ok := r.FetchCustom(genesis.PendingBlockWindowKey); ok {
+		return v.(uint64)
+	}
+	return consts.PendingBlockWindow
+}
+
 func (c *Controller) StateManager() chain.StateManager {
 	return c.stateManager
 }
 
 func (c *Controller) Accepted(ctx context.Context, blk *chain.StatelessBlock) error {
 	start := time.Now()
-	results := blk.Results()
-	var pendingAmounts []orderbook.PendingAmt
-	pendingAmtPtr := &pendingAmounts
-
-	c.orderbookManager.EvictAllPairs(blk.Hght, pendingAmtPtr, c.metrics)
-
-	for i, tx := range blk.Txs {
-		result := results[i]
-		if result.Success {
-			addr := tx.Auth.PublicKey()
-			switch action := tx.Action.(type) {
-			case *actions.AddOrder:
-				c.metrics.AddOrder()
-				order := orderbook.NewOrder(tx.ID(), addr, action.Price, action.Quantity, action.Side, blk.Hght, action.BlockExpiryWindow)
-				ob := c.orderbookManager.GetOrderbook(action.Pair)
-				ob.Add(order, blk.Hght, blk.Tmstmp, pendingAmtPtr, c.metrics)
-			case *actions.CancelOrder:
-				c.metrics.CancelOrder()
-				orderbook := c.orderbookManager.GetOrderbook(action.Pair)
-				if action.OrderID == ids.Empty {
-					orderbook.CancelAll(addr, pendingAmtPtr, c.metrics)
-				} else {
-					order := orderbook.Get(action.OrderID)
-					if order != nil || order.User == addr {
-						orderbook.Cancel(order, pendingAmtPtr, c.metrics)
-					}
-				}
-			case *actions.Transfer:
-				c.metrics.Transfer()
-			}
-		}
+	c.orderbookManager.Lock()
+	defer c.orderbookManager.Unlock()
+	// Applying a block that does not follow the book would store a root and
+	// checkpoint for a book that has diverged from the chain
+	if last := c.orderbookManager.GetLastBlockHeight(); blk.Hght != last+1 {
+		return fmt.Errorf("%w: accepted block %d does not follow orderbook height %d, resync the node", ErrOrderbookBehind, blk.Hght, last)
 	}
+	events := ApplyBlock(c.orderbookManager, c.metrics, blk.StatefulBlock, blk.Results())
+	if err := storeBlock(c.orderbookDB, c.orderbookManager, blk.Hght, blk.Results(), events); err != nil {
+		return err
+	}
+	if err := c.marketData.AcceptBlock(blk.Hght, blk.Tmstmp, events.Levels, events.Trades, events.ClosedOrders); err != nil {
+		return err
+	}
+	c.metrics.ObserverOrderProcessing(time.Since(start))
+	return nil
+}
 
-	fundsPerUser := make(map[crypto.PublicKey]map[ids.ID]uint64)
-	for _, pendingAmt := range pendingAmounts {
-		if _, ok := fundsPerUser[pendingAmt.User]; !ok {
-			fundsPerUser[pendingAmt.User] = make(map[ids.ID]uint64)
+// storeBlock records the events, results and root of the block at [height].
+// [obm] is only checkpointed every SnapshotBlockInterval blocks; on restart
+// the blocks after the checkpoint are caught up from their stored results.
+func storeBlock(db database.Database, obm *orderbook.OrderbookManager, height uint64, results []*chain.Result, events *BlockEvents) error {
+	batch := db.NewBatch()
+	for _, order := range events.ClosedOrders {
+		if err := storage.StoreClosedOrder(batch, order.Order); err != nil {
+			return err
 		}
-		fundsPerUser[pendingAmt.User][pendingAmt.TokenID] += pendingAmt.Amount
 	}
-
-	for user, tokenBalances := range fundsPerUser {
-		for tokenID, balance := range tokenBalances {
-			c.orderbookManager.AddPendingFunds(user, tokenID, balance, blk.Hght)
+	if err := storage.StoreTrades(batch, events.Trades); err != nil {
+		return err
+	}
+	if err := storage.StoreCandles(db, batch, events.Trades); err != nil {
+		return err
+	}
+	if err := storage.StoreFees(db, batch, events.Fees); err != nil {
+		return err
+	}
+	if err := storage.StoreResults(batch, height, results); err != nil {
+		return err
+	}
+	if err := storage.StoreRoot(batch, height, obm.Root()); err != nil {
+		return err
+	}
+	if height%consts.SnapshotBlockInterval == 0 {
+		if err := storage.StoreSnapshot(batch, obm, height); err != nil {
+			return err
+		}
+		if err := storage.StoreCheckpoint(batch, obm, height); err != nil {
+			return err
 		}
 	}
-	c.orderbookManager.UpdateAllMidPrices(blk.Hght)
-	c.orderbookManager.UpdateLastBlockHeight(blk.Hght)
-	c.metrics.ObserverOrderProcessing(time.Since(start))
-	return nil
+	return batch.Write()
 }
 
 func (c *Controller) Rejected(ctx context.Context, blk *chain.StatelessBlock) error {
@@ -191,7 +258,7 @@ func (c *Controller) Rejected(ctx context.Context, blk *chain.StatelessBlock) er
 }
 
 func (c *Controller) Shutdown(context.Context) error {
-	return nil
+	return c.orderbookDB.Close()
 }
 
 func (c *Controller) Tracer() trace.Tracer {
@@ -0,0 +1,52 @@
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/jaimi-io/clobvm/actions"
+	"github.com/jaimi-io/clobvm/orderbook"
+	"github.com/jaimi-io/clobvm/storage"
+	"github.com/jaimi-io/hypersdk/chain"
+	"github.com/jaimi-io/hypersdk/crypto"
+)
+
+func TestReplaceWithoutPriceLocksNothing(t *testing.T) {
+	h := newChainHarness(t)
+	pair := orderbook.Pair{BaseTokenID: ids.GenerateTestID(), QuoteTokenID: ids.GenerateTestID()}
+	key, err := crypto.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.list(pair, []crypto.PrivateKey{key})
+	user := key.PublicKey()
+
+	add := h.tx(key, &actions.AddOrder{Pair: pair, Quantity: 50_000, Side: true, Price: 10_000, BlockExpiryWindow: 20})
+	h.accept([]*chain.Transaction{add})
+
+	ctx := context.Background()
+	_, before, err := storage.GetBalance(ctx, h.state, user, pair.QuoteTokenID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	replace := &actions.ReplaceOrder{Pair: pair, OrderID: add.ID(), Quantity: 30_000, Side: true}
+	tx := h.tx(key, replace)
+	result, ts := h.executeScoped(tx)
+	if !result.Success {
+		t.Fatalf("replacement failed: %s", result.Output)
+	}
+	_, after, err := storage.GetBalance(ctx, ts, user, pair.QuoteTokenID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee := replace.Fee(0, h.height+1, nil, h.live); after != before-fee {
+		t.Fatalf("balance %d, want %d less only the fee of %d", after, before, fee)
+	}
+
+	h.accept([]*chain.Transaction{tx})
+	order := h.live.ViewOrderbook(pair).Get(add.ID())
+	if order == nil || order.Quantity != 3 {
+		t.Fatalf("order %v, want it resting with 3", order)
+	}
+}
@@ -0,0 +1,293 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/jaimi-io/clobvm/actions"
+	"github.com/jaimi-io/clobvm/consts"
+	"github.com/jaimi-io/clobvm/genesis"
+	"github.com/jaimi-io/clobvm/metrics"
+	"github.com/jaimi-io/clobvm/orderbook"
+	"github.com/jaimi-io/clobvm/registry"
+	"github.com/jaimi-io/clobvm/storage"
+	"github.com/jaimi-io/clobvm/utils"
+	"github.com/jaimi-io/hypersdk/chain"
+	"github.com/jaimi-io/hypersdk/crypto"
+	"github.com/jaimi-io/hypersdk/vm"
+)
+
+var ErrOrderbookBehind = errors.New("orderbook is behind the chain")
+
+// BlockEvents are what happened to the books while applying one block.
+type BlockEvents struct {
+	ClosedOrders []*orderbook.ClosedOrder
+	Trades       []*orderbook.Trade
+	Fees         []*orderbook.PairFees
+	Levels       map[orderbook.Pair][]orderbook.Level // levels touched, see TakeLevelChanges
+}
+
+// ApplyBlock applies the orderbook effects of an accepted block to [obm]. It
+// is the only place book state is mutated, so replaying the same blocks and
+// results from the same starting state yields the same manager. Actions only
+// read [obm]: pending funds are kept as running totals here, and how much of
+// them each user has pulled is kept in state by storage.PullPendingBalance.
+func ApplyBlock(obm *orderbook.OrderbookManager, m *metrics.Metrics, blk *chain.StatefulBlock, results []*chain.Result) *BlockEvents {
+	var pendingAmounts []orderbook.PendingAmt
+	pendingAmtPtr := &pendingAmounts
+
+	obm.EvictAllPairs(blk.Hght, blk.Tmstmp, pendingAmtPtr, m)
+
+	for i, tx := range blk.Txs {
+		result := results[i]
+		if result.Success {
+			addr := tx.Auth.PublicKey()
+			switch action := tx.Action.(type) {
+			case *actions.AddOrder:
+				m.AddOrder()
+				order := orderbook.NewOrder(tx.ID(), addr, action.Price, action.Quantity, action.Side, blk.Hght, action.BlockExpiryWindow)
+				order.TimeInForce = action.TimeInForce
+				order.SelfTradePrevention = action.SelfTradePrevention
+				order.TriggerPrice = action.TriggerPrice
+				order.DisplayQuantity = utils.BalanceToQuantity(action.DisplayQuantity)
+				order.TimeExpiry = action.TimeExpiry
+				order.QuoteAmount = action.QuoteAmount
+				if action.Price == 0 {
+					order.WorstPrice = action.MarketPrice(obm, blk.Hght)
+				}
+				ob := obm.GetOrderbook(action.Pair)
+				if order.TriggerPrice > 0 {
+					ob.AddStop(order, blk.Hght, blk.Tmstmp, m)
+				} else {
+					ob.Add(order, blk.Hght, blk.Tmstmp, pendingAmtPtr, m)
+				}
+			case *actions.CancelOrder:
+				m.CancelOrder()
+				cancelOrder(obm.ViewOrderbook(action.Pair), addr, action.OrderID, pendingAmtPtr, m)
+			case *actions.ReplaceOrder:
+				m.ReplaceOrder()
+				replacement := orderbook.NewOrder(tx.ID(), addr, action.Price, action.Quantity, action.Side, blk.Hght, consts.EvictionBlockWindow)
+				obm.GetOrderbook(action.Pair).Replace(action.OrderID, replacement, blk.Hght, blk.Tmstmp, pendingAmtPtr, m)
+			case *actions.BatchOrders:
+				m.BatchOrders()
+				ob := obm.ViewOrderbook(action.Pair)
+				for _, orderID := range action.Cancels {
+					cancelOrder(ob, addr, orderID, pendingAmtPtr, m)
+				}
+				if len(action.Adds) > 0 {
+					ob = obm.GetOrderbook(action.Pair)
+				}
+				for j, add := range action.Adds {
+					order := orderbook.NewOrder(actions.BatchOrderID(tx.ID(), j), addr, add.Price, add.Quantity, add.Side, blk.Hght, action.BlockExpiryWindow)
+					order.TimeInForce = add.TimeInForce
+					order.TimeExpiry = action.TimeExpiry
+					ob.Add(order, blk.Hght, blk.Tmstmp, pendingAmtPtr, m)
+				}
+			case *actions.CreatePair:
+				m.CreatePair()
+			case *actions.CreateToken:
+				m.CreateToken()
+			case *actions.MintToken:
+				m.MintToken()
+			case *actions.BurnToken:
+				m.BurnToken()
+			case *actions.ClaimFunds:
+				m.ClaimFunds()
+			case *actions.SettleFunds:
+				m.SettleFunds()
+			case *actions.Transfer:
+				m.Transfer()
+			}
+		}
+	}
+
+	obm.TriggerAllPairs(blk.Hght, blk.Tmstmp, pendingAmtPtr, m)
+
+	// Fees are paid to the recipient like any other fill
+	fees := obm.FeeSchedule()
+	trades := obm.TakeTrades(blk.Hght)
+	collected := fees.CollectFees(trades)
+	for _, pf := range collected {
+		m.FeesCollected(pf.Pair.BaseTokenID, pf.Pair.QuoteTokenID, pf.Base, pf.Quote)
+		if fees.Recipient == crypto.EmptyPublicKey {
+			continue
+		}
+		for _, amt := range []orderbook.PendingAmt{
+			{User: fees.Recipient, TokenID: pf.Pair.BaseTokenID, Amount: pf.Base},
+			{User: fees.Recipient, TokenID: pf.Pair.QuoteTokenID, Amount: pf.Quote},
+		} {
+			if amt.Amount > 0 {
+				pendingAmounts = append(pendingAmounts, amt)
+			}
+		}
+	}
+
+	fundsPerUser := make(map[crypto.PublicKey]map[ids.ID]uint64)
+	for _, pendingAmt := range pendingAmounts {
+		if _, ok := fundsPerUser[pendingAmt.User]; !ok {
+			fundsPerUser[pendingAmt.User] = make(map[ids.ID]uint64)
+		}
+		fundsPerUser[pendingAmt.User][pendingAmt.TokenID] += pendingAmt.Amount
+	}
+
+	for user, tokenBalances := range fundsPerUser {
+		for tokenID, balance := range tokenBalances {
+			obm.AddPendingFunds(user, tokenID, balance, blk.Hght)
+		}
+	}
+	obm.UpdateAllMidPrices(blk.Hght)
+	obm.UpdateLastBlockHeight(blk.Hght)
+	return &BlockEvents{
+		ClosedOrders: obm.TakeClosedOrders(),
+		Trades:       trades,
+		Fees:         collected,
+		Levels:       obm.TakeLevelChanges(),
+	}
+}
+
+// cancelOrder cancels [orderID] if it is a resting or stop order owned by
+// [user]. An empty ID cancels all of the user's orders.
+func cancelOrder(ob *orderbook.Orderbook, user crypto.PublicKey, orderID ids.ID, pendingAmounts *[]orderbook.PendingAmt, m *metrics.Metrics) {
+	if orderID == ids.Empty {
+		ob.CancelAll(user, pendingAmounts, m)
+		return
+	}
+	if order := ob.Get(orderID); order != nil && order.User == user {
+		ob.Cancel(order, pendingAmounts, m)
+	} else if stop := ob.GetStop(orderID); stop != nil && stop.User == user {
+		ob.CancelStop(stop, pendingAmounts, m)
+	}
+}
+
+type replayParser struct{}
+
+func (*replayParser) ChainID() ids.ID {
+	return ids.Empty
+}
+
+func (*replayParser) Rules(int64) chain.Rules {
+	return genesis.Default().GetRules()
+}
+
+func (*replayParser) Registry() (chain.ActionRegistry, chain.AuthRegistry) {
+	return registry.ActionRegistry, registry.AuthRegistry
+}
+
+func getAcceptedBlock(blockDB database.KeyValueReader, blockHeight uint64) (*chain.StatefulBlock, error) {
+	rawID, err := blockDB.Get(vm.PrefixBlockHeightKey(blockHeight))
+	if err != nil {
+		return nil, err
+	}
+	blkID, err := ids.ToID(rawID)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := blockDB.Get(vm.PrefixBlockIDKey(blkID))
+	if err != nil {
+		return nil, err
+	}
+	return chain.UnmarshalBlock(raw, &replayParser{})
+}
+
+// Replay rebuilds an orderbook manager by re-applying accepted blocks after
+// [from] up to and including [to]. [from] must be 0 (genesis) or a height a
+// snapshot was stored at. If [to] is 0, blocks are applied until the first
+// height without a stored block or results. The chain must charge [fees] and
+// hold funds pending for [pendingWindow] blocks.
+func Replay(
+	ctx context.Context,
+	blockDB database.KeyValueReader,
+	orderbookDB database.KeyValueReader,
+	fees *orderbook.FeeSchedule,
+	pendingWindow uint64,
+	from uint64,
+	to uint64,
+	m *metrics.Metrics,
+) (*orderbook.OrderbookManager, uint64, error) {
+	obm := orderbook.NewOrderbookManager(fees, pendingWindow)
+	if from > 0 {
+		snapshot, err := storage.GetSnapshot(orderbookDB, from, fees, pendingWindow)
+		if err != nil {
+			return nil, 0, err
+		}
+		if snapshot == nil {
+			return nil, 0, fmt.Errorf("no orderbook snapshot at height %d", from)
+		}
+		obm = snapshot
+	}
+
+	blockHeight := from
+	for to == 0 || blockHeight < to {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		blk, err := getAcceptedBlock(blockDB, blockHeight+1)
+		if to == 0 && errors.Is(err, database.ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: unable to load block %d", err, blockHeight+1)
+		}
+		results, err := storage.GetResults(orderbookDB, blockHeight+1)
+		if to == 0 && errors.Is(err, database.ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: unable to load results %d", err, blockHeight+1)
+		}
+		if len(results) != len(blk.Txs) {
+			return nil, 0, fmt.Errorf("block %d has %d txs but %d results", blockHeight+1, len(blk.Txs), len(results))
+		}
+		ApplyBlock(obm, m, blk, results)
+		blockHeight++
+	}
+	return obm, blockHeight, nil
+}
+
+// CatchUp applies every block accepted after the checkpoint at [from] to
+// [obm], checkpoints it at the height it reached and returns that height.
+// Blocks with stored results had their events stored along with them, so
+// they are only applied. Accepted only runs after a block is stored as
+// accepted, so a crash in between leaves the book behind with the block's
+// results missing. The book can then not be rebuilt from this node's data and
+// CatchUp fails rather than start behind.
+func CatchUp(
+	ctx context.Context,
+	obm *orderbook.OrderbookManager,
+	blockDB database.KeyValueReader,
+	orderbookDB database.Database,
+	from uint64,
+	m *metrics.Metrics,
+) (uint64, error) {
+	blockHeight := from
+	for {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		blk, err := getAcceptedBlock(blockDB, blockHeight+1)
+		if errors.Is(err, database.ErrNotFound) {
+			if blockHeight == from {
+				return blockHeight, nil
+			}
+			return blockHeight, storage.StoreCheckpoint(orderbookDB, obm, blockHeight)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%w: unable to load block %d", err, blockHeight+1)
+		}
+		results, err := storage.GetResults(orderbookDB, blockHeight+1)
+		if errors.Is(err, database.ErrNotFound) {
+			return 0, fmt.Errorf("%w: orderbook is at height %d but block %d was accepted without its results, resync the node", ErrOrderbookBehind, blockHeight, blockHeight+1)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("%w: unable to load results %d", err, blockHeight+1)
+		}
+		if len(results) != len(blk.Txs) {
+			return 0, fmt.Errorf("block %d has %d txs but %d results", blockHeight+1, len(blk.Txs), len(results))
+		}
+		ApplyBlock(obm, m, blk, results)
+		blockHeight++
+	}
+}
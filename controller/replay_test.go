@@ -0,0 +1,398 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"testing"
+
+	ametrics "github.com/ava-labs/avalanchego/api/metrics"
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/database/memdb"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/hashing"
+	"github.com/jaimi-io/clobvm/actions"
+	"github.com/jaimi-io/clobvm/auth"
+	"github.com/jaimi-io/clobvm/genesis"
+	"github.com/jaimi-io/clobvm/metrics"
+	"github.com/jaimi-io/clobvm/orderbook"
+	"github.com/jaimi-io/clobvm/registry"
+	"github.com/jaimi-io/clobvm/storage"
+	"github.com/jaimi-io/hypersdk/chain"
+	"github.com/jaimi-io/hypersdk/codec"
+	"github.com/jaimi-io/hypersdk/crypto"
+	"github.com/jaimi-io/hypersdk/tstate"
+	"github.com/jaimi-io/hypersdk/vm"
+)
+
+// stateDB is chain state held in memory.
+type stateDB map[string][]byte
+
+func (s stateDB) GetValue(_ context.Context, key []byte) ([]byte, error) {
+	v, ok := s[string(key)]
+	if !ok {
+		return nil, database.ErrNotFound
+	}
+	return v, nil
+}
+
+func (s stateDB) Insert(_ context.Context, key []byte, value []byte) error {
+	s[string(key)] = value
+	return nil
+}
+
+func (s stateDB) Remove(_ context.Context, key []byte) error {
+	delete(s, string(key))
+	return nil
+}
+
+func (s stateDB) copy() stateDB {
+	c := make(stateDB, len(s))
+	for k, v := range s {
+		c[k] = v
+	}
+	return c
+}
+
+// chainHarness executes blocks against chain state and applies them to a
+// live manager the way Accepted does, storing them so Replay can read them
+// back.
+type chainHarness struct {
+	t           *testing.T
+	live        *orderbook.OrderbookManager
+	state       stateDB
+	blockDB     *memdb.Database
+	orderbookDB *memdb.Database
+	m           *metrics.Metrics
+	height      uint64
+	nonce       int64
+}
+
+func newChainHarness(t *testing.T) *chainHarness {
+	m, err := metrics.NewMetrics(ametrics.NewMultiGatherer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &chainHarness{
+		t:           t,
+		live:        orderbook.NewOrderbookManager(orderbook.DefaultFeeSchedule(), 3),
+		state:       stateDB{},
+		blockDB:     memdb.New(),
+		orderbookDB: memdb.New(),
+		m:           m,
+	}
+}
+
+// tx signs [action] with [key] into a transaction with a unique ID.
+func (h *chainHarness) tx(key crypto.PrivateKey, action chain.Action) *chain.Transaction {
+	h.nonce++
+	tx, err := chain.NewTx(&chain.Base{Timestamp: h.nonce, ChainID: ids.ID{1}, UnitPrice: 1}, nil, action).Sign(
+		auth.NewE25519Factory(key), registry.ActionRegistry, registry.AuthRegistry,
+	)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return tx
+}
+
+// list lists [pair] and funds each of [keys] with both of its tokens.
+func (h *chainHarness) list(pair orderbook.Pair, keys []crypto.PrivateKey) {
+	ctx := context.Background()
+	if err := storage.SetPair(ctx, h.state, pair, &orderbook.PairInfo{TickSize: 1, LotSize: 10_000}); err != nil {
+		h.t.Fatal(err)
+	}
+	for _, key := range keys {
+		for _, tokenID := range []ids.ID{pair.BaseTokenID, pair.QuoteTokenID} {
+			if err := storage.SetBalance(ctx, h.state, key.PublicKey(), tokenID, 1_000_000_000_000_000); err != nil {
+				h.t.Fatal(err)
+			}
+		}
+	}
+}
+
+// execute runs [txs] as the next block against [db] and returns their
+// results.
+func (h *chainHarness) execute(db stateDB, txs []*chain.Transaction) []*chain.Result {
+	r := genesis.Default().GetRules()
+	results := make([]*chain.Result, 0, len(txs))
+	for _, tx := range txs {
+		result, err := tx.Action.Execute(context.Background(), r, db, int64(h.height+1), tx.Auth, tx.ID(), false, h.live, h.height+1)
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// executeScoped runs [tx] in the next block the way the chain does, paying
+// its fee and only able to touch the state keys it declares, and returns
+// its result along with the state it left.
+func (h *chainHarness) executeScoped(tx *chain.Transaction) (*chain.Result, *tstate.TState) {
+	ctx := context.Background()
+	sm := &StateManager{}
+	ts := tstate.New(0)
+	if err := ts.FetchAndSetScope(ctx, tx.StateKeys(sm), h.state); err != nil {
+		h.t.Fatal(err)
+	}
+	result, err := tx.Execute(ctx, &chain.ExecutionContext{}, genesis.Default().GetRules(), sm, ts, int64(h.height+1), false, h.live, h.height+1)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	return result, ts
+}
+
+// reject verifies a block holding [txs] that is never accepted.
+func (h *chainHarness) reject(txs []*chain.Transaction) {
+	h.execute(h.state.copy(), txs)
+}
+
+// accept verifies and accepts the next block holding [txs], storing the
+// block, its results and the resulting root. The root is checked against one
+// computed without any cached hashes, so a change that does not invalidate
+// the cache fails here.
+func (h *chainHarness) accept(txs []*chain.Transaction) []*chain.Result {
+	results := h.execute(h.state, txs)
+	h.height++
+	blk := &chain.StatefulBlock{Tmstmp: int64(h.height), Hght: h.height, Txs: txs}
+	ApplyBlock(h.live, h.m, blk, results)
+
+	raw, err := blk.Marshal(registry.ActionRegistry, registry.AuthRegistry)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	blkID := ids.ID(hashing.ComputeHash256Array(raw))
+	if err := h.blockDB.Put(vm.PrefixBlockHeightKey(h.height), blkID[:]); err != nil {
+		h.t.Fatal(err)
+	}
+	if err := h.blockDB.Put(vm.PrefixBlockIDKey(blkID), raw); err != nil {
+		h.t.Fatal(err)
+	}
+	if err := storage.StoreResults(h.orderbookDB, h.height, results); err != nil {
+		h.t.Fatal(err)
+	}
+	root := h.live.Root()
+	p := codec.NewWriter(math.MaxInt)
+	h.live.Marshal(p)
+	fresh, err := orderbook.UnmarshalOrderbookManager(codec.NewReader(p.Bytes(), math.MaxInt), h.live.FeeSchedule(), h.live.PendingWindow())
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	if fresh.Root() != root {
+		h.t.Fatalf("block %d: cached root %s, recomputed root %s", h.height, root, fresh.Root())
+	}
+	if err := storage.StoreRoot(h.orderbookDB, h.height, root); err != nil {
+		h.t.Fatal(err)
+	}
+	return results
+}
+
+// snapshot stores the live manager as a snapshot at the current height.
+func (h *chainHarness) snapshot() {
+	if err := storage.StoreSnapshot(h.orderbookDB, h.live, h.height); err != nil {
+		h.t.Fatal(err)
+	}
+}
+
+// replay rebuilds the manager from [from] and checks it has the root the
+// live manager had at every height up to the current one.
+func (h *chainHarness) replay(from uint64) {
+	for to := from + 1; to <= h.height; to++ {
+		obm, height, err := Replay(context.Background(), h.blockDB, h.orderbookDB, h.live.FeeSchedule(), h.live.PendingWindow(), from, to, h.m)
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		want, err := storage.GetRoot(h.orderbookDB, to)
+		if err != nil {
+			h.t.Fatal(err)
+		}
+		if height != to || obm.Root() != want {
+			h.t.Fatalf("replay from %d to %d: root %s at height %d, live root %s", from, to, obm.Root(), height, want)
+		}
+	}
+}
+
+func TestReplayMatchesLiveRoot(t *testing.T) {
+	h := newChainHarness(t)
+	r := rand.New(rand.NewSource(1))
+	pair := orderbook.Pair{BaseTokenID: ids.GenerateTestID(), QuoteTokenID: ids.GenerateTestID()}
+	keys := make([]crypto.PrivateKey, 3)
+	for i := range keys {
+		key, err := crypto.GeneratePrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+	h.list(pair, keys)
+
+	var resting []*chain.Transaction
+	for blk := 0; blk < 30; blk++ {
+		var txs []*chain.Transaction
+		for i := 0; i < 4; i++ {
+			txs = append(txs, h.tx(keys[r.Intn(len(keys))], &actions.AddOrder{
+				Pair:              pair,
+				Quantity:          uint64(1+r.Intn(50)) * 10_000,
+				Side:              r.Intn(2) == 0,
+				Price:             uint64(9_900 + r.Intn(200)),
+				BlockExpiryWindow: 20,
+			}))
+		}
+		if len(resting) > 0 && r.Intn(2) == 0 {
+			order := resting[r.Intn(len(resting))]
+			txs = append(txs, h.tx(keys[0], &actions.CancelOrder{Pair: pair, OrderID: order.ID()}))
+		}
+		txs = append(txs, h.tx(keys[r.Intn(len(keys))], &actions.ClaimFunds{TokenIDs: []ids.ID{pair.BaseTokenID}}))
+		// A block that is verified but never accepted must leave the book
+		// as it was, including the pending funds its claims would pull
+		root := h.live.Root()
+		h.reject(txs)
+		if h.live.Root() != root {
+			t.Fatalf("rejected block %d changed the root", blk)
+		}
+		h.accept(txs)
+		resting = append(resting, txs[:4]...)
+		if blk == 10 {
+			h.snapshot()
+		}
+	}
+
+	h.replay(0)
+	h.replay(11)
+}
+
+func TestCatchUpAppliesBlocksAfterCheckpoint(t *testing.T) {
+	h := newChainHarness(t)
+	pair := orderbook.Pair{BaseTokenID: ids.GenerateTestID(), QuoteTokenID: ids.GenerateTestID()}
+	keys := make([]crypto.PrivateKey, 2)
+	for i := range keys {
+		key, err := crypto.GeneratePrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+	h.list(pair, keys)
+
+	for blk := 0; blk < 8; blk++ {
+		h.accept([]*chain.Transaction{
+			h.tx(keys[0], &actions.AddOrder{Pair: pair, Quantity: 20_000, Side: true, Price: uint64(10_000 + blk), BlockExpiryWindow: 20}),
+			h.tx(keys[1], &actions.AddOrder{Pair: pair, Quantity: 10_000, Side: false, Price: 10_000, BlockExpiryWindow: 20}),
+		})
+		if blk == 2 {
+			h.snapshot()
+		}
+	}
+
+	// The node stopped after blk 3 was checkpointed but the chain kept going
+	restore := func() *orderbook.OrderbookManager {
+		obm, err := storage.GetSnapshot(h.orderbookDB, 3, h.live.FeeSchedule(), h.live.PendingWindow())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return obm
+	}
+	obm := restore()
+	height, err := CatchUp(context.Background(), obm, h.blockDB, h.orderbookDB, 3, h.m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height != h.height || obm.Root() != h.live.Root() {
+		t.Fatalf("caught up to %d with root %s, live is at %d with root %s", height, obm.Root(), h.height, h.live.Root())
+	}
+	checkpoint, checkpointHeight, err := storage.GetCheckpoint(h.orderbookDB, h.live.FeeSchedule(), h.live.PendingWindow())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpointHeight != h.height || checkpoint.Root() != h.live.Root() {
+		t.Fatalf("checkpoint at %d with root %s, want %d with root %s", checkpointHeight, checkpoint.Root(), h.height, h.live.Root())
+	}
+
+	// Results are stored with the checkpoint, so a block accepted right
+	// before a crash has none and the book cannot be caught up
+	if err := h.orderbookDB.Delete(storage.ResultsKey(6)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := CatchUp(context.Background(), restore(), h.blockDB, h.orderbookDB, 3, h.m); !errors.Is(err, ErrOrderbookBehind) {
+		t.Fatalf("got %v, want %v", err, ErrOrderbookBehind)
+	}
+}
+
+// Market orders without a worst price fill up to a price worked out from the
+// mid price of an earlier block, both when executed and when replayed, so a
+// replay must work out the same price.
+func TestReplayMarketOrders(t *testing.T) {
+	h := newChainHarness(t)
+	r := rand.New(rand.NewSource(2))
+	pair := orderbook.Pair{BaseTokenID: ids.GenerateTestID(), QuoteTokenID: ids.GenerateTestID()}
+	keys := make([]crypto.PrivateKey, 3)
+	for i := range keys {
+		key, err := crypto.GeneratePrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+	h.list(pair, keys)
+
+	filled := make(map[bool]int)
+	for blk := 0; blk < 30; blk++ {
+		var txs []*chain.Transaction
+		for i := 0; i < 4; i++ {
+			side := i%2 == 0
+			price := uint64(9_900 + r.Intn(90))
+			if !side {
+				price = uint64(10_010 + r.Intn(90))
+			}
+			txs = append(txs, h.tx(keys[r.Intn(len(keys))], &actions.AddOrder{
+				Pair:              pair,
+				Quantity:          uint64(1+r.Intn(50)) * 10_000,
+				Side:              side,
+				Price:             price,
+				BlockExpiryWindow: 20,
+			}))
+		}
+		var market []bool
+		if blk >= 8 {
+			for _, side := range []bool{true, false} {
+				order := &actions.AddOrder{
+					Pair:              pair,
+					Quantity:          uint64(1+r.Intn(30)) * 10_000,
+					Side:              side,
+					BlockExpiryWindow: 20,
+				}
+				switch r.Intn(4) {
+				case 1:
+					order.MaxSlippage = uint64(10 + r.Intn(100))
+				case 2:
+					order.WorstPrice = uint64(9_950 + r.Intn(100))
+				case 3:
+					if side {
+						order.Quantity = 0
+						order.QuoteAmount = uint64(1+r.Intn(30)) * 10_000
+					}
+				}
+				txs = append(txs, h.tx(keys[r.Intn(len(keys))], order))
+				market = append(market, side)
+			}
+		}
+		results := h.accept(txs)
+		for i, side := range market {
+			if result := results[4+i]; result.Success {
+				filled[side]++
+			} else {
+				t.Fatalf("market order in block %d failed: %s", blk, result.Output)
+			}
+		}
+		if blk == 14 {
+			h.snapshot()
+		}
+	}
+	if filled[true] == 0 || filled[false] == 0 {
+		t.Fatalf("market orders executed: %d buys and %d sells", filled[true], filled[false])
+	}
+
+	h.replay(0)
+	h.replay(15)
+}
@@ -2,32 +2,51 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/ava-labs/avalanchego/database"
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/jaimi-io/clobvm/genesis"
 	"github.com/jaimi-io/clobvm/orderbook"
 	"github.com/jaimi-io/clobvm/storage"
 	"github.com/jaimi-io/hypersdk/crypto"
+	"go.uber.org/zap"
 )
 
 func (c *Controller) Genesis() (*genesis.Genesis) {
 	return c.genesis
 }
 
+// GetBalance returns [pk]'s balance of [tokenID] including the pending funds
+// the next block can settle, since any action touching the balance pulls them
+// into it first.
 func (c *Controller) GetBalance(ctx context.Context, pk crypto.PublicKey, tokenID ids.ID) (uint64, error) {
-	return storage.GetBalanceFromState(ctx, c.inner.ReadState, pk, tokenID)
+	balance, err := storage.GetBalanceFromState(ctx, c.inner.ReadState, pk, tokenID)
+	if err != nil {
+		return 0, err
+	}
+	c.orderbookManager.RLock()
+	defer c.orderbookManager.RUnlock()
+	_, matured := c.orderbookManager.GetUnsettledFunds(pk, tokenID, c.settled(ctx, pk, tokenID), c.orderbookManager.GetLastBlockHeight()+1)
+	return balance + matured, nil
 }
 
-func (c *Controller) GetOrderbook(ctx context.Context, pair orderbook.Pair, numPriceLevels int) (string, string, error) {
-	ob := c.orderbookManager.GetOrderbook(pair)
-	buySide := ob.GetBuySide(numPriceLevels)
-	sellSide := ob.GetSellSide(numPriceLevels)
-	return fmt.Sprint(buySide), fmt.Sprint(sellSide), nil
+func (c *Controller) GetDepth(ctx context.Context, pair orderbook.Pair, numPriceLevels int, includeOrders bool) ([]orderbook.PriceLevel, []orderbook.PriceLevel, error) {
+	c.orderbookManager.RLock()
+	defer c.orderbookManager.RUnlock()
+	ob := c.orderbookManager.ViewOrderbook(pair)
+	if ob == nil {
+		return nil, nil, fmt.Errorf("orderbook not found for pair %s", pair)
+	}
+	bids, asks := ob.GetDepth(numPriceLevels, includeOrders)
+	return bids, asks, nil
 }
 
 func (c *Controller) GetMidPrice(ctx context.Context, pair orderbook.Pair) (uint64, error) {
-	ob := c.orderbookManager.GetOrderbook(pair)
+	c.orderbookManager.RLock()
+	defer c.orderbookManager.RUnlock()
+	ob := c.orderbookManager.ViewOrderbook(pair)
 	if ob == nil {
 		return 0, fmt.Errorf("orderbook not found for pair %s", pair)
 	}
@@ -37,13 +56,112 @@ func (c *Controller) GetMidPrice(ctx context.Context, pair orderbook.Pair) (uint
 
 
 func (c *Controller) GetPendingFunds(ctx context.Context, user crypto.PublicKey, tokenID ids.ID, blockHeight uint64) (uint64, uint64) {
-	return c.orderbookManager.GetPendingFunds(user, tokenID, blockHeight)
+	c.orderbookManager.RLock()
+	defer c.orderbookManager.RUnlock()
+	total, blkHgt := c.orderbookManager.GetPendingFunds(user, tokenID, blockHeight)
+	return orderbook.Unsettled(total, c.settled(ctx, user, tokenID)), blkHgt
 }
 
-func (c *Controller) GetVolumes(ctx context.Context, pair orderbook.Pair, numPriceLevels int) (string, error) {
-	ob := c.orderbookManager.GetOrderbook(pair)
-	if ob == nil {
-		return "", fmt.Errorf("orderbook not found for pair %s", pair)
+// GetAllPendingFunds returns everything [user] has pending and the last height
+// applied to the books.
+func (c *Controller) GetAllPendingFunds(ctx context.Context, user crypto.PublicKey) ([]*orderbook.PendingFunds, uint64) {
+	c.orderbookManager.RLock()
+	defer c.orderbookManager.RUnlock()
+	settled := func(tokenID ids.ID) uint64 { return c.settled(ctx, user, tokenID) }
+	return c.orderbookManager.ListPendingFunds(user, settled), c.orderbookManager.GetLastBlockHeight()
+}
+
+// GetUnsettledFunds returns all of [user]'s pending [tokenID] and how much of
+// it can be settled by the next block.
+func (c *Controller) GetUnsettledFunds(ctx context.Context, user crypto.PublicKey, tokenID ids.ID) (uint64, uint64) {
+	c.orderbookManager.RLock()
+	defer c.orderbookManager.RUnlock()
+	return c.orderbookManager.GetUnsettledFunds(user, tokenID, c.settled(ctx, user, tokenID), c.orderbookManager.GetLastBlockHeight()+1)
+}
+
+// GetMaturedFunds returns every user's pending funds that can be settled by
+// the next block and the last height applied to the books.
+func (c *Controller) GetMaturedFunds(ctx context.Context) ([]*orderbook.MaturedFund, uint64) {
+	c.orderbookManager.RLock()
+	defer c.orderbookManager.RUnlock()
+	blockHeight := c.orderbookManager.GetLastBlockHeight()
+	settled := func(user crypto.PublicKey, tokenID ids.ID) uint64 { return c.settled(ctx, user, tokenID) }
+	return c.orderbookManager.ListMaturedFunds(blockHeight+1, settled), blockHeight
+}
+
+// settled is how much of [user]'s pending [tokenID] has been pulled as of the
+// last accepted state. A failed read counts as nothing pulled.
+func (c *Controller) settled(ctx context.Context, user crypto.PublicKey, tokenID ids.ID) uint64 {
+	settled, err := storage.GetSettledFromState(ctx, c.inner.ReadState, user, tokenID)
+	if err != nil {
+		c.inner.Logger().Warn("failed to read settled funds", zap.Error(err))
+	}
+	return settled
+}
+
+func (c *Controller) GetOrderbookRoot(ctx context.Context, blockHeight uint64) (ids.ID, uint64, error) {
+	c.orderbookManager.RLock()
+	defer c.orderbookManager.RUnlock()
+	if blockHeight == 0 {
+		blockHeight = c.orderbookManager.GetLastBlockHeight()
+	}
+	root, err := storage.GetRoot(c.orderbookDB, blockHeight)
+	if errors.Is(err, database.ErrNotFound) {
+		return ids.Empty, 0, fmt.Errorf("no orderbook root at height %d", blockHeight)
 	}
-	return ob.GetVolumes(numPriceLevels), nil
+	return root, blockHeight, err
+}
+
+// GetOpenOrders, GetOrderStatus and GetDepth return copies of the orders taken
+// under the read lock, since Accepted goes on matching the orders in the book.
+func (c *Controller) GetOpenOrders(ctx context.Context, user crypto.PublicKey, pair orderbook.Pair) ([]*orderbook.Order, error) {
+	c.orderbookManager.RLock()
+	defer c.orderbookManager.RUnlock()
+	return c.orderbookManager.ViewOrderbook(pair).GetOpenOrders(user), nil
+}
+
+// GetOrderStatus looks up [orderID] in the books first and falls back to the
+// final state recorded when the order closed.
+func (c *Controller) GetOrderStatus(ctx context.Context, orderID ids.ID) (*orderbook.Order, error) {
+	c.orderbookManager.RLock()
+	defer c.orderbookManager.RUnlock()
+	if order := c.orderbookManager.GetOrder(orderID); order != nil {
+		return order, nil
+	}
+	order, err := storage.GetClosedOrder(c.orderbookDB, orderID)
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, fmt.Errorf("order %s not found", orderID)
+	}
+	return order, err
+}
+
+// GetTrades, GetCandles and GetFees read what Accepted stores with the book,
+// so they hold its read lock to see a whole block or none of it.
+func (c *Controller) GetTrades(ctx context.Context, pair orderbook.Pair, user crypto.PublicKey, blockHeight uint64, index uint32, limit int) ([]*orderbook.Trade, error) {
+	c.orderbookManager.RLock()
+	defer c.orderbookManager.RUnlock()
+	return storage.GetTrades(c.orderbookDB, pair, user, blockHeight, index, limit)
+}
+
+func (c *Controller) GetCandles(ctx context.Context, pair orderbook.Pair, interval int64, start int64, limit int) ([]*orderbook.Candle, error) {
+	if !orderbook.ValidCandleInterval(interval) {
+		return nil, fmt.Errorf("invalid candle interval %ds", interval)
+	}
+	c.orderbookManager.RLock()
+	defer c.orderbookManager.RUnlock()
+	return storage.GetCandles(c.orderbookDB, pair, interval, start, limit)
+}
+
+func (c *Controller) GetFees(ctx context.Context, pair orderbook.Pair) (*orderbook.PairFees, error) {
+	c.orderbookManager.RLock()
+	defer c.orderbookManager.RUnlock()
+	return storage.GetFees(c.orderbookDB, pair)
+}
+
+func (c *Controller) GetPair(ctx context.Context, pair orderbook.Pair) (*orderbook.PairInfo, error) {
+	return storage.GetPairFromState(ctx, c.inner.ReadState, pair)
+}
+
+func (c *Controller) GetToken(ctx context.Context, tokenID ids.ID) (*storage.TokenInfo, error) {
+	return storage.GetTokenFromState(ctx, c.inner.ReadState, tokenID)
 }
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/jaimi-io/clobvm/actions"
+	"github.com/jaimi-io/clobvm/orderbook"
+	"github.com/jaimi-io/clobvm/storage"
+	"github.com/jaimi-io/hypersdk/chain"
+	"github.com/jaimi-io/hypersdk/crypto"
+)
+
+func TestSettleFundsForAnotherUser(t *testing.T) {
+	h := newChainHarness(t)
+	pair := orderbook.Pair{BaseTokenID: ids.GenerateTestID(), QuoteTokenID: ids.GenerateTestID()}
+	keys := make([]crypto.PrivateKey, 3)
+	for i := range keys {
+		key, err := crypto.GeneratePrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+	h.list(pair, keys)
+	seller, sweeper := keys[0].PublicKey(), keys[2].PublicKey()
+
+	h.accept([]*chain.Transaction{
+		h.tx(keys[0], &actions.AddOrder{Pair: pair, Quantity: 20_000, Side: false, Price: 10_000, BlockExpiryWindow: 20}),
+		h.tx(keys[1], &actions.AddOrder{Pair: pair, Quantity: 20_000, Side: true, Price: 10_000, BlockExpiryWindow: 20}),
+	})
+	for h.height <= h.live.PendingWindow() {
+		h.accept(nil)
+	}
+
+	ctx := context.Background()
+	_, sellerBefore, err := storage.GetBalance(ctx, h.state, seller, pair.QuoteTokenID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, sweeperBefore, err := storage.GetBalance(ctx, h.state, sweeper, pair.QuoteTokenID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The sweeper pays the fee from their own balance of the settled token
+	settle := &actions.SettleFunds{TokenID: pair.QuoteTokenID, Users: []crypto.PublicKey{seller}}
+	result, ts := h.executeScoped(h.tx(keys[2], settle))
+	if !result.Success {
+		t.Fatalf("settlement failed: %s", result.Output)
+	}
+	_, sellerAfter, err := storage.GetBalance(ctx, ts, seller, pair.QuoteTokenID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matured := h.live.MaturedFunds(seller, pair.QuoteTokenID, h.height+1)
+	if matured == 0 || sellerAfter != sellerBefore+matured {
+		t.Fatalf("seller has %d, want %d settled onto %d", sellerAfter, matured, sellerBefore)
+	}
+	_, sweeperAfter, err := storage.GetBalance(ctx, ts, sweeper, pair.QuoteTokenID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee := settle.Fee(0, h.height+1, nil, h.live); sweeperAfter != sweeperBefore-fee {
+		t.Fatalf("sweeper has %d, want %d less the fee of %d", sweeperAfter, sweeperBefore, fee)
+	}
+}
+
+func TestTransferSettlesRecipient(t *testing.T) {
+	h := newChainHarness(t)
+	pair := orderbook.Pair{BaseTokenID: ids.GenerateTestID(), QuoteTokenID: ids.GenerateTestID()}
+	keys := make([]crypto.PrivateKey, 3)
+	for i := range keys {
+		key, err := crypto.GeneratePrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[i] = key
+	}
+	h.list(pair, keys)
+	seller := keys[0].PublicKey()
+
+	h.accept([]*chain.Transaction{
+		h.tx(keys[0], &actions.AddOrder{Pair: pair, Quantity: 20_000, Side: false, Price: 10_000, BlockExpiryWindow: 20}),
+		h.tx(keys[1], &actions.AddOrder{Pair: pair, Quantity: 20_000, Side: true, Price: 10_000, BlockExpiryWindow: 20}),
+	})
+	for h.height <= h.live.PendingWindow() {
+		h.accept(nil)
+	}
+
+	ctx := context.Background()
+	_, before, err := storage.GetBalance(ctx, h.state, seller, pair.QuoteTokenID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Receiving funds touches the seller's balance, so their matured funds
+	// are pulled without a settlement
+	transfer := &actions.Transfer{To: seller, TokenID: pair.QuoteTokenID, Amount: 1_000}
+	result, ts := h.executeScoped(h.tx(keys[2], transfer))
+	if !result.Success {
+		t.Fatalf("transfer failed: %s", result.Output)
+	}
+	_, after, err := storage.GetBalance(ctx, ts, seller, pair.QuoteTokenID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matured := h.live.MaturedFunds(seller, pair.QuoteTokenID, h.height+1)
+	if matured == 0 || after != before+matured+transfer.Amount {
+		t.Fatalf("seller has %d, want %d settled and %d received onto %d", after, matured, transfer.Amount, before)
+	}
+	settled, err := ts.GetValue(ctx, storage.SettledKey(seller, pair.QuoteTokenID))
+	if err != nil || binary.BigEndian.Uint64(settled) != matured {
+		t.Fatalf("seller has settled %x, want %d: %v", settled, matured, err)
+	}
+}
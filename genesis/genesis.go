@@ -3,18 +3,60 @@ package genesis
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/trace"
+	smath "github.com/ava-labs/avalanchego/utils/math"
 
 	"github.com/jaimi-io/clobvm/consts"
+	"github.com/jaimi-io/clobvm/orderbook"
 	"github.com/jaimi-io/clobvm/storage"
 	"github.com/jaimi-io/hypersdk/chain"
 	"github.com/jaimi-io/hypersdk/crypto"
 )
 
+var (
+	ErrInvalidToken         = errors.New("invalid genesis token")
+	ErrDuplicateToken       = errors.New("duplicate genesis token")
+	ErrUnknownToken         = errors.New("unknown genesis token")
+	ErrInvalidPair          = errors.New("invalid genesis pair")
+	ErrDuplicatePair        = errors.New("duplicate genesis pair")
+	ErrInvalidAllocation    = errors.New("invalid genesis allocation")
+	ErrMaxSupplyExceeded    = errors.New("genesis allocations exceed max supply")
+	ErrNoNativeToken        = fmt.Errorf("genesis must create the native token %s", consts.NativeTokenSymbol)
+	ErrInvalidPendingWindow = fmt.Errorf("genesis pending block window must be between 1 and %d", consts.MaxPendingBlockWindow)
+)
+
+// Token is a token created at genesis. Its ID is its symbol padded to 32
+// bytes, so it can be named by symbol. With no Owner no more can be minted.
+type Token struct {
+	Symbol    string `json:"symbol"`
+	Decimals  uint8  `json:"decimals"`
+	MaxSupply uint64 `json:"maxSupply"` // 0 is uncapped
+	Owner     string `json:"owner,omitempty"`
+}
+
+// Pair is a pair listed at genesis between the genesis tokens with symbols
+// Base and Quote.
+type Pair struct {
+	Base        string `json:"base"`
+	Quote       string `json:"quote"`
+	TickSize    uint64 `json:"tickSize"`
+	LotSize     uint64 `json:"lotSize"`
+	MinNotional uint64 `json:"minNotional"`
+}
+
+// CustomAllocation credits Amount balance units of the genesis token with
+// symbol Token to Address.
+type CustomAllocation struct {
+	Address string `json:"address"`
+	Token   string `json:"token"`
+	Amount  uint64 `json:"amount"`
+}
+
 type Genesis struct {
 	// Address prefix
 	HRP string `json:"hrp"`
@@ -37,6 +79,24 @@ type Genesis struct {
 	BlockCostChangeDenominator uint64 `json:"blockCostChangeDenominator"`
 	WindowTargetBlocks         uint64 `json:"windowTargetBlocks"` // 10s
 
+	// Order params
+	MaxBlockExpiryWindow uint64 `json:"maxBlockExpiryWindow"` // blocks
+	MaxTimeExpiryWindow  int64  `json:"maxTimeExpiryWindow"`  // seconds
+
+	// Fee params
+	FeeTiers             []orderbook.Fee `json:"feeTiers"`             // ascending by amount
+	ExecutionHistoryDays int             `json:"executionHistoryDays"` // days of volume tiers are picked by
+	FeeTokenPolicy       string          `json:"feeTokenPolicy"`       // "spent" or "quote"
+	FeeRecipient         string          `json:"feeRecipient"`         // fees are burned if empty
+
+	// Settlement params
+	PendingBlockWindow uint64 `json:"pendingBlockWindow"` // blocks fills and refunds wait before they can be claimed
+
+	// Initial state
+	Tokens           []*Token            `json:"tokens"`
+	Pairs            []*Pair             `json:"pairs"`
+	CustomAllocation []*CustomAllocation `json:"customAllocation"`
+
 	Rules *Rules
 }
 
@@ -61,19 +121,189 @@ func Default() *Genesis {
 		MinBlockCost:               0,
 		BlockCostChangeDenominator: 48,
 		WindowTargetBlocks:         1_000_000_000, // 10s
+
+		// Order params
+		MaxBlockExpiryWindow: 1_000_000,
+		MaxTimeExpiryWindow:  30 * 24 * 60 * 60, // 30 days
+
+		// Fee params
+		FeeTiers:             orderbook.DefaultFeeTiers(),
+		ExecutionHistoryDays: consts.NumExecutionHistoryDays,
+		FeeTokenPolicy:       orderbook.FeeSpentToken.String(),
+
+		// Settlement params
+		PendingBlockWindow: consts.PendingBlockWindow,
+
+		// Initial state
+		Tokens: []*Token{
+			{Symbol: "AVAX", Decimals: consts.BalanceDecimals},
+			{Symbol: "USDC", Decimals: consts.BalanceDecimals},
+		},
+		Pairs: []*Pair{
+			{Base: "AVAX", Quote: "USDC", TickSize: 1, LotSize: 10_000}, // 1 quantity unit
+		},
+		CustomAllocation: defaultAllocations(),
+	}
+}
+
+func defaultAllocations() []*CustomAllocation {
+	addresses := []string{
+		"clob1xdnx5wqmkz83laqmeqamdyftwnm74rldlp2fsh9ymmqsztv7ykysltfjk6",
+		"clob12l2xyad754fu3s9rqdwq4mkllnl0vc5yerygn2aw5xasmjhzmtwspkx4ek",
+		"clob1fz7uy8z5xezg7ns5qke4taflr062eqr5uhxmzan8ys92fpvhd24syx7ft6",
 	}
+	// Small enough for the total supply of each token to fit in a uint64
+	amt := uint64(6_000_000_000) * uint64(math.Pow10(consts.BalanceDecimals))
+	allocations := make([]*CustomAllocation, 0, 2*len(addresses))
+	for _, addr := range addresses {
+		for _, token := range []string{"AVAX", "USDC"} {
+			allocations = append(allocations, &CustomAllocation{Address: addr, Token: token, Amount: amt})
+		}
+	}
+	return allocations
 }
 
+// TokenID is the ID of the genesis token with [symbol].
+func TokenID(symbol string) ids.ID {
+	var id ids.ID
+	copy(id[:], []byte(symbol))
+	return id
+}
+
+// NativeTokenID is the ID of the genesis token that actions creating tokens
+// pay their fee in.
+var NativeTokenID = TokenID(consts.NativeTokenSymbol)
+
 func New(b []byte, _ []byte) (*Genesis, error) {
 	g := Default()
 	if len(b) > 0 {
+		// Unmarshal would decode into the default entries, so the lists are
+		// only defaulted when the config leaves them out
+		feeTiers, tokens, pairs, allocations := g.FeeTiers, g.Tokens, g.Pairs, g.CustomAllocation
+		g.FeeTiers, g.Tokens, g.Pairs, g.CustomAllocation = nil, nil, nil, nil
 		if err := json.Unmarshal(b, g); err != nil {
 			return nil, fmt.Errorf("failed to unmarshal config %s: %w", string(b), err)
 		}
+		if g.FeeTiers == nil {
+			g.FeeTiers = feeTiers
+		}
+		if g.Tokens == nil {
+			g.Tokens = tokens
+		}
+		if g.Pairs == nil {
+			g.Pairs = pairs
+		}
+		if g.CustomAllocation == nil {
+			g.CustomAllocation = allocations
+		}
+	}
+	if err := g.Verify(); err != nil {
+		return nil, err
 	}
 	return g, nil
 }
 
+func parseAddress(addr string) (crypto.PublicKey, error) {
+	if len(addr) == 0 {
+		return crypto.EmptyPublicKey, nil
+	}
+	return crypto.ParseAddress(consts.HRP, addr)
+}
+
+// Verify checks that the fee schedule of [g] is valid and that its tokens,
+// pairs and allocations can be loaded.
+func (g *Genesis) Verify() error {
+	policy, err := orderbook.ParseFeePolicy(g.FeeTokenPolicy)
+	if err != nil {
+		return err
+	}
+	fees := &orderbook.FeeSchedule{
+		Tiers:       g.FeeTiers,
+		HistoryDays: g.ExecutionHistoryDays,
+		Policy:      policy,
+	}
+	if err := fees.Verify(); err != nil {
+		return err
+	}
+	if _, err := parseAddress(g.FeeRecipient); err != nil {
+		return fmt.Errorf("%w: fee recipient: %v", orderbook.ErrInvalidFeeSchedule, err)
+	}
+	if g.PendingBlockWindow == 0 || g.PendingBlockWindow > consts.MaxPendingBlockWindow {
+		return ErrInvalidPendingWindow
+	}
+
+	tokens := make(map[string]*Token, len(g.Tokens))
+	for _, token := range g.Tokens {
+		if len(token.Symbol) == 0 || len(token.Symbol) > consts.MaxSymbolSize || token.Decimals > consts.BalanceDecimals {
+			return fmt.Errorf("%w: %s", ErrInvalidToken, token.Symbol)
+		}
+		if _, ok := tokens[token.Symbol]; ok {
+			return fmt.Errorf("%w: %s", ErrDuplicateToken, token.Symbol)
+		}
+		if _, err := parseAddress(token.Owner); err != nil {
+			return fmt.Errorf("%w: %s owner: %v", ErrInvalidToken, token.Symbol, err)
+		}
+		tokens[token.Symbol] = token
+	}
+	if _, ok := tokens[consts.NativeTokenSymbol]; !ok {
+		return ErrNoNativeToken
+	}
+
+	pairs := make(map[Pair]struct{}, len(g.Pairs))
+	for _, pair := range g.Pairs {
+		for _, symbol := range []string{pair.Base, pair.Quote} {
+			if _, ok := tokens[symbol]; !ok {
+				return fmt.Errorf("%w: %s", ErrUnknownToken, symbol)
+			}
+		}
+		if pair.Base == pair.Quote || pair.TickSize == 0 || pair.LotSize == 0 {
+			return fmt.Errorf("%w: %s/%s", ErrInvalidPair, pair.Base, pair.Quote)
+		}
+		key := Pair{Base: pair.Base, Quote: pair.Quote}
+		if _, ok := pairs[key]; ok {
+			return fmt.Errorf("%w: %s/%s", ErrDuplicatePair, pair.Base, pair.Quote)
+		}
+		pairs[key] = struct{}{}
+	}
+
+	supplies, err := g.supplies()
+	if err != nil {
+		return err
+	}
+	for symbol, supply := range supplies {
+		if maxSupply := tokens[symbol].MaxSupply; maxSupply > 0 && supply > maxSupply {
+			return fmt.Errorf("%w: %s", ErrMaxSupplyExceeded, symbol)
+		}
+	}
+	return nil
+}
+
+// supplies is the total allocated to each genesis token by symbol.
+func (g *Genesis) supplies() (map[string]uint64, error) {
+	supplies := make(map[string]uint64, len(g.Tokens))
+	for _, token := range g.Tokens {
+		supplies[token.Symbol] = 0
+	}
+	for _, alloc := range g.CustomAllocation {
+		supply, ok := supplies[alloc.Token]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownToken, alloc.Token)
+		}
+		if _, err := crypto.ParseAddress(consts.HRP, alloc.Address); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidAllocation, alloc.Address, err)
+		}
+		if alloc.Amount == 0 {
+			return nil, fmt.Errorf("%w: %s has no %s", ErrInvalidAllocation, alloc.Address, alloc.Token)
+		}
+		supply, err := smath.Add64(supply, alloc.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrInvalidAllocation, alloc.Token, err)
+		}
+		supplies[alloc.Token] = supply
+	}
+	return supplies, nil
+}
+
 func (g *Genesis) GetHRP() string {
 	return consts.HRP
 }
@@ -85,41 +315,59 @@ func (g *Genesis) GetRules() *Rules {
 	return g.Rules
 }
 
-func distributeTokens(ctx context.Context, db chain.Database) error {
-	b := make([]byte, 32)
-	copy(b, []byte("AVAX"))
-	avaxID, _ := ids.ToID(b)
-	copy(b, []byte("USDC"))
-	usdcID, _ := ids.ToID(b)
-	tokens := []ids.ID {
-		avaxID,
-		usdcID,
-	}
-	addressess := []string{
-		"clob1xdnx5wqmkz83laqmeqamdyftwnm74rldlp2fsh9ymmqsztv7ykysltfjk6",
-		"clob12l2xyad754fu3s9rqdwq4mkllnl0vc5yerygn2aw5xasmjhzmtwspkx4ek",
-		"clob1fz7uy8z5xezg7ns5qke4taflr062eqr5uhxmzan8ys92fpvhd24syx7ft6",
+func (g *Genesis) Load(ctx context.Context, tracer trace.Tracer, db chain.Database) error {
+	ctx, span := tracer.Start(ctx, "genesis.Load")
+	defer span.End()
+
+	supplies, err := g.supplies()
+	if err != nil {
+		return err
 	}
-	for _, strAddr := range addressess {
-		addr, err := crypto.ParseAddress("clob", strAddr)
+	for _, token := range g.Tokens {
+		owner, err := parseAddress(token.Owner)
 		if err != nil {
 			return err
 		}
-		decimals := uint64(math.Pow10(consts.BalanceDecimals))
-		amt := uint64(18_000_000_000) * decimals
-		for _, tokenID := range tokens {
-			err = storage.SetBalance(ctx, db, addr, tokenID, amt)
-			if err != nil {
-				return err
-			}
+		if err := storage.SetToken(ctx, db, TokenID(token.Symbol), &storage.TokenInfo{
+			Symbol:    token.Symbol,
+			Decimals:  token.Decimals,
+			MaxSupply: token.MaxSupply,
+			Supply:    supplies[token.Symbol],
+			Owner:     owner,
+		}); err != nil {
+			return err
+		}
+	}
+	for _, pair := range g.Pairs {
+		if err := storage.SetPair(ctx, db, orderbook.Pair{
+			BaseTokenID:  TokenID(pair.Base),
+			QuoteTokenID: TokenID(pair.Quote),
+		}, &orderbook.PairInfo{
+			TickSize:    pair.TickSize,
+			LotSize:     pair.LotSize,
+			MinNotional: pair.MinNotional,
+			Status:      orderbook.PairActive,
+		}); err != nil {
+			return err
+		}
+	}
+	for _, alloc := range g.CustomAllocation {
+		addr, err := crypto.ParseAddress(consts.HRP, alloc.Address)
+		if err != nil {
+			return err
+		}
+		tokenID := TokenID(alloc.Token)
+		_, bal, err := storage.GetBalance(ctx, db, addr, tokenID)
+		if err != nil {
+			return err
+		}
+		bal, err = smath.Add64(bal, alloc.Amount)
+		if err != nil {
+			return err
+		}
+		if err := storage.SetBalance(ctx, db, addr, tokenID, bal); err != nil {
+			return err
 		}
 	}
 	return nil
 }
-
-func (g *Genesis) Load(ctx context.Context, tracer trace.Tracer, db chain.Database) error {
-	ctx, span := tracer.Start(ctx, "genesis.Load")
-	defer span.End()
-
-	return distributeTokens(ctx, db)
-}
\ No newline at end of file
@@ -1,6 +1,22 @@
 package genesis
 
-import "github.com/ava-labs/avalanchego/ids"
+import (
+	"github.com/ava-labs/avalanchego/ids"
+
+	"github.com/jaimi-io/clobvm/orderbook"
+	"github.com/jaimi-io/hypersdk/crypto"
+)
+
+// Keys of the order and fee rules served by FetchCustom.
+const (
+	MaxBlockExpiryWindowKey = "maxBlockExpiryWindow"
+	MaxTimeExpiryWindowKey  = "maxTimeExpiryWindow"
+	FeeTiersKey             = "feeTiers"
+	ExecutionHistoryDaysKey = "executionHistoryDays"
+	FeeTokenPolicyKey       = "feeTokenPolicy"
+	FeeRecipientKey         = "feeRecipient"
+	PendingBlockWindowKey   = "pendingBlockWindow"
+)
 
 type Rules struct {
 	g *Genesis
@@ -62,6 +78,54 @@ func (r *Rules) GetWarpFeePerSigner() uint64 {
 	return 128
 }
 
-func (r *Rules) FetchCustom(string) (any, bool) {
+func (r *Rules) GetMaxBlockExpiryWindow() uint64 {
+	return r.g.MaxBlockExpiryWindow
+}
+
+func (r *Rules) GetMaxTimeExpiryWindow() int64 {
+	return r.g.MaxTimeExpiryWindow
+}
+
+func (r *Rules) GetFeeTiers() []orderbook.Fee {
+	return r.g.FeeTiers
+}
+
+func (r *Rules) GetExecutionHistoryDays() int {
+	return r.g.ExecutionHistoryDays
+}
+
+// GetFeeTokenPolicy is only valid for a genesis that passed Verify.
+func (r *Rules) GetFeeTokenPolicy() orderbook.FeePolicy {
+	policy, _ := orderbook.ParseFeePolicy(r.g.FeeTokenPolicy)
+	return policy
+}
+
+// GetFeeRecipient is only valid for a genesis that passed Verify.
+func (r *Rules) GetFeeRecipient() crypto.PublicKey {
+	recipient, _ := parseAddress(r.g.FeeRecipient)
+	return recipient
+}
+
+func (r *Rules) GetPendingBlockWindow() uint64 {
+	return r.g.PendingBlockWindow
+}
+
+func (r *Rules) FetchCustom(key string) (any, bool) {
+	switch key {
+	case MaxBlockExpiryWindowKey:
+		return r.GetMaxBlockExpiryWindow(), true
+	case MaxTimeExpiryWindowKey:
+		return r.GetMaxTimeExpiryWindow(), true
+	case FeeTiersKey:
+		return r.GetFeeTiers(), true
+	case ExecutionHistoryDaysKey:
+		return r.GetExecutionHistoryDays(), true
+	case FeeTokenPolicyKey:
+		return r.GetFeeTokenPolicy(), true
+	case FeeRecipientKey:
+		return r.GetFeeRecipient(), true
+	case PendingBlockWindowKey:
+		return r.GetPendingBlockWindow(), true
+	}
 	return nil, false
-}
\ No newline at end of file
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	ametrics "github.com/ava-labs/avalanchego/api/metrics"
+	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/utils/metric"
 	"github.com/ava-labs/avalanchego/utils/wrappers"
 	"github.com/jaimi-io/clobvm/consts"
@@ -15,8 +16,18 @@ type Metrics struct {
 	transfer      prometheus.Counter
 	addOrder      prometheus.Counter
 	cancelOrder   prometheus.Counter
+	replaceOrder  prometheus.Counter
+	batchOrders   prometheus.Counter
+	createPair    prometheus.Counter
+	createToken   prometheus.Counter
+	mintToken     prometheus.Counter
+	burnToken     prometheus.Counter
+	claimFunds    prometheus.Counter
+	settleFunds   prometheus.Counter
 	limitOrder    prometheus.Counter
 	marketOrder   prometheus.Counter
+	stopOrder     prometheus.Counter
+	stopTriggered prometheus.Counter
 
 
 	orderCancelNum   prometheus.Counter
@@ -24,6 +35,7 @@ type Metrics struct {
 	orderAmount      prometheus.Gauge
   orderFillsNum    prometheus.Counter
 	orderFillsAmount prometheus.Counter
+	feesCollected    *prometheus.CounterVec
 	orderProcessing  metric.Averager
 }
 
@@ -54,6 +66,46 @@ func NewMetrics(gatherer ametrics.MultiGatherer) (*Metrics, error) {
 			Name:      "cancel_order",
 			Help:      "number of cancel order actions",
 		}),
+		replaceOrder: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "actions",
+			Name:      "replace_order",
+			Help:      "number of replace order actions",
+		}),
+		batchOrders: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "actions",
+			Name:      "batch_orders",
+			Help:      "number of batch orders actions",
+		}),
+		createPair: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "actions",
+			Name:      "create_pair",
+			Help:      "number of create pair actions",
+		}),
+		createToken: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "actions",
+			Name:      "create_token",
+			Help:      "number of create token actions",
+		}),
+		mintToken: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "actions",
+			Name:      "mint_token",
+			Help:      "number of mint token actions",
+		}),
+		burnToken: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "actions",
+			Name:      "burn_token",
+			Help:      "number of burn token actions",
+		}),
+		claimFunds: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "actions",
+			Name:      "claim_funds",
+			Help:      "number of claim funds actions",
+		}),
+		settleFunds: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "actions",
+			Name:      "settle_funds",
+			Help:      "number of settle funds actions",
+		}),
 		limitOrder: prometheus.NewCounter(prometheus.CounterOpts{
 			Namespace: "orders",
 			Name:      "limit_order",
@@ -64,6 +116,16 @@ func NewMetrics(gatherer ametrics.MultiGatherer) (*Metrics, error) {
 			Name:      "market_order",
 			Help:      "number of successful market orders entered",
 		}),
+		stopOrder: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "orders",
+			Name:      "stop_order",
+			Help:      "number of stop orders entered",
+		}),
+		stopTriggered: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "orders",
+			Name:      "stop_order_triggered",
+			Help:      "number of stop orders triggered",
+		}),
 		orderCancelNum: prometheus.NewCounter(prometheus.CounterOpts{
 			Namespace: "orders",
 			Name:      "order_cancel_num",
@@ -89,6 +151,11 @@ func NewMetrics(gatherer ametrics.MultiGatherer) (*Metrics, error) {
 			Name:      "order_fills_amount",
 			Help:      "sum of order fills",
 		}),
+		feesCollected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "orders",
+			Name:      "fees_collected",
+			Help:      "sum of fees collected per pair and token",
+		}, []string{"pair", "token"}),
 		orderProcessing: orderProcessing,
 	}
 	errs := wrappers.Errs{}
@@ -96,13 +163,24 @@ func NewMetrics(gatherer ametrics.MultiGatherer) (*Metrics, error) {
 		r.Register(m.transfer),
 		r.Register(m.addOrder),
 		r.Register(m.cancelOrder),
+		r.Register(m.replaceOrder),
+		r.Register(m.batchOrders),
+		r.Register(m.createPair),
+		r.Register(m.createToken),
+		r.Register(m.mintToken),
+		r.Register(m.burnToken),
+		r.Register(m.claimFunds),
+		r.Register(m.settleFunds),
 		r.Register(m.limitOrder),
 		r.Register(m.marketOrder),
+		r.Register(m.stopOrder),
+		r.Register(m.stopTriggered),
 		r.Register(m.orderCancelNum),
 		r.Register(m.orderNum),
 		r.Register(m.orderAmount),
 		r.Register(m.orderFillsNum),
 		r.Register(m.orderFillsAmount),
+		r.Register(m.feesCollected),
 		gatherer.Register(consts.Name, r),
 	)
 	return m, errs.Err
@@ -120,6 +198,38 @@ func (m *Metrics) CancelOrder() {
 	m.cancelOrder.Inc()
 }
 
+func (m *Metrics) ReplaceOrder() {
+	m.replaceOrder.Inc()
+}
+
+func (m *Metrics) BatchOrders() {
+	m.batchOrders.Inc()
+}
+
+func (m *Metrics) CreatePair() {
+	m.createPair.Inc()
+}
+
+func (m *Metrics) CreateToken() {
+	m.createToken.Inc()
+}
+
+func (m *Metrics) MintToken() {
+	m.mintToken.Inc()
+}
+
+func (m *Metrics) BurnToken() {
+	m.burnToken.Inc()
+}
+
+func (m *Metrics) ClaimFunds() {
+	m.claimFunds.Inc()
+}
+
+func (m *Metrics) SettleFunds() {
+	m.settleFunds.Inc()
+}
+
 func (m *Metrics) LimitOrder() {
 	m.limitOrder.Inc()
 }
@@ -128,6 +238,14 @@ func (m *Metrics) MarketOrder() {
 	m.marketOrder.Inc()
 }
 
+func (m *Metrics) StopOrder() {
+	m.stopOrder.Inc()
+}
+
+func (m *Metrics) StopOrderTriggered() {
+	m.stopTriggered.Inc()
+}
+
 func (m *Metrics) OrderCancelNum() {
 	m.orderCancelNum.Inc()
 }
@@ -140,6 +258,12 @@ func (m *Metrics) OrderFillsAmount(amount uint64) {
 	m.orderFillsAmount.Add(float64(amount * utils.MinQuantity()))
 }
 
+func (m *Metrics) FeesCollected(baseTokenID ids.ID, quoteTokenID ids.ID, base uint64, quote uint64) {
+	pair := baseTokenID.String() + "/" + quoteTokenID.String()
+	m.feesCollected.WithLabelValues(pair, baseTokenID.String()).Add(float64(base))
+	m.feesCollected.WithLabelValues(pair, quoteTokenID.String()).Add(float64(quote))
+}
+
 func (m *Metrics) ObserverOrderProcessing(ts time.Duration) {
 	m.orderProcessing.Observe(float64(ts))
 }
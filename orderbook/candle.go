@@ -0,0 +1,85 @@
+package orderbook
+
+import "github.com/jaimi-io/hypersdk/codec"
+
+// CandleIntervals are the bar lengths, in seconds, candles are kept for.
+var CandleIntervals = []int64{
+	60,           // 1m
+	5 * 60,       // 5m
+	60 * 60,      // 1h
+	24 * 60 * 60, // 1d
+}
+
+func ValidCandleInterval(interval int64) bool {
+	for _, i := range CandleIntervals {
+		if i == interval {
+			return true
+		}
+	}
+	return false
+}
+
+// Candle is the OHLCV bar of the trades of a pair in the [interval] seconds
+// from Start. Volume is in quantity units.
+type Candle struct {
+	Start     int64
+	Open      uint64
+	High      uint64
+	Low       uint64
+	Close     uint64
+	Volume    uint64
+	NumTrades uint64
+}
+
+// CandleStart returns the start of the [interval] bar [timestamp] falls in.
+func CandleStart(timestamp int64, interval int64) int64 {
+	return timestamp - timestamp%interval
+}
+
+func NewCandle(trade *Trade, interval int64) *Candle {
+	return &Candle{
+		Start:     CandleStart(trade.Timestamp, interval),
+		Open:      trade.Price,
+		High:      trade.Price,
+		Low:       trade.Price,
+		Close:     trade.Price,
+		Volume:    trade.Quantity,
+		NumTrades: 1,
+	}
+}
+
+// Add folds [trade], which must be later than every trade already in the
+// candle, into it.
+func (c *Candle) Add(trade *Trade) {
+	if trade.Price > c.High {
+		c.High = trade.Price
+	}
+	if trade.Price < c.Low {
+		c.Low = trade.Price
+	}
+	c.Close = trade.Price
+	c.Volume += trade.Quantity
+	c.NumTrades++
+}
+
+func (c *Candle) Marshal(p *codec.Packer) {
+	p.PackInt64(c.Start)
+	p.PackUint64(c.Open)
+	p.PackUint64(c.High)
+	p.PackUint64(c.Low)
+	p.PackUint64(c.Close)
+	p.PackUint64(c.Volume)
+	p.PackUint64(c.NumTrades)
+}
+
+func UnmarshalCandle(p *codec.Packer) *Candle {
+	var c Candle
+	c.Start = p.UnpackInt64(false)
+	c.Open = p.UnpackUint64(true)
+	c.High = p.UnpackUint64(true)
+	c.Low = p.UnpackUint64(true)
+	c.Close = p.UnpackUint64(true)
+	c.Volume = p.UnpackUint64(true)
+	c.NumTrades = p.UnpackUint64(true)
+	return &c
+}
@@ -0,0 +1,280 @@
+package orderbook
+
+import (
+	"bytes"
+	"container/ring"
+	"sort"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/jaimi-io/hypersdk/codec"
+	"github.com/jaimi-io/hypersdk/crypto"
+)
+
+// Marshal packs the full in-memory state of the manager. Maps are walked in
+// sorted key order so two managers holding the same state produce the same
+// bytes.
+func (obm *OrderbookManager) Marshal(p *codec.Packer) {
+	p.PackUint64(obm.lastBlockHeight)
+
+	users := sortedUsers(obm.pendingFunds)
+	p.PackInt(len(users))
+	for _, user := range users {
+		p.PackPublicKey(user)
+		tokens := sortedIDs(obm.pendingFunds[user])
+		p.PackInt(len(tokens))
+		for _, tokenID := range tokens {
+			p.PackID(tokenID)
+			obm.pendingFunds[user][tokenID].Marshal(p)
+		}
+	}
+
+	pairs := obm.sortedPairs()
+	p.PackInt(len(pairs))
+	for _, pair := range pairs {
+		obm.orderbooks[pair].Marshal(p)
+	}
+}
+
+// UnmarshalOrderbookManager unpacks a manager packed by Marshal that charges
+// [fees] and holds funds pending for [pendingWindow] blocks.
+func UnmarshalOrderbookManager(p *codec.Packer, fees *FeeSchedule, pendingWindow uint64) (*OrderbookManager, error) {
+	obm := NewOrderbookManager(fees, pendingWindow)
+	obm.lastBlockHeight = p.UnpackUint64(false)
+
+	numUsers := p.UnpackInt(false)
+	for i := 0; i < numUsers && p.Err() == nil; i++ {
+		var user crypto.PublicKey
+		p.UnpackPublicKey(true, &user)
+		numTokens := p.UnpackInt(false)
+		obm.pendingFunds[user] = make(map[ids.ID]*VersionedBalance, numTokens)
+		for j := 0; j < numTokens && p.Err() == nil; j++ {
+			var tokenID ids.ID
+			p.UnpackID(true, &tokenID)
+			obm.pendingFunds[user][tokenID] = unmarshalVersionedBalance(p, pendingWindow)
+		}
+	}
+
+	numPairs := p.UnpackInt(false)
+	for i := 0; i < numPairs && p.Err() == nil; i++ {
+		ob := unmarshalOrderbook(p, fees, pendingWindow)
+		obm.orderbooks[ob.pair] = ob
+	}
+	return obm, p.Err()
+}
+
+func (ob *Orderbook) Marshal(p *codec.Packer) {
+	p.PackID(ob.pair.BaseTokenID)
+	p.PackID(ob.pair.QuoteTokenID)
+	marshalOrders(p, ob.restingOrders())
+	ob.marshalExecutionHistory(p)
+	ob.midPrice.Marshal(p)
+	marshalOrders(p, ob.stops.stopOrders())
+}
+
+func marshalOrders(p *codec.Packer, orders []*Order) {
+	p.PackInt(len(orders))
+	for _, order := range orders {
+		order.Marshal(p)
+	}
+}
+
+func (ob *Orderbook) marshalExecutionHistory(p *codec.Packer) {
+	users := sortedUsers(ob.executionHistory)
+	p.PackInt(len(users))
+	for _, user := range users {
+		p.PackPublicKey(user)
+		ob.executionHistory[user].Marshal(p)
+	}
+}
+
+func unmarshalOrderbook(p *codec.Packer, fees *FeeSchedule, pendingWindow uint64) *Orderbook {
+	var pair Pair
+	p.UnpackID(true, &pair.BaseTokenID)
+	p.UnpackID(true, &pair.QuoteTokenID)
+	ob := NewOrderbook(pair, fees, pendingWindow)
+
+	numOrders := p.UnpackInt(false)
+	for i := 0; i < numOrders && p.Err() == nil; i++ {
+		order := UnmarshalOrder(p)
+		ob.insert(order)
+		ob.AddToEviction(order)
+	}
+
+	numUsers := p.UnpackInt(false)
+	for i := 0; i < numUsers && p.Err() == nil; i++ {
+		var user crypto.PublicKey
+		p.UnpackPublicKey(true, &user)
+		ob.executionHistory[user] = unmarshalMonthlyExecuted(p, fees.HistoryDays)
+	}
+
+	ob.midPrice = unmarshalVersionedBalance(p, pendingWindow)
+
+	numStops := p.UnpackInt(false)
+	for i := 0; i < numStops && p.Err() == nil; i++ {
+		order := UnmarshalOrder(p)
+		ob.stops.add(order)
+		ob.addOpenOrder(order)
+		ob.AddToEviction(order)
+	}
+	// Restoring the orders is not a change to publish
+	ob.changedLevels = make(map[LevelKey]struct{})
+	return ob
+}
+
+func (o *Order) Marshal(p *codec.Packer) {
+	p.PackID(o.ID)
+	p.PackPublicKey(o.User)
+	p.PackUint64(o.Price)
+	p.PackUint64(o.Quantity)
+	p.PackUint64(o.Fee)
+	p.PackBool(o.Side)
+	p.PackUint64(o.BlockExpiry)
+	p.PackInt64(o.TimeExpiry)
+	p.PackByte(byte(o.TimeInForce))
+	p.PackByte(byte(o.SelfTradePrevention))
+	p.PackUint64(o.TriggerPrice)
+	p.PackUint64(o.WorstPrice)
+	p.PackUint64(o.DisplayQuantity)
+	p.PackUint64(o.Hidden)
+	p.PackUint64(o.OriginalQuantity)
+	p.PackByte(byte(o.Status))
+}
+
+func UnmarshalOrder(p *codec.Packer) *Order {
+	var o Order
+	p.UnpackID(true, &o.ID)
+	p.UnpackPublicKey(true, &o.User)
+	o.Price = p.UnpackUint64(false)
+	o.Quantity = p.UnpackUint64(false)
+	o.Fee = p.UnpackUint64(false)
+	o.Side = p.UnpackBool()
+	o.BlockExpiry = p.UnpackUint64(false)
+	o.TimeExpiry = p.UnpackInt64(false)
+	o.TimeInForce = TimeInForce(p.UnpackByte())
+	o.SelfTradePrevention = SelfTradePrevention(p.UnpackByte())
+	o.TriggerPrice = p.UnpackUint64(false)
+	o.WorstPrice = p.UnpackUint64(false)
+	o.DisplayQuantity = p.UnpackUint64(false)
+	o.Hidden = p.UnpackUint64(false)
+	o.OriginalQuantity = p.UnpackUint64(false)
+	o.Status = OrderStatus(p.UnpackByte())
+	return &o
+}
+
+func (vb *VersionedBalance) Marshal(p *codec.Packer) {
+	p.PackUint64(vb.lastBalance)
+	p.PackUint64(vb.lastBlockHeight)
+	p.PackInt(vb.items.Len())
+	cur := vb.items
+	for i := 0; i < vb.items.Len(); i++ {
+		item, ok := cur.Value.(*VersionedItem)
+		p.PackBool(ok)
+		if ok {
+			p.PackUint64(item.bal)
+			p.PackUint64(item.blkHgt)
+		}
+		cur = cur.Next()
+	}
+}
+
+func unmarshalVersionedBalance(p *codec.Packer, window uint64) *VersionedBalance {
+	vb := &VersionedBalance{
+		lastBalance:     p.UnpackUint64(false),
+		lastBlockHeight: p.UnpackUint64(false),
+	}
+	size := p.UnpackInt(true)
+	if p.Err() != nil {
+		size = int(window + 1)
+	}
+	vb.items = ring.New(size)
+	cur := vb.items
+	for i := 0; i < size && p.Err() == nil; i++ {
+		if p.UnpackBool() {
+			cur.Value = &VersionedItem{
+				bal:    p.UnpackUint64(false),
+				blkHgt: p.UnpackUint64(false),
+			}
+		}
+		cur = cur.Next()
+	}
+	return vb
+}
+
+func (me *MonthlyExecuted) Marshal(p *codec.Packer) {
+	p.PackUint64(me.total)
+	p.PackInt(me.executions.Len())
+	cur := me.executions
+	for i := 0; i < me.executions.Len(); i++ {
+		exec, ok := cur.Value.(*Execution)
+		p.PackBool(ok)
+		if ok {
+			p.PackInt64(exec.Timestamp)
+			p.PackUint64(exec.Quantity)
+		}
+		cur = cur.Next()
+	}
+}
+
+func unmarshalMonthlyExecuted(p *codec.Packer, days int) *MonthlyExecuted {
+	me := &MonthlyExecuted{total: p.UnpackUint64(false)}
+	size := p.UnpackInt(true)
+	if p.Err() != nil {
+		size = days + 1
+	}
+	me.executions = ring.New(size)
+	cur := me.executions
+	for i := 0; i < size && p.Err() == nil; i++ {
+		if p.UnpackBool() {
+			cur.Value = &Execution{
+				Timestamp: p.UnpackInt64(false),
+				Quantity:  p.UnpackUint64(false),
+			}
+		}
+		cur = cur.Next()
+	}
+	return me
+}
+
+// restingOrders returns every order in the book in price-time priority: bids
+// from best to worst price followed by asks from best to worst price.
+func (ob *Orderbook) restingOrders() []*Order {
+	orders := make([]*Order, 0, len(ob.orderMap))
+	for _, side := range []bool{true, false} {
+		for _, level := range ob.restingLevels(side) {
+			orders = append(orders, level...)
+		}
+	}
+	return orders
+}
+
+func (obm *OrderbookManager) sortedPairs() []Pair {
+	pairs := make([]Pair, 0, len(obm.orderbooks))
+	for pair := range obm.orderbooks {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if c := bytes.Compare(pairs[i].BaseTokenID[:], pairs[j].BaseTokenID[:]); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(pairs[i].QuoteTokenID[:], pairs[j].QuoteTokenID[:]) < 0
+	})
+	return pairs
+}
+
+func sortedUsers[V any](m map[crypto.PublicKey]V) []crypto.PublicKey {
+	users := make([]crypto.PublicKey, 0, len(m))
+	for user := range m {
+		users = append(users, user)
+	}
+	sort.Slice(users, func(i, j int) bool { return bytes.Compare(users[i][:], users[j][:]) < 0 })
+	return users
+}
+
+func sortedIDs[V any](m map[ids.ID]V) []ids.ID {
+	res := make([]ids.ID, 0, len(m))
+	for id := range m {
+		res = append(res, id)
+	}
+	sort.Slice(res, func(i, j int) bool { return bytes.Compare(res[i][:], res[j][:]) < 0 })
+	return res
+}
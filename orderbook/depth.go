@@ -0,0 +1,148 @@
+package orderbook
+
+import (
+	"sort"
+
+	"github.com/jaimi-io/clobvm/heap"
+)
+
+// PriceLevel is the visible resting quantity at one price. Orders is only
+// populated when requested, with copies of the orders resting on it.
+type PriceLevel struct {
+	Price     uint64
+	Quantity  uint64
+	NumOrders int
+	Orders    []*Order
+}
+
+// GetDepth returns up to [numPriceLevels] levels of each side of the book,
+// best price first. Hidden iceberg reserves are not included.
+func (ob *Orderbook) GetDepth(numPriceLevels int, includeOrders bool) (bids []PriceLevel, asks []PriceLevel) {
+	prices := ob.getPrices()
+	for i := len(prices) - 1; i >= 0 && len(bids) < numPriceLevels; i-- {
+		if level, ok := ob.priceLevel(ob.maxHeap, uint64(prices[i]), includeOrders); ok {
+			bids = append(bids, level)
+		}
+	}
+	for i := 0; i < len(prices) && len(asks) < numPriceLevels; i++ {
+		if level, ok := ob.priceLevel(ob.minHeap, uint64(prices[i]), includeOrders); ok {
+			asks = append(asks, level)
+		}
+	}
+	return bids, asks
+}
+
+func (ob *Orderbook) priceLevel(side *heap.PriorityQueueHeap[*Order, uint64], price uint64, includeOrders bool) (PriceLevel, bool) {
+	queue, _ := side.Get(price)
+	if queue == nil {
+		return PriceLevel{}, false
+	}
+	level := PriceLevel{
+		Price:     price,
+		Quantity:  ob.volumeMap[price],
+		NumOrders: queue.Len(),
+	}
+	if includeOrders {
+		for _, order := range queue.Values() {
+			level.Orders = append(level.Orders, order.Copy())
+		}
+	}
+	return level, true
+}
+
+// Level is the visible quantity resting on one side of one price. In a level
+// update a zero Quantity means the level was removed.
+type Level struct {
+	Side     bool
+	Price    uint64
+	Quantity uint64
+}
+
+// LevelKey identifies one side of one price.
+type LevelKey struct {
+	Side  bool
+	Price uint64
+}
+
+// Levels returns the visible quantity of every non-empty price level.
+func (ob *Orderbook) Levels() map[LevelKey]uint64 {
+	levels := make(map[LevelKey]uint64)
+	for price, vol := range ob.volumeMap {
+		if vol == 0 {
+			continue
+		}
+		queue, _ := ob.maxHeap.Get(price)
+		levels[LevelKey{queue != nil, price}] = vol
+	}
+	return levels
+}
+
+// Levels returns the visible levels of every pair.
+func (obm *OrderbookManager) Levels() map[Pair]map[LevelKey]uint64 {
+	levels := make(map[Pair]map[LevelKey]uint64, len(obm.orderbooks))
+	for pair, ob := range obm.orderbooks {
+		levels[pair] = ob.Levels()
+	}
+	return levels
+}
+
+// levelVolume returns the visible quantity resting on [key], 0 if none is.
+func (ob *Orderbook) levelVolume(key LevelKey) uint64 {
+	side := ob.minHeap
+	if key.Side {
+		side = ob.maxHeap
+	}
+	if queue, _ := side.Get(key.Price); queue == nil || queue.Len() == 0 {
+		return 0
+	}
+	return ob.volumeMap[key.Price]
+}
+
+// TakeLevelChanges returns every level touched since it was last called with
+// its visible quantity now, 0 if it emptied, as bids then asks, each best
+// price first. A touched level may have ended up where it started.
+func (ob *Orderbook) TakeLevelChanges() []Level {
+	if len(ob.changedLevels) == 0 {
+		return nil
+	}
+	levels := make([]Level, 0, len(ob.changedLevels))
+	for key := range ob.changedLevels {
+		levels = append(levels, Level{key.Side, key.Price, ob.levelVolume(key)})
+	}
+	ob.changedLevels = make(map[LevelKey]struct{})
+	sortLevels(levels)
+	return levels
+}
+
+// TakeLevelChanges returns the touched levels of every pair that has any.
+func (obm *OrderbookManager) TakeLevelChanges() map[Pair][]Level {
+	changes := make(map[Pair][]Level)
+	for pair, ob := range obm.orderbooks {
+		if levels := ob.TakeLevelChanges(); len(levels) > 0 {
+			changes[pair] = levels
+		}
+	}
+	return changes
+}
+
+// SortedLevels returns [levels] as bids then asks, each best price first.
+func SortedLevels(levels map[LevelKey]uint64) []Level {
+	sorted := make([]Level, 0, len(levels))
+	for key, vol := range levels {
+		sorted = append(sorted, Level{key.Side, key.Price, vol})
+	}
+	sortLevels(sorted)
+	return sorted
+}
+
+func sortLevels(levels []Level) {
+	sort.Slice(levels, func(i, j int) bool {
+		if levels[i].Side != levels[j].Side {
+			return levels[i].Side
+		}
+		if levels[i].Side {
+			return levels[i].Price > levels[j].Price
+		}
+		return levels[i].Price < levels[j].Price
+	})
+}
@@ -18,9 +18,9 @@ type MonthlyExecuted struct {
 	total  uint64
 }
 
-func NewMonthlyExecuted(timestamp int64) *MonthlyExecuted {
+func NewMonthlyExecuted(timestamp int64, days int) *MonthlyExecuted {
 	// +1 to store current day (doesn't count towards total)
-	executionsRing := ring.New(consts.NumExecutionHistoryDays + 1)
+	executionsRing := ring.New(days + 1)
 	executionsRing.Value = &Execution{
 		Timestamp: time.Unix(timestamp, 0).Truncate(consts.Day).UnixMilli(),
 		Quantity:  0,
@@ -30,12 +30,15 @@ func NewMonthlyExecuted(timestamp int64) *MonthlyExecuted {
 	}
 }
 
-func (me *MonthlyExecuted) advanceExecs(blockTs int64) {
+// advanceExecs rolls the executions forward to the day of [blockTs] and
+// reports whether they moved.
+func (me *MonthlyExecuted) advanceExecs(blockTs int64) bool {
 	bufferWindow := time.Second * consts.ExecHistoryWindow
 	lastSyncTs := time.Unix(blockTs, 0).Add(-bufferWindow).Truncate(consts.Day).UnixMilli()
 	currentNode := me.executions
 	currentExec := currentNode.Value.(*Execution)
 	currentTs := currentExec.Timestamp
+	advanced := currentTs < lastSyncTs
 
 	// TODO: optimise if currentTs > lastSyncTs - 31 days
 	
@@ -61,6 +64,7 @@ func (me *MonthlyExecuted) advanceExecs(blockTs int64) {
 	}
 
 	me.executions = currentNode
+	return advanced
 }
 
 func (me *MonthlyExecuted) AddExec(timestamp int64, quantity uint64) {
@@ -69,46 +73,52 @@ func (me *MonthlyExecuted) AddExec(timestamp int64, quantity uint64) {
 	exec.Quantity += quantity
 }
 
-func (me *MonthlyExecuted) getMonthlyExecuted(blockTs int64) uint64 {
-	me.advanceExecs(blockTs)
-	return me.total
-}
-
 func (ob *Orderbook) addExec(user crypto.PublicKey, timestamp int64, quantity uint64) {
 	if _, ok := ob.executionHistory[user]; !ok {
-		ob.executionHistory[user] = NewMonthlyExecuted(timestamp)
+		ob.executionHistory[user] = NewMonthlyExecuted(timestamp, ob.fees.HistoryDays)
 	}
 	ob.executionHistory[user].AddExec(timestamp, quantity)
+	ob.changed()
 }
 
-func (ob *Orderbook) GetFee(user crypto.PublicKey, timestamp int64, quantity uint64) uint64 {
-	if _, ok := ob.executionHistory[user]; !ok {
-		return CalculateTakerFee(0, quantity)
+func (ob *Orderbook) monthlyExecuted(user crypto.PublicKey, timestamp int64) uint64 {
+	me, ok := ob.executionHistory[user]
+	if !ok {
+		return 0
 	}
-	monthlyExecuted := ob.executionHistory[user].getMonthlyExecuted(timestamp)
-	return CalculateTakerFee(monthlyExecuted, quantity)
+	if me.advanceExecs(timestamp) {
+		ob.changed()
+	}
+	return me.total
 }
 
-func (ob *Orderbook) GetFeeRate(user crypto.PublicKey, timestamp int64) float64 {
-	if _, ok := ob.executionHistory[user]; !ok {
-		return GetMakerRate(0)
+// GetFee is the taker fee locked with an order on [side] spending [amount].
+// It is 0 if the fee is instead withheld from what the order receives.
+func (ob *Orderbook) GetFee(user crypto.PublicKey, side bool, timestamp int64, amount uint64) uint64 {
+	if !ob.fees.upfront(side) {
+		return 0
 	}
-	monthlyExecuted := ob.executionHistory[user].getMonthlyExecuted(timestamp)
-	return GetMakerRate(monthlyExecuted)
+	return ob.fees.CalculateTakerFee(ob.monthlyExecuted(user, timestamp), amount)
 }
 
-func (ob *Orderbook) RefundFee(user crypto.PublicKey, timestamp int64, quantity uint64) uint64 {
-	if _, ok := ob.executionHistory[user]; !ok {
-		return RefundTakerFee(0, quantity)
+func (ob *Orderbook) GetFeeRate(user crypto.PublicKey, timestamp int64) uint64 {
+	return ob.fees.GetMakerRate(ob.monthlyExecuted(user, timestamp))
+}
+
+func (ob *Orderbook) GetTakerFeeRate(user crypto.PublicKey, timestamp int64) uint64 {
+	return ob.fees.GetTakerRate(ob.monthlyExecuted(user, timestamp))
+}
+
+func (ob *Orderbook) RefundFee(user crypto.PublicKey, side bool, timestamp int64, quantity uint64) uint64 {
+	if !ob.fees.upfront(side) {
+		return 0
 	}
-	monthlyExecuted := ob.executionHistory[user].getMonthlyExecuted(timestamp)
-	return RefundTakerFee(monthlyExecuted, quantity)
+	return ob.fees.RefundTakerFee(ob.monthlyExecuted(user, timestamp), quantity)
 }
 
-func (ob *Orderbook) RefundMarketOrderFee(user crypto.PublicKey, timestamp int64, quantity uint64) uint64 {
-	if _, ok := ob.executionHistory[user]; !ok {
-		return RefundTakerMarketOrderFee(0, quantity)
+func (ob *Orderbook) RefundMarketOrderFee(user crypto.PublicKey, side bool, timestamp int64, quantity uint64) uint64 {
+	if !ob.fees.upfront(side) {
+		return 0
 	}
-	monthlyExecuted := ob.executionHistory[user].getMonthlyExecuted(timestamp)
-	return RefundTakerMarketOrderFee(monthlyExecuted, quantity)
-}
\ No newline at end of file
+	return ob.fees.RefundTakerMarketOrderFee(ob.monthlyExecuted(user, timestamp), quantity)
+}
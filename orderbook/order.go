@@ -10,13 +10,33 @@ import (
 )
 
 type Order struct {
-	ID          ids.ID
-	User        crypto.PublicKey
-	Price       uint64
-	Quantity    uint64
-	Fee         float64
-	Side        bool
-	BlockExpiry uint64
+	ID              ids.ID
+	User            crypto.PublicKey
+	Price           uint64
+	Quantity        uint64 // visible quantity
+	Fee             uint64 // maker rate in basis points
+	Side            bool
+	BlockExpiry     uint64
+	TimeExpiry      int64 // unix seconds, 0 if good till block only
+	TimeInForce     TimeInForce
+	SelfTradePrevention SelfTradePrevention
+	TriggerPrice    uint64
+	WorstPrice      uint64 // market orders only, the price they fill up to
+	QuoteAmount     uint64 // quote-denominated market buys only, the balance they spend
+	DisplayQuantity uint64 // iceberg peak, 0 if fully visible
+	Hidden          uint64 // iceberg reserve not yet shown
+	OriginalQuantity uint64
+	Status          OrderStatus
+
+	// Set while the order is matched on entry
+	selfTraded         uint64 // quantity taken off by self-trade prevention
+	selfTradeCancelled bool   // the rest is cancelled by self-trade prevention
+}
+
+// Copy returns a snapshot of [o] that later matching does not change.
+func (o *Order) Copy() *Order {
+	c := *o
+	return &c
 }
 
 func (o *Order) GetID() ids.ID {
@@ -35,7 +55,24 @@ func NewOrder (id ids.ID, user crypto.PublicKey, price uint64, quantity uint64,
 		Quantity: utils.BalanceToQuantity(quantity),
 		Side: side,
 		BlockExpiry: currentBlock + blockWindow,
+		OriginalQuantity: utils.BalanceToQuantity(quantity),
+	}
+}
+
+// hide moves everything above the iceberg peak into the hidden reserve.
+func (o *Order) hide() {
+	if o.DisplayQuantity == 0 || o.Quantity <= o.DisplayQuantity {
+		return
 	}
+	o.Hidden += o.Quantity - o.DisplayQuantity
+	o.Quantity = o.DisplayQuantity
+}
+
+// refresh shows the next peak of an iceberg order once the previous one has
+// been filled.
+func (o *Order) refresh() {
+	o.Quantity = min(o.DisplayQuantity, o.Hidden)
+	o.Hidden -= o.Quantity
 }
 
 func (o *Order) String() string {
@@ -2,38 +2,61 @@ package orderbook
 
 import (
 	"github.com/ava-labs/avalanchego/ids"
-	"github.com/jaimi-io/clobvm/consts"
 	"github.com/jaimi-io/clobvm/metrics"
 )
 
-func (ob *Orderbook) AddToEviction(orderID ids.ID, blockHeight uint64) {
-	blockExpiry := blockHeight + consts.EvictionBlockWindow
-	if _, ok := ob.evictionMap[blockExpiry]; !ok {
-		ob.evictionMap[blockExpiry] = make(map[ids.ID]struct{})
+// AddToEviction schedules [order] to be cancelled at the start of the block at
+// its BlockExpiry height, or of the first block timestamped at or after its
+// TimeExpiry if it has one, whichever comes first.
+func (ob *Orderbook) AddToEviction(order *Order) {
+	if _, ok := ob.evictionMap[order.BlockExpiry]; !ok {
+		ob.evictionMap[order.BlockExpiry] = make(map[ids.ID]struct{})
+	}
+	ob.evictionMap[order.BlockExpiry][order.ID] = struct{}{}
+	if order.TimeExpiry > 0 {
+		ob.timeEvictions.Add(order, order.ID, order.TimeExpiry)
 	}
-	ob.evictionMap[blockExpiry][orderID] = struct{}{}
 }
 
-func (ob *Orderbook) Evict(blockNumber uint64, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
-	ordersToEvict := ob.evictionMap[blockNumber]
-	if ordersToEvict == nil {
+func (ob *Orderbook) removeFromEviction(order *Order) {
+	if orderIDs, ok := ob.evictionMap[order.BlockExpiry]; ok {
+		delete(orderIDs, order.ID)
+		if len(orderIDs) == 0 {
+			delete(ob.evictionMap, order.BlockExpiry)
+		}
+	}
+	if order.TimeExpiry == 0 {
 		return
 	}
-	var i int;
-	for orderID := range ordersToEvict {
-		order := ob.Get(orderID)
-		if order == nil {
-			continue
-		}
-		i++;
-		ob.Cancel(order, pendingAmounts, metrics)
+	if queue, _ := ob.timeEvictions.Get(order.TimeExpiry); queue != nil && queue.Contains(order.ID) {
+		_ = ob.timeEvictions.Remove(order.ID, order.TimeExpiry)
+	}
+}
+
+func (ob *Orderbook) evict(orderID ids.ID, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
+	if stop := ob.stops.get(orderID); stop != nil {
+		ob.cancelStop(stop, Evicted, pendingAmounts, metrics)
+	} else if order := ob.Get(orderID); order != nil {
+		ob.cancel(order, Evicted, pendingAmounts, metrics)
+	}
+}
+
+func (ob *Orderbook) Evict(blockNumber uint64, blockTs int64, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
+	for orderID := range ob.evictionMap[blockNumber] {
+		ob.evict(orderID, pendingAmounts, metrics)
 	}
 	delete(ob.evictionMap, blockNumber)
+
+	for ob.timeEvictions.Len() > 0 && ob.timeEvictions.Peek().Priority() <= blockTs {
+		order := ob.timeEvictions.Peek().Peek()
+		ob.evict(order.ID, pendingAmounts, metrics)
+		ob.removeFromEviction(order)
+	}
 }
 
-func (obm *OrderbookManager) EvictAllPairs(blockNumber uint64, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
+func (obm *OrderbookManager) EvictAllPairs(blockNumber uint64, blockTs int64, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
 	for pair := range obm.orderbooks {
 		ob := obm.orderbooks[pair]
-		ob.Evict(blockNumber, pendingAmounts, metrics)
+		ob.Evict(blockNumber, blockTs, pendingAmounts, metrics)
 	}
-}
\ No newline at end of file
+}
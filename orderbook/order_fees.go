@@ -1,27 +1,121 @@
 package orderbook
 
 import (
-	"math"
+	"errors"
+	"fmt"
+	"math/bits"
 
+	"github.com/jaimi-io/clobvm/consts"
 	"github.com/jaimi-io/clobvm/utils"
+	"github.com/jaimi-io/hypersdk/crypto"
 )
 
+var ErrInvalidFeeSchedule = errors.New("invalid fee schedule")
+
+// Fee is a volume tier. Rates are in basis points of the traded amount.
 type Fee struct {
-	Amount uint64
-	MakerRate float64
-	TakerRate float64
+	Amount    uint64 `json:"amount"`
+	MakerRate uint64 `json:"makerRate"`
+	TakerRate uint64 `json:"takerRate"`
+}
+
+// FeePolicy is the token orders pay their fees in.
+type FeePolicy byte
+
+const (
+	// FeeSpentToken charges fees in the token an order gives up. The taker fee
+	// is locked with the order and what is not used is refunded.
+	FeeSpentToken FeePolicy = iota
+	// FeeQuoteToken charges every fee in the quote token. Buys pay as with
+	// FeeSpentToken while sells have their fee withheld from what they receive.
+	FeeQuoteToken
+)
+
+func (fp FeePolicy) Valid() bool {
+	return fp <= FeeQuoteToken
 }
 
-var fees = []Fee{
-	{Amount: 0 * utils.MinBalance(), MakerRate: 0.001, TakerRate: 0.0015},
-	{Amount: 100_000 * utils.MinBalance(), MakerRate: 0.0009, TakerRate: 0.001},
-	{Amount: 1_000_000 * utils.MinBalance(), MakerRate: 0.0008, TakerRate: 0.001},
-	{Amount: 10_000_000 * utils.MinBalance(), MakerRate: 0.0007, TakerRate: 0.0009},
+func (fp FeePolicy) String() string {
+	switch fp {
+	case FeeSpentToken:
+		return "spent"
+	case FeeQuoteToken:
+		return "quote"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseFeePolicy returns the policy named [s] by FeePolicy.String.
+func ParseFeePolicy(s string) (FeePolicy, error) {
+	for fp := FeeSpentToken; fp.Valid(); fp++ {
+		if fp.String() == s {
+			return fp, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: unknown fee policy %q", ErrInvalidFeeSchedule, s)
+}
+
+// FeeSchedule is how a chain charges fees: the volume tiers in ascending
+// order of Amount, the number of days of executed volume a user's tier is
+// picked by, the token fees are paid in and who they are paid to. Fees are
+// burned if there is no Recipient.
+type FeeSchedule struct {
+	Tiers       []Fee
+	HistoryDays int
+	Policy      FeePolicy
+	Recipient   crypto.PublicKey
+}
+
+func DefaultFeeTiers() []Fee {
+	return []Fee{
+		{Amount: 0 * utils.MinBalance(), MakerRate: 10, TakerRate: 15},
+		{Amount: 100_000 * utils.MinBalance(), MakerRate: 9, TakerRate: 10},
+		{Amount: 1_000_000 * utils.MinBalance(), MakerRate: 8, TakerRate: 10},
+		{Amount: 10_000_000 * utils.MinBalance(), MakerRate: 7, TakerRate: 9},
+	}
+}
+
+func DefaultFeeSchedule() *FeeSchedule {
+	return &FeeSchedule{
+		Tiers:       DefaultFeeTiers(),
+		HistoryDays: consts.NumExecutionHistoryDays,
+		Policy:      FeeSpentToken,
+	}
 }
 
-func getFeeRates(monthlyExecuted uint64) (float64, float64) {
-	var currentMakerRate, currentTakerRate float64
-	for _, fee := range fees {
+// Verify checks that every volume has a tier and that no rate can make a
+// refund exceed what was charged.
+func (fs *FeeSchedule) Verify() error {
+	if len(fs.Tiers) == 0 || fs.Tiers[0].Amount != 0 {
+		return fmt.Errorf("%w: first tier must start at 0", ErrInvalidFeeSchedule)
+	}
+	for i, fee := range fs.Tiers {
+		if i > 0 && fee.Amount <= fs.Tiers[i-1].Amount {
+			return fmt.Errorf("%w: tiers must be in ascending order", ErrInvalidFeeSchedule)
+		}
+		if fee.MakerRate > fee.TakerRate || fee.TakerRate > consts.BasisPoints {
+			return fmt.Errorf("%w: tier %d rates must satisfy maker <= taker <= %d", ErrInvalidFeeSchedule, i, consts.BasisPoints)
+		}
+	}
+	if fs.HistoryDays <= 0 {
+		return fmt.Errorf("%w: history days must be positive", ErrInvalidFeeSchedule)
+	}
+	if !fs.Policy.Valid() {
+		return fmt.Errorf("%w: unknown fee policy %d", ErrInvalidFeeSchedule, fs.Policy)
+	}
+	return nil
+}
+
+// upfront reports whether orders on [side] lock their taker fee with their
+// collateral rather than having it withheld when filled.
+func (fs *FeeSchedule) upfront(side bool) bool {
+	return side || fs.Policy == FeeSpentToken
+}
+
+func (fs *FeeSchedule) getFeeRates(monthlyExecuted uint64) (uint64, uint64) {
+	var currentMakerRate, currentTakerRate uint64
+	for _, fee := range fs.Tiers {
 		if monthlyExecuted >= fee.Amount {
 			currentMakerRate = fee.MakerRate
 			currentTakerRate = fee.TakerRate
@@ -32,26 +126,48 @@ func getFeeRates(monthlyExecuted uint64) (float64, float64) {
 	return currentMakerRate, currentTakerRate
 }
 
-func CalculateTakerFee(monthlyExecuted uint64, amount uint64) uint64 {
-	_, takerRate := getFeeRates(monthlyExecuted)
-	takerFee := float64(amount) * takerRate
-	return uint64(math.Ceil(takerFee))
+// applyRate returns amount * rate / BasisPoints rounded down. The product is
+// computed in 128 bits so it cannot overflow; rate must not exceed
+// BasisPoints, which keeps the quotient within 64 bits.
+func applyRate(amount uint64, rate uint64) uint64 {
+	hi, lo := bits.Mul64(amount, rate)
+	quo, _ := bits.Div64(hi, lo, consts.BasisPoints)
+	return quo
 }
 
-func GetMakerRate(monthlyExecuted uint64) float64 {
-	makerRate, _ := getFeeRates(monthlyExecuted)
+// applyRateCeil is applyRate rounded up.
+func applyRateCeil(amount uint64, rate uint64) uint64 {
+	hi, lo := bits.Mul64(amount, rate)
+	quo, rem := bits.Div64(hi, lo, consts.BasisPoints)
+	if rem > 0 {
+		quo++
+	}
+	return quo
+}
+
+// Fees charged are rounded up and fees refunded are rounded down, so a user
+// can never get back more than they paid.
+func (fs *FeeSchedule) CalculateTakerFee(monthlyExecuted uint64, amount uint64) uint64 {
+	_, takerRate := fs.getFeeRates(monthlyExecuted)
+	return applyRateCeil(amount, takerRate)
+}
+
+func (fs *FeeSchedule) GetMakerRate(monthlyExecuted uint64) uint64 {
+	makerRate, _ := fs.getFeeRates(monthlyExecuted)
 	return makerRate
 }
 
-func RefundTakerFee(monthlyExecuted uint64, amount uint64) uint64 {
-	makerRate, takerRate := getFeeRates(monthlyExecuted)
-	refundRate := takerRate - makerRate
-	refund := float64(amount) * refundRate
-	return uint64(math.Ceil(refund))
+func (fs *FeeSchedule) GetTakerRate(monthlyExecuted uint64) uint64 {
+	_, takerRate := fs.getFeeRates(monthlyExecuted)
+	return takerRate
+}
+
+func (fs *FeeSchedule) RefundTakerFee(monthlyExecuted uint64, amount uint64) uint64 {
+	makerRate, takerRate := fs.getFeeRates(monthlyExecuted)
+	return applyRate(amount, takerRate-makerRate)
 }
 
-func RefundTakerMarketOrderFee(monthlyExecuted uint64, amount uint64) uint64 {
-	_, takerRate := getFeeRates(monthlyExecuted)
-	refund := float64(amount) * takerRate
-	return uint64(math.Ceil(refund))
-}
\ No newline at end of file
+func (fs *FeeSchedule) RefundTakerMarketOrderFee(monthlyExecuted uint64, amount uint64) uint64 {
+	_, takerRate := fs.getFeeRates(monthlyExecuted)
+	return applyRate(amount, takerRate)
+}
@@ -0,0 +1,105 @@
+package orderbook
+
+import (
+	"math/big"
+	"testing"
+	"testing/quick"
+
+	ametrics "github.com/ava-labs/avalanchego/api/metrics"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/jaimi-io/clobvm/consts"
+	"github.com/jaimi-io/clobvm/metrics"
+	"github.com/jaimi-io/clobvm/utils"
+	"github.com/jaimi-io/hypersdk/crypto"
+)
+
+// tierVolumes returns a monthly executed volume inside every fee tier.
+func tierVolumes(fs *FeeSchedule) []uint64 {
+	volumes := make([]uint64, 0, len(fs.Tiers))
+	for _, fee := range fs.Tiers {
+		volumes = append(volumes, fee.Amount)
+	}
+	return volumes
+}
+
+func TestApplyRateMatchesBigInt(t *testing.T) {
+	f := func(amount uint64, rate uint64) bool {
+		rate %= consts.BasisPoints + 1
+		product := new(big.Int).Mul(new(big.Int).SetUint64(amount), new(big.Int).SetUint64(rate))
+		quo, rem := new(big.Int).QuoRem(product, new(big.Int).SetUint64(consts.BasisPoints), new(big.Int))
+		ceil := new(big.Int).Set(quo)
+		if rem.Sign() > 0 {
+			ceil.Add(ceil, big.NewInt(1))
+		}
+		return applyRate(amount, rate) == quo.Uint64() && applyRateCeil(amount, rate) == ceil.Uint64()
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A resting limit order gets the taker/maker difference back when it is
+// placed and the maker fee back when it is cancelled. Together these must
+// never exceed the taker fee charged up front, and may only fall short of it
+// by the rounding of each of the two refunds.
+func TestLimitOrderFeeRefundNoDrift(t *testing.T) {
+	fs := DefaultFeeSchedule()
+	for _, monthlyExecuted := range tierVolumes(fs) {
+		f := func(amount uint64) bool {
+			charged := fs.CalculateTakerFee(monthlyExecuted, amount)
+			refunded := fs.RefundTakerFee(monthlyExecuted, amount) + applyRate(amount, fs.GetMakerRate(monthlyExecuted))
+			if refunded > charged || charged-refunded > 2 {
+				return false
+			}
+			exact := amount - amount%consts.BasisPoints
+			return fs.CalculateTakerFee(monthlyExecuted, exact) ==
+				fs.RefundTakerFee(monthlyExecuted, exact)+applyRate(exact, fs.GetMakerRate(monthlyExecuted))
+		}
+		if err := quick.Check(f, nil); err != nil {
+			t.Fatalf("monthly executed %d: %v", monthlyExecuted, err)
+		}
+	}
+}
+
+func TestMarketOrderFeeRefundNoDrift(t *testing.T) {
+	fs := DefaultFeeSchedule()
+	for _, monthlyExecuted := range tierVolumes(fs) {
+		f := func(amount uint64) bool {
+			charged := fs.CalculateTakerFee(monthlyExecuted, amount)
+			refunded := fs.RefundTakerMarketOrderFee(monthlyExecuted, amount)
+			return refunded <= charged && charged-refunded <= 1
+		}
+		if err := quick.Check(f, nil); err != nil {
+			t.Fatalf("monthly executed %d: %v", monthlyExecuted, err)
+		}
+	}
+}
+
+// Placing and cancelling a sell order must return at most what AddOrder
+// locked for it, i.e. the quantity plus the taker fee.
+func TestCancelledOrderRefundsAtMostLocked(t *testing.T) {
+	m, err := metrics.NewMetrics(ametrics.NewMultiGatherer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := crypto.PublicKey{1}
+	f := func(quantity uint32, price uint32) bool {
+		ob := NewOrderbook(Pair{ids.GenerateTestID(), ids.GenerateTestID()}, DefaultFeeSchedule(), consts.PendingBlockWindow)
+		balance := utils.QuantityToBalance(uint64(quantity) + 1)
+		locked := balance + ob.GetFee(user, false, 0, balance)
+
+		var pendingAmounts []PendingAmt
+		order := NewOrder(ids.GenerateTestID(), user, uint64(price)+1, balance, false, 1, 1)
+		ob.Add(order, 1, 0, &pendingAmounts, m)
+		ob.Cancel(order, &pendingAmounts, m)
+
+		var refunded uint64
+		for _, pendingAmt := range pendingAmounts {
+			refunded += pendingAmt.Amount
+		}
+		return refunded <= locked
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Fatal(err)
+	}
+}
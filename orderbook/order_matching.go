@@ -1,6 +1,8 @@
 package orderbook
 
 import (
+	"sort"
+
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/jaimi-io/clobvm/heap"
 	"github.com/jaimi-io/clobvm/metrics"
@@ -16,6 +18,16 @@ func GetAmountFn(side bool, isFilled bool, pair Pair) func (q, p uint64) (uint64
 	return func(q, p uint64) (uint64, ids.ID) { return q, pair.BaseTokenID }
 }
 
+// SlippagePrice is the worst price a market order on [side] referencing
+// [price] fills at with [maxSlippage] basis points of slippage.
+func SlippagePrice(side bool, price uint64, maxSlippage uint64) uint64 {
+	slippage := applyRate(price, maxSlippage)
+	if side {
+		return price + slippage
+	}
+	return price - slippage
+}
+
 func min(a, b uint64) uint64 {
 	if a < b {
 		return a
@@ -44,21 +56,50 @@ func (ob *Orderbook) getOppositeHeap(side bool) *heap.PriorityQueueHeap[*Order,
 	return ob.maxHeap
 }
 
-func (ob *Orderbook) fillPriceLevel(heap *heap.PriorityQueueHeap[*Order, uint64], order *Order, blockTs int64, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) uint64 {
+// restingLevels returns the non-empty price levels resting on [side], best
+// price first.
+func (ob *Orderbook) restingLevels(side bool) [][]*Order {
+	var levels [][]*Order
+	for _, level := range ob.getOppositeHeap(!side).Values() {
+		if len(level) > 0 {
+			levels = append(levels, level)
+		}
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		if side {
+			return levels[i][0].Price > levels[j][0].Price
+		}
+		return levels[i][0].Price < levels[j][0].Price
+	})
+	return levels
+}
+
+// fillPriceLevel fills [order] against the best level of [heap], returning
+// the quote value filled and the taker fees recorded for [order].
+func (ob *Orderbook) fillPriceLevel(heap *heap.PriorityQueueHeap[*Order, uint64], order *Order, blockTs int64, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) (uint64, uint64) {
 	queue := heap.Peek()
-	var filledQuote uint64
-	for queue.Len() > 0 && 0 < order.Quantity {
+	var filledQuote, takerFee uint64
+	for queue.Len() > 0 && 0 < order.Quantity && !order.selfTradeCancelled {
 		takerOrder := queue.Peek()
+		if takerOrder.User == order.User && order.SelfTradePrevention.prevents() {
+			ob.preventSelfTrade(queue, takerOrder, order, pendingAmounts, metrics)
+			continue
+		}
 		toFill := min(takerOrder.Quantity, order.Quantity)
 		takerOrder.Quantity -= toFill
 		order.Quantity -= toFill
-		ob.volumeMap[takerOrder.Price] -= toFill
+		ob.subVolume(takerOrder.Price, takerOrder.Side, toFill)
 
-		if takerOrder.Quantity == 0 {
+		if takerOrder.Quantity == 0 && takerOrder.Hidden > 0 {
+			ob.refreshIceberg(queue.Pop(), queue, metrics)
+		} else if takerOrder.Quantity == 0 {
 			ob.Remove(queue.Pop(), metrics)
+			ob.close(takerOrder, Filled)
 		}
 
-		ob.fillAmount(takerOrder, toFill, pendingAmounts)
+		trade := ob.recordTrade(takerOrder, order, toFill, blockTs)
+		ob.fillAmount(takerOrder, toFill, trade.MakerFee, pendingAmounts)
+		takerFee += trade.TakerFee
 		ob.addExec(takerOrder.User, blockTs, toFill)
 		filledQuote += takerOrder.Price * toFill
 		metrics.OrderAmountSub(toFill)
@@ -70,14 +111,22 @@ func (ob *Orderbook) fillPriceLevel(heap *heap.PriorityQueueHeap[*Order, uint64]
 		heap.PopQueue()
 	}
 
-	return filledQuote
+	return filledQuote, takerFee
+}
+
+// refreshIceberg shows the next peak of a filled iceberg order and moves it to
+// the back of its price level, losing time priority.
+func (ob *Orderbook) refreshIceberg(order *Order, level *queue.LinkedMapQueue[*Order, uint64], metrics *metrics.Metrics) {
+	order.refresh()
+	ob.addVolume(order.Price, order.Side, order.Quantity)
+	level.Push(order, order.ID)
+	metrics.OrderAmountAdd(order.Quantity)
 }
 
-func (ob *Orderbook) fillMaker(order *Order, blockTs int64, prevQuantity uint64, filledQuote uint64, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
-	filledQuantity := prevQuantity - order.Quantity
+func (ob *Orderbook) fillMaker(order *Order, blockTs int64, filledQuantity uint64, filledQuote uint64, takerFee uint64, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
 	oldOrderPrice := order.Price
 	order.Price = filledQuote / filledQuantity
-	ob.fillAmount(order, filledQuantity, pendingAmounts)
+	ob.fillAmount(order, filledQuantity, takerFee, pendingAmounts)
 	order.Price = oldOrderPrice
 	ob.addExec(order.User, blockTs, filledQuantity)
 	metrics.OrderFillsNum()
@@ -87,63 +136,70 @@ func (ob *Orderbook) fillMaker(order *Order, blockTs int64, prevQuantity uint64,
 func (ob *Orderbook) matchLimitOrder(order *Order, blockTs int64, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
 	heap := ob.getOppositeHeap(order.Side)
 	matchPriceFn := getMatchPriceFn(order.Side)
-	var filledQuote uint64
+	var filledQuote, takerFee uint64
 	prevQuantity := order.Quantity
 
-	for heap.Len() > 0 && matchPriceFn(heap.Peek().Priority(), order.Price) && 0 < order.Quantity {
-		filledQuote += ob.fillPriceLevel(heap, order, blockTs, pendingAmounts, metrics)
+	for heap.Len() > 0 && matchPriceFn(heap.Peek().Priority(), order.Price) && 0 < order.Quantity && !order.selfTradeCancelled {
+		quote, fee := ob.fillPriceLevel(heap, order, blockTs, pendingAmounts, metrics)
+		filledQuote += quote
+		takerFee += fee
 	}
 
-	if prevQuantity > order.Quantity {
-		ob.fillMaker(order, blockTs, prevQuantity, filledQuote, pendingAmounts, metrics)
+	if filledQuantity := prevQuantity - order.Quantity - order.selfTraded; filledQuantity > 0 {
+		ob.fillMaker(order, blockTs, filledQuantity, filledQuote, takerFee, pendingAmounts, metrics)
+		ob.refundImprovement(order, blockTs, filledQuantity, filledQuote, pendingAmounts)
 	} 
 }
 
-func (ob *Orderbook) checkSlippage(heap *heap.PriorityQueueHeap[*Order, uint64], order *Order, pendingAmounts *[]PendingAmt) bool {
-	initial := order.Quantity * order.Price
-	qty := order.Quantity
-	var potential uint64
-	var foo []*queue.LinkedMapQueue[*Order, uint64]
-	for heap.Len() > 0 && 0 < qty {
-		queue := heap.Peek()
-		price := queue.Priority()
-		vol := ob.volumeMap[queue.Priority()]
-		toFill := min(vol, qty)
-		qty -= toFill
-		potential += toFill * price
-		if qty != 0 {
-			foo = append(foo, heap.PopQueue())
+// matchQuoteOrder fills a market buy that spends its quote amount rather than
+// buying a set quantity, level by level up to its worst price, returning
+// whether the amount was spent. Whatever is left, down to dust too small to
+// buy with, is refunded along with the taker fee charged on it.
+func (ob *Orderbook) matchQuoteOrder(order *Order, blockTs int64, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) bool {
+	heap := ob.getOppositeHeap(order.Side)
+	matchPriceFn := getMatchPriceFn(order.Side)
+	remaining := order.QuoteAmount
+	var filledQuantity, filledQuote, takerFee uint64
+	var spent bool
+
+	for !spent && heap.Len() > 0 && matchPriceFn(heap.Peek().Priority(), order.Price) && !order.selfTradeCancelled {
+		order.Quantity = remaining / utils.MinQuantity() * utils.MinPrice() / heap.Peek().Priority()
+		if prevQuantity := order.Quantity; prevQuantity > 0 {
+			quote, fee := ob.fillPriceLevel(heap, order, blockTs, pendingAmounts, metrics)
+			filledQuantity += prevQuantity - order.Quantity - order.selfTraded
+			filledQuote += quote
+			takerFee += fee
+			// Rounded up so the makers are never paid more than was spent
+			remaining -= (quote + utils.MinPrice() - 1) / utils.MinPrice() * utils.MinQuantity()
 		}
+		order.selfTraded = 0
+		spent = order.Quantity == 0
 	}
-	for _, q := range foo {
-		heap.PushQueue(q)
+
+	order.Quantity = 0
+	order.OriginalQuantity = filledQuantity
+	remaining += ob.RefundMarketOrderFee(order.User, order.Side, blockTs, remaining)
+	if remaining > 0 {
+		*pendingAmounts = append(*pendingAmounts, PendingAmt{order.User, ob.pair.QuoteTokenID, remaining})
 	}
-	if initial < potential {
-		ob.refundAmount(order, order.Quantity, pendingAmounts)
-		return false
+	if filledQuantity > 0 {
+		ob.fillMaker(order, blockTs, filledQuantity, filledQuote, takerFee, pendingAmounts, metrics)
 	}
-	return true
+	return spent && !order.selfTradeCancelled
 }
 
-func (ob *Orderbook) matchMarketOrder(order *Order, blockTs int64, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
-	heap := ob.getOppositeHeap(order.Side)
-	var filledQuote uint64
-	prevQuantity := order.Quantity
-
-	if order.Side && !ob.checkSlippage(heap, order, pendingAmounts){
+// refundImprovement returns what a buy locked at its own price for
+// [filledQuantity] beyond the [filledQuote] it filled for, along with the
+// taker fee charged on the difference.
+func (ob *Orderbook) refundImprovement(order *Order, blockTs int64, filledQuantity uint64, filledQuote uint64, pendingAmounts *[]PendingAmt) {
+	if !order.Side {
 		return
 	}
-
-	for heap.Len() > 0 && 0 < order.Quantity {
-		filledQuote += ob.fillPriceLevel(heap, order, blockTs, pendingAmounts, metrics)
+	amount := (order.Price * filledQuantity - filledQuote) / utils.MinPrice() * utils.MinQuantity()
+	amount += ob.RefundMarketOrderFee(order.User, order.Side, blockTs, amount)
+	if amount > 0 {
+		*pendingAmounts = append(*pendingAmounts, PendingAmt{order.User, ob.pair.QuoteTokenID, amount})
 	}
-
-	if order.Side {
-		toRefund := order.Price * prevQuantity - filledQuote
-		ob.refundAmount(order, toRefund, pendingAmounts)
-	}
-
-	ob.fillMaker(order, blockTs, prevQuantity, filledQuote, pendingAmounts, metrics)
 }
 
 type PendingAmt struct {
@@ -154,15 +210,24 @@ type PendingAmt struct {
 
 func (ob *Orderbook) toPendingAmount(order *Order, quantity uint64, isFilled bool, pendingAmounts *[]PendingAmt) {
 	getAmount := GetAmountFn(order.Side, isFilled, ob.pair)
-	if !isFilled && order.Fee > 0 {
-		quantity += uint64(float64(quantity) * order.Fee)
+	if !isFilled && order.Fee > 0 && ob.fees.upfront(order.Side) {
+		quantity += applyRate(quantity, order.Fee)
 	}
 	amount, tokenID := getAmount(quantity, order.Price)
 	*pendingAmounts = append(*pendingAmounts, PendingAmt{order.User, tokenID, amount * utils.MinQuantity()})
 }
 
-func (ob *Orderbook) fillAmount(order *Order, quantity uint64, pendingAmounts *[]PendingAmt) {
-	ob.toPendingAmount(order, quantity, true, pendingAmounts)
+// fillAmount credits [order] with what it receives for [quantity] filled,
+// less the [fee] recorded on its trades if it was not locked up front.
+func (ob *Orderbook) fillAmount(order *Order, quantity uint64, fee uint64, pendingAmounts *[]PendingAmt) {
+	if ob.fees.upfront(order.Side) {
+		ob.toPendingAmount(order, quantity, true, pendingAmounts)
+		return
+	}
+	getAmount := GetAmountFn(order.Side, true, ob.pair)
+	amount, tokenID := getAmount(quantity, order.Price)
+	amount *= utils.MinQuantity()
+	*pendingAmounts = append(*pendingAmounts, PendingAmt{order.User, tokenID, amount - min(fee, amount)})
 }
 
 func (ob *Orderbook) refundAmount(order *Order, quantity uint64, pendingAmounts *[]PendingAmt) {
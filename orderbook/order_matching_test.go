@@ -0,0 +1,194 @@
+package orderbook
+
+import (
+	"testing"
+
+	"github.com/jaimi-io/clobvm/utils"
+	"github.com/jaimi-io/hypersdk/crypto"
+)
+
+// iceberg rests an iceberg limit order of [user] showing [display] of its
+// [quantity] and returns it.
+func (tb *testBook) iceberg(user crypto.PublicKey, side bool, price uint64, quantity uint64, display uint64) *Order {
+	order := tb.order(user, side, price, quantity)
+	order.DisplayQuantity = display
+	return tb.add(order)
+}
+
+func TestIcebergShowsPeak(t *testing.T) {
+	tb := newTestBook(t)
+	iceberg := tb.iceberg(bob, false, 10_000, 5, 2)
+	if iceberg.Quantity != 2 || iceberg.Hidden != 3 {
+		t.Fatalf("showing %d with %d hidden, want 2 with 3 hidden", iceberg.Quantity, iceberg.Hidden)
+	}
+	_, asks := tb.GetDepth(1, false)
+	if len(asks) != 1 || asks[0].Quantity != 2 {
+		t.Fatalf("asks %v, want only the peak of 2", asks)
+	}
+}
+
+func TestIcebergRefreshLosesTimePriority(t *testing.T) {
+	tb := newTestBook(t)
+	iceberg := tb.iceberg(bob, false, 10_000, 5, 2)
+	behind := tb.place(carol, false, 10_000, 2)
+
+	tb.place(alice, true, 10_000, 2)
+	if iceberg.Quantity != 2 || iceberg.Hidden != 1 {
+		t.Fatalf("showing %d with %d hidden, want 2 with 1 hidden", iceberg.Quantity, iceberg.Hidden)
+	}
+	if behind.Quantity != 2 {
+		t.Fatal("order behind the iceberg filled before its peak")
+	}
+
+	// The next peak rests behind the order that was behind the first one
+	tb.place(alice, true, 10_000, 2)
+	if behind.Status != Filled {
+		t.Fatalf("order behind the first peak is %s, want filled before the refreshed peak", behind.Status)
+	}
+	if iceberg.Quantity != 2 || iceberg.Hidden != 1 {
+		t.Fatalf("refreshed peak filled out of turn: showing %d with %d hidden", iceberg.Quantity, iceberg.Hidden)
+	}
+}
+
+func TestIcebergLastPeakShowsRemainder(t *testing.T) {
+	tb := newTestBook(t)
+	iceberg := tb.iceberg(bob, false, 10_000, 5, 2)
+
+	tb.place(alice, true, 10_000, 4)
+	if iceberg.Quantity != 1 || iceberg.Hidden != 0 {
+		t.Fatalf("showing %d with %d hidden, want the last 1 shown", iceberg.Quantity, iceberg.Hidden)
+	}
+	_, asks := tb.GetDepth(1, false)
+	if len(asks) != 1 || asks[0].Quantity != 1 {
+		t.Fatalf("asks %v, want the last peak of 1", asks)
+	}
+
+	tb.place(alice, true, 10_000, 1)
+	if iceberg.Status != Filled {
+		t.Fatalf("iceberg is %s, want filled", iceberg.Status)
+	}
+}
+
+func TestIcebergFilledThroughRefreshesByOneOrder(t *testing.T) {
+	tb := newTestBook(t)
+	iceberg := tb.iceberg(bob, false, 10_000, 5, 2)
+	behind := tb.place(carol, false, 10_000, 2)
+	tb.pendingAmounts = nil
+
+	order := tb.place(alice, true, 10_000, 7)
+	if order.Status != Filled || iceberg.Status != Filled || behind.Status != Filled {
+		t.Fatalf("taker %s, iceberg %s, behind %s, want all filled", order.Status, iceberg.Status, behind.Status)
+	}
+	if got := tb.pending(alice, tb.pair.BaseTokenID); got != utils.QuantityToBalance(7) {
+		t.Fatalf("taker received %d, want %d", got, utils.QuantityToBalance(7))
+	}
+	if _, asks := tb.GetDepth(1, false); len(asks) != 0 {
+		t.Fatalf("asks %v, want none", asks)
+	}
+}
+
+// quoteBuy adds a market buy of [user] spending [quoteAmount] of the quote
+// token up to [worstPrice] and returns it.
+func (tb *testBook) quoteBuy(user crypto.PublicKey, worstPrice uint64, quoteAmount uint64) *Order {
+	order := tb.order(user, true, 0, 0)
+	order.WorstPrice = worstPrice
+	order.QuoteAmount = quoteAmount
+	return tb.add(order)
+}
+
+func TestQuoteOrderStopsAtWorstPrice(t *testing.T) {
+	tb := newTestBook(t)
+	tb.place(bob, false, 10_000, 2)
+	tb.place(bob, false, 20_000, 1)
+	above := tb.place(bob, false, 30_000, 5)
+	tb.pendingAmounts = nil
+
+	// 2 + 2 of the 10 quote units are spent before the price passes the cap
+	order := tb.quoteBuy(alice, 20_000, utils.QuantityToBalance(10))
+	if order.Status != Cancelled || order.OriginalQuantity != 3 {
+		t.Fatalf("order is %s having bought %d, want cancelled having bought 3", order.Status, order.OriginalQuantity)
+	}
+	if above.Quantity != 5 {
+		t.Fatalf("order above the worst price shows %d, want 5", above.Quantity)
+	}
+	if got := tb.pending(alice, tb.pair.BaseTokenID); got != utils.QuantityToBalance(3) {
+		t.Fatalf("received %d, want %d", got, utils.QuantityToBalance(3))
+	}
+	unspent := utils.QuantityToBalance(6)
+	want := unspent + tb.RefundMarketOrderFee(alice, true, 0, unspent)
+	if got := tb.pending(alice, tb.pair.QuoteTokenID); got != want {
+		t.Fatalf("refunded %d, want %d", got, want)
+	}
+}
+
+func TestQuoteOrderSpentAtWorstPrice(t *testing.T) {
+	tb := newTestBook(t)
+	tb.place(bob, false, 10_000, 2)
+	capped := tb.place(bob, false, 20_000, 5)
+	tb.pendingAmounts = nil
+
+	order := tb.quoteBuy(alice, 20_000, utils.QuantityToBalance(6))
+	if order.Status != Filled || order.OriginalQuantity != 4 {
+		t.Fatalf("order is %s having bought %d, want filled having bought 4", order.Status, order.OriginalQuantity)
+	}
+	if capped.Quantity != 3 || tb.volumeMap[20_000] != 3 {
+		t.Fatalf("order at the worst price shows %d with level volume %d, want 3", capped.Quantity, tb.volumeMap[20_000])
+	}
+	if got := tb.pending(alice, tb.pair.BaseTokenID); got != utils.QuantityToBalance(4) {
+		t.Fatalf("received %d, want %d", got, utils.QuantityToBalance(4))
+	}
+	if got := tb.pending(alice, tb.pair.QuoteTokenID); got != 0 {
+		t.Fatalf("refunded %d of a spent amount", got)
+	}
+}
+
+func TestLimitBuyRefundsPriceImprovement(t *testing.T) {
+	tb := newTestBook(t)
+	tb.place(bob, false, 10_000, 2)
+	tb.place(bob, false, 20_000, 1)
+	tb.pendingAmounts = nil
+
+	// Locked at 20_000 for 3, filled for 2 at 10_000 and 1 at 20_000
+	order := tb.place(alice, true, 20_000, 3)
+	if order.Status != Filled {
+		t.Fatalf("order is %s, want filled", order.Status)
+	}
+	if got := tb.pending(alice, tb.pair.BaseTokenID); got != utils.QuantityToBalance(3) {
+		t.Fatalf("received %d, want %d", got, utils.QuantityToBalance(3))
+	}
+	improvement := utils.QuantityToBalance(2)
+	want := improvement + tb.RefundMarketOrderFee(alice, true, 0, improvement)
+	if got := tb.pending(alice, tb.pair.QuoteTokenID); got != want {
+		t.Fatalf("refunded %d, want %d", got, want)
+	}
+}
+
+func TestRestingBuyRefundsImprovementOnFilledPart(t *testing.T) {
+	tb := newTestBook(t)
+	tb.place(bob, false, 10_000, 2)
+	tb.pendingAmounts = nil
+
+	order := tb.place(alice, true, 20_000, 5)
+	if tb.Get(order.ID) != order || order.Quantity != 3 {
+		t.Fatalf("order rests with %d, want 3", order.Quantity)
+	}
+	improvement := utils.QuantityToBalance(2)
+	want := improvement + tb.RefundMarketOrderFee(alice, true, 0, improvement)
+	if got := tb.pending(alice, tb.pair.QuoteTokenID); got != want {
+		t.Fatalf("refunded %d, want %d", got, want)
+	}
+}
+
+func TestLimitSellReceivesFilledPrice(t *testing.T) {
+	tb := newTestBook(t)
+	tb.place(bob, true, 20_000, 2)
+	tb.pendingAmounts = nil
+
+	tb.place(alice, false, 10_000, 2)
+	if got := tb.pending(alice, tb.pair.BaseTokenID); got != 0 {
+		t.Fatalf("refunded %d of the base token", got)
+	}
+	if got := tb.pending(alice, tb.pair.QuoteTokenID); got != utils.QuantityToBalance(4) {
+		t.Fatalf("received %d, want the filled price's %d", got, utils.QuantityToBalance(4))
+	}
+}
@@ -0,0 +1,69 @@
+package orderbook
+
+import (
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/jaimi-io/clobvm/metrics"
+)
+
+// Replace amends the resting order [orderID] to the price and quantity of
+// [replacement], which was collateralised in full when submitted.
+//
+// Reducing the quantity at the same price keeps the order's place in its
+// price level: the reduction is refunded and so is the replacement's own
+// collateral. Any other change refunds the old order and enters the
+// replacement under the same ID, matching like a new limit order and resting
+// at the back of its price level. The replacement keeps the order's expiry,
+// display quantity, time in force and self-trade prevention. If the order is
+// no longer resting the replacement's collateral is refunded.
+//
+// A replacement without a price only reduces the order at its own price and
+// was not collateralised, so nothing is refunded for it if it cannot apply.
+func (ob *Orderbook) Replace(orderID ids.ID, replacement *Order, blockHeight uint64, blockTs int64, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
+	order := ob.Get(orderID)
+	if replacement.Price == 0 {
+		if order != nil && order.User == replacement.User && order.Side == replacement.Side && replacement.Quantity <= order.Quantity+order.Hidden {
+			ob.reduce(order, replacement.Quantity, pendingAmounts, metrics)
+		}
+		return
+	}
+	if order == nil || order.User != replacement.User || order.Side != replacement.Side || replacement.Quantity == 0 {
+		ob.refundUnfilled(replacement, blockTs, replacement.Quantity, pendingAmounts)
+		return
+	}
+
+	if replacement.Price == order.Price && replacement.Quantity <= order.Quantity+order.Hidden {
+		ob.reduce(order, replacement.Quantity, pendingAmounts, metrics)
+		ob.refundUnfilled(replacement, blockTs, replacement.Quantity, pendingAmounts)
+		return
+	}
+
+	ob.removeResting(order, metrics)
+	ob.refundAmount(order, order.Quantity+order.Hidden, pendingAmounts)
+	replacement.ID = order.ID
+	replacement.BlockExpiry = order.BlockExpiry
+	replacement.TimeExpiry = order.TimeExpiry
+	replacement.DisplayQuantity = order.DisplayQuantity
+	replacement.TimeInForce = order.TimeInForce
+	replacement.SelfTradePrevention = order.SelfTradePrevention
+	ob.Add(replacement, blockHeight, blockTs, pendingAmounts, metrics)
+}
+
+// reduce lowers the total quantity of [order] to [quantity] in place, taking
+// from the hidden reserve of an iceberg order first.
+func (ob *Orderbook) reduce(order *Order, quantity uint64, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
+	reduction := order.Quantity + order.Hidden - quantity
+	if reduction == 0 {
+		return
+	}
+	ob.refundAmount(order, reduction, pendingAmounts)
+	if order.Hidden >= reduction {
+		order.Hidden -= reduction
+		ob.changed()
+		return
+	}
+	visible := reduction - order.Hidden
+	order.Hidden = 0
+	order.Quantity -= visible
+	ob.subVolume(order.Price, order.Side, visible)
+	metrics.OrderAmountSub(visible)
+}
@@ -0,0 +1,190 @@
+package orderbook
+
+import (
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/jaimi-io/clobvm/utils"
+)
+
+// lockedRefund is what refunding [quantity] of [order] while it rests adds to
+// its user's pending funds.
+func (tb *testBook) lockedRefund(order *Order, quantity uint64) uint64 {
+	if tb.fees.upfront(order.Side) {
+		quantity += applyRate(quantity, order.Fee)
+	}
+	amount, _ := GetAmountFn(order.Side, false, tb.pair)(quantity, order.Price)
+	return amount * utils.MinQuantity()
+}
+
+// unfilledRefund is what refunding the collateral of [quantity] of an order
+// that never rests, at [price], adds to its user's pending funds.
+func (tb *testBook) unfilledRefund(order *Order, price uint64, quantity uint64) uint64 {
+	quantity += tb.RefundMarketOrderFee(order.User, order.Side, 0, quantity)
+	amount, _ := GetAmountFn(order.Side, false, tb.pair)(quantity, price)
+	return amount * utils.MinQuantity()
+}
+
+func TestReplaceSizeDownRefundsReduction(t *testing.T) {
+	for _, side := range []bool{true, false} {
+		tb := newTestBook(t)
+		order := tb.place(alice, side, 20_000, 50_000)
+		tb.pendingAmounts = nil
+
+		tb.Replace(order.ID, tb.order(alice, side, 20_000, 30_000), tb.height, 0, &tb.pendingAmounts, tb.m)
+		tokenID := tb.pair.BaseTokenID
+		if side {
+			tokenID = tb.pair.QuoteTokenID
+		}
+		want := tb.lockedRefund(order, 20_000) + tb.unfilledRefund(order, 20_000, 30_000)
+		if got := tb.pending(alice, tokenID); got != want {
+			t.Fatalf("side %t: refunded %d, want %d", side, got, want)
+		}
+		if len(tb.pendingAmounts) != 0 {
+			t.Fatalf("side %t: refunded other tokens %v", side, tb.pendingAmounts)
+		}
+		if order.Quantity != 30_000 || tb.volumeMap[20_000] != 30_000 {
+			t.Fatalf("side %t: order shows %d with level volume %d, want 30000", side, order.Quantity, tb.volumeMap[20_000])
+		}
+	}
+}
+
+func TestReplaceSizeDownKeepsPriority(t *testing.T) {
+	tb := newTestBook(t)
+	order := tb.place(alice, false, 10_000, 50_000)
+	behind := tb.place(bob, false, 10_000, 50_000)
+	tb.Replace(order.ID, tb.order(alice, false, 10_000, 30_000), tb.height, 0, &tb.pendingAmounts, tb.m)
+
+	tb.place(carol, true, 10_000, 30_000)
+	if order.Status != Filled {
+		t.Fatalf("reduced order is %s, want filled ahead of the order behind it", order.Status)
+	}
+	if behind.Quantity != 50_000 {
+		t.Fatalf("order behind filled %d before the reduced order", 50_000-behind.Quantity)
+	}
+}
+
+func TestReplaceSizeDownIcebergTakesHiddenFirst(t *testing.T) {
+	tb := newTestBook(t)
+	order := tb.iceberg(alice, false, 10_000, 50_000, 10_000)
+	tb.pendingAmounts = nil
+
+	tb.Replace(order.ID, tb.order(alice, false, 10_000, 30_000), tb.height, 0, &tb.pendingAmounts, tb.m)
+	if order.Quantity != 10_000 || order.Hidden != 20_000 {
+		t.Fatalf("showing %d with %d hidden, want 10000 with 20000 hidden", order.Quantity, order.Hidden)
+	}
+	want := tb.lockedRefund(order, 20_000) + tb.unfilledRefund(order, 10_000, 30_000)
+	if got := tb.pending(alice, tb.pair.BaseTokenID); got != want {
+		t.Fatalf("refunded %d, want %d", got, want)
+	}
+}
+
+func TestReplaceSizeUpLosesPriority(t *testing.T) {
+	tb := newTestBook(t)
+	order := tb.place(alice, false, 10_000, 50_000)
+	behind := tb.place(bob, false, 10_000, 50_000)
+	tb.pendingAmounts = nil
+
+	replacement := tb.order(alice, false, 10_000, 60_000)
+	tb.Replace(order.ID, replacement, tb.height, 0, &tb.pendingAmounts, tb.m)
+	if replacement.ID != order.ID || tb.Get(order.ID) != replacement {
+		t.Fatal("replacement does not rest under the replaced order's ID")
+	}
+	if got, want := tb.pending(alice, tb.pair.BaseTokenID), tb.lockedRefund(order, 50_000); got < want {
+		t.Fatalf("refunded %d, want at least the replaced order's %d", got, want)
+	}
+
+	tb.place(carol, true, 10_000, 50_000)
+	if behind.Status != Filled {
+		t.Fatalf("order behind is %s, want filled ahead of the replacement", behind.Status)
+	}
+	if replacement.Quantity != 60_000 {
+		t.Fatalf("replacement filled %d ahead of the order behind it", 60_000-replacement.Quantity)
+	}
+}
+
+func TestReplaceMissingOrderRefundsReplacement(t *testing.T) {
+	tb := newTestBook(t)
+	replacement := tb.order(alice, true, 10_000, 30_000)
+	tb.Replace(ids.GenerateTestID(), replacement, tb.height, 0, &tb.pendingAmounts, tb.m)
+	if got, want := tb.pending(alice, tb.pair.QuoteTokenID), tb.unfilledRefund(replacement, 10_000, 30_000); got != want {
+		t.Fatalf("refunded %d, want %d", got, want)
+	}
+	if tb.Len() != 0 {
+		t.Fatal("replacement rests on the book")
+	}
+}
+
+func TestReplacePostOnlyToCrossingPriceIsRejected(t *testing.T) {
+	tb := newTestBook(t)
+	tb.place(bob, false, 10_000, 5)
+	order := tb.order(alice, true, 9_000, 2)
+	order.TimeInForce = PostOnly
+	tb.add(order)
+	tb.pendingAmounts = nil
+
+	replacement := tb.order(alice, true, 10_000, 2)
+	tb.Replace(order.ID, replacement, tb.height, 0, &tb.pendingAmounts, tb.m)
+	if replacement.Status != Cancelled || tb.Get(order.ID) != nil {
+		t.Fatalf("replacement is %s, want rejected as post only", replacement.Status)
+	}
+	if len(tb.trades) != 0 {
+		t.Fatal("post only replacement took liquidity")
+	}
+	want := tb.lockedRefund(order, 2) + tb.unfilledRefund(replacement, 10_000, 2)
+	if got := tb.pending(alice, tb.pair.QuoteTokenID); got != want {
+		t.Fatalf("refunded %d, want %d", got, want)
+	}
+}
+
+func TestReplaceKeepsSelfTradePrevention(t *testing.T) {
+	tb := newTestBook(t)
+	self := tb.place(alice, false, 10_000, 5)
+	order := tb.order(alice, true, 9_000, 2)
+	order.SelfTradePrevention = CancelNewest
+	tb.add(order)
+
+	replacement := tb.order(alice, true, 10_000, 2)
+	tb.Replace(order.ID, replacement, tb.height, 0, &tb.pendingAmounts, tb.m)
+	if replacement.Status != Cancelled {
+		t.Fatalf("replacement is %s, want cancelled at its own order", replacement.Status)
+	}
+	if self.Quantity != 5 || len(tb.trades) != 0 {
+		t.Fatal("replacement traded with its own order")
+	}
+}
+
+func TestReplaceWithoutPriceRefundsOnlyReduction(t *testing.T) {
+	tb := newTestBook(t)
+	order := tb.place(alice, true, 10_000, 5)
+	behind := tb.place(bob, true, 10_000, 5)
+	tb.pendingAmounts = nil
+
+	tb.Replace(order.ID, tb.order(alice, true, 0, 3), tb.height, 0, &tb.pendingAmounts, tb.m)
+	if got, want := tb.pending(alice, tb.pair.QuoteTokenID), tb.lockedRefund(order, 2); got != want {
+		t.Fatalf("refunded %d, want the reduction's %d", got, want)
+	}
+	if len(tb.pendingAmounts) != 0 {
+		t.Fatalf("refunded other amounts %v", tb.pendingAmounts)
+	}
+
+	tb.place(carol, false, 10_000, 3)
+	if order.Status != Filled || behind.Quantity != 5 {
+		t.Fatalf("reduced order is %s with the order behind showing %d, want filled first", order.Status, behind.Quantity)
+	}
+}
+
+func TestReplaceWithoutPriceBelowQuantityDoesNothing(t *testing.T) {
+	tb := newTestBook(t)
+	order := tb.place(alice, false, 10_000, 2)
+	tb.pendingAmounts = nil
+
+	tb.Replace(order.ID, tb.order(alice, false, 0, 3), tb.height, 0, &tb.pendingAmounts, tb.m)
+	tb.Replace(ids.GenerateTestID(), tb.order(alice, false, 0, 1), tb.height, 0, &tb.pendingAmounts, tb.m)
+	if len(tb.pendingAmounts) != 0 {
+		t.Fatalf("refunded %v for replacements that locked nothing", tb.pendingAmounts)
+	}
+	if order.Quantity != 2 || tb.Get(order.ID) != order {
+		t.Fatalf("order shows %d, want 2", order.Quantity)
+	}
+}
@@ -0,0 +1,93 @@
+package orderbook
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/jaimi-io/hypersdk/crypto"
+)
+
+// OrderStatus is where an order is in its lifecycle. Orders are Open while
+// resting or waiting for their trigger price and take one of the other
+// statuses when they leave the book.
+type OrderStatus byte
+
+const (
+	Open OrderStatus = iota
+	Filled
+	Cancelled
+	Evicted
+)
+
+func (s OrderStatus) String() string {
+	switch s {
+	case Open:
+		return "open"
+	case Filled:
+		return "filled"
+	case Cancelled:
+		return "cancelled"
+	case Evicted:
+		return "evicted"
+	default:
+		return "unknown"
+	}
+}
+
+// close records that [order] has left the book with [status]. Closed orders
+// are collected until the end of the block by TakeClosedOrders.
+func (ob *Orderbook) close(order *Order, status OrderStatus) {
+	order.Status = status
+	ob.closed = append(ob.closed, order)
+}
+
+// ClosedOrder is an order that left the book of [Pair].
+type ClosedOrder struct {
+	Pair Pair
+	*Order
+}
+
+// TakeClosedOrders returns the orders closed since it was last called, pair
+// by pair in sorted order.
+func (obm *OrderbookManager) TakeClosedOrders() []*ClosedOrder {
+	var closed []*ClosedOrder
+	for _, pair := range obm.sortedPairs() {
+		ob := obm.orderbooks[pair]
+		for _, order := range ob.closed {
+			closed = append(closed, &ClosedOrder{pair, order})
+		}
+		ob.closed = nil
+	}
+	return closed
+}
+
+// GetOrder returns a copy of the open order [id] from any pair, or nil if it
+// is not resting or waiting for its trigger price.
+func (obm *OrderbookManager) GetOrder(id ids.ID) *Order {
+	for _, pair := range obm.sortedPairs() {
+		ob := obm.orderbooks[pair]
+		if order := ob.Get(id); order != nil {
+			return order.Copy()
+		}
+		if order := ob.GetStop(id); order != nil {
+			return order.Copy()
+		}
+	}
+	return nil
+}
+
+// GetOpenOrders returns copies of the resting and stop orders of [user]
+// sorted by ID.
+func (ob *Orderbook) GetOpenOrders(user crypto.PublicKey) []*Order {
+	orders := make([]*Order, 0, len(ob.openOrders[user]))
+	for id := range ob.openOrders[user] {
+		if order := ob.Get(id); order != nil {
+			orders = append(orders, order.Copy())
+		} else if order := ob.GetStop(id); order != nil {
+			orders = append(orders, order.Copy())
+		}
+	}
+	sort.Slice(orders, func(i, j int) bool { return bytes.Compare(orders[i].ID[:], orders[j].ID[:]) < 0 })
+	return orders
+}
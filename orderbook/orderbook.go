@@ -1,15 +1,11 @@
 package orderbook
 
 import (
-	"fmt"
 	"sort"
 
 	"github.com/ava-labs/avalanchego/ids"
-	"github.com/ava-labs/avalanchego/utils/math"
-	"github.com/jaimi-io/clobvm/consts"
 	"github.com/jaimi-io/clobvm/heap"
 	"github.com/jaimi-io/clobvm/metrics"
-	"github.com/jaimi-io/clobvm/utils"
 	"github.com/jaimi-io/hypersdk/crypto"
 )
 
@@ -23,12 +19,20 @@ type Orderbook struct {
 	sellSideVolume uint64
 	volumeMap map[uint64]uint64
 	evictionMap map[uint64]map[ids.ID]struct{}
+	timeEvictions *heap.PriorityQueueHeap[*Order, int64]
 	openOrders map[crypto.PublicKey]map[ids.ID]struct{}
 	executionHistory map[crypto.PublicKey]*MonthlyExecuted
 	midPrice *VersionedBalance
+	stops *TriggerBook
+	closed []*Order
+	trades []*Trade
+	fees *FeeSchedule
+	pendingWindow uint64 // blocks the mid price market orders reference lags by
+	bookHash *ids.ID // cached hash of the book without its mid price, nil once it changes
+	changedLevels map[LevelKey]struct{} // levels touched since TakeLevelChanges
 }
 
-func NewOrderbook(pair Pair) *Orderbook {
+func NewOrderbook(pair Pair, fees *FeeSchedule, pendingWindow uint64) *Orderbook {
 	return &Orderbook{
 		pair: pair,
 		minHeap: heap.NewPriorityQueueHeap[*Order, uint64](1024, true),
@@ -36,9 +40,14 @@ func NewOrderbook(pair Pair) *Orderbook {
 		orderMap: make(map[ids.ID]*Order),
 		volumeMap: make(map[uint64]uint64),
 		evictionMap: make(map[uint64]map[ids.ID]struct{}),
+		timeEvictions: heap.NewPriorityQueueHeap[*Order, int64](64, true),
 		executionHistory: make(map[crypto.PublicKey]*MonthlyExecuted),
 		openOrders: make(map[crypto.PublicKey]map[ids.ID]struct{}),
-		midPrice: NewVersionedBalance(0, 0),
+		midPrice: NewVersionedBalance(0, 0, pendingWindow),
+		stops: NewTriggerBook(),
+		changedLevels: make(map[LevelKey]struct{}),
+		fees: fees,
+		pendingWindow: pendingWindow,
 	}
 }
 
@@ -50,86 +59,177 @@ func (ob *Orderbook) Add(order *Order, blockHeight uint64, blockTs int64, pendin
 	}
 }
 
+// AddMarketOrder fills [order] against the opposite side up to its worst
+// price and refunds whatever is left unfilled.
 func (ob *Orderbook) AddMarketOrder(order *Order, blockHeight uint64, blockTs int64, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
-	order.Price = ob.GetMidPrice()
-	if ((order.Side && ob.sellSideVolume < order.Quantity) || (!order.Side && ob.buySideVolume < order.Quantity)) {
-		feeToReturn := ob.RefundMarketOrderFee(order.User, blockTs, order.Quantity)
-		if feeToReturn > 0 {
-			ob.refundAmount(order, feeToReturn, pendingAmounts)
+	order.Price = order.WorstPrice
+	if order.QuoteAmount > 0 {
+		if ob.matchQuoteOrder(order, blockTs, pendingAmounts, metrics) {
+			ob.close(order, Filled)
+		} else {
+			ob.close(order, Cancelled)
 		}
+		metrics.MarketOrder()
 		return
 	}
-	ob.matchMarketOrder(order, blockTs, pendingAmounts, metrics)
+	ob.matchLimitOrder(order, blockTs, pendingAmounts, metrics)
+
+	unfilled := order.takeSelfTraded()
+	if !order.selfTradeCancelled {
+		unfilled += order.Quantity
+	}
+	ob.refundUnfilled(order, blockTs, unfilled, pendingAmounts)
+
+	if order.Quantity == 0 && !order.selfTradeCancelled {
+		ob.close(order, Filled)
+	} else {
+		ob.close(order, Cancelled)
+	}
 	metrics.MarketOrder()
 }
 
 func (ob *Orderbook) AddLimitOrder(order *Order, blockHeight uint64, blockTs int64, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
-	ob.matchLimitOrder(order, blockTs, pendingAmounts, metrics)
+	switch {
+	case order.TimeInForce == PostOnly && ob.crosses(order),
+		order.TimeInForce == FillOrKill && ob.crossingVolume(order) < order.Quantity:
+		ob.refundUnfilled(order, blockTs, order.Quantity, pendingAmounts)
+		ob.close(order, Cancelled)
+		metrics.LimitOrder()
+		return
+	}
 
-	if order.Quantity > 0 {
-		feeToReturn := ob.RefundFee(order.User, blockTs, order.Quantity)
-		if feeToReturn > 0 {
-			ob.refundAmount(order, feeToReturn, pendingAmounts)
-		}
+	ob.matchLimitOrder(order, blockTs, pendingAmounts, metrics)
+	ob.refundUnfilled(order, blockTs, order.takeSelfTraded(), pendingAmounts)
 
-		ob.volumeMap[order.Price] += order.Quantity
-		ob.orderMap[order.ID] = order
-		ob.AddToEviction(order.ID, blockHeight)
-		order.Fee = ob.GetFeeRate(order.User, blockTs)
+	if order.selfTradeCancelled {
+		ob.close(order, Cancelled)
+		metrics.LimitOrder()
+		return
+	}
 
-		if _, ok := ob.openOrders[order.User]; !ok {
-			ob.openOrders[order.User] = make(map[ids.ID]struct{})
-		}
-		ob.openOrders[order.User][order.ID] = struct{}{}
+	if order.Quantity == 0 {
+		ob.close(order, Filled)
+		metrics.LimitOrder()
+		return
+	}
 
-		if order.Side {
-			ob.maxHeap.Add(order, order.ID, order.Price)
-			ob.buySideVolume += order.Quantity
-		} else {
-			ob.minHeap.Add(order, order.ID, order.Price)
-			ob.sellSideVolume += order.Quantity
-		}
+	if order.TimeInForce == ImmediateOrCancel {
+		ob.refundUnfilled(order, blockTs, order.Quantity, pendingAmounts)
+		ob.close(order, Cancelled)
+		metrics.LimitOrder()
+		return
+	}
 
-		metrics.OrderNumInc()
-		metrics.OrderAmountAdd(order.Quantity)
+	feeToReturn := ob.RefundFee(order.User, order.Side, blockTs, order.Quantity)
+	if feeToReturn > 0 {
+		ob.refundAmount(order, feeToReturn, pendingAmounts)
 	}
+
+	ob.AddToEviction(order)
+	order.Fee = ob.GetFeeRate(order.User, blockTs)
+	order.hide()
+	ob.insert(order)
+
+	metrics.OrderNumInc()
+	metrics.OrderAmountAdd(order.Quantity)
 	metrics.LimitOrder()
 }
 
+func (ob *Orderbook) insert(order *Order) {
+	ob.addVolume(order.Price, order.Side, order.Quantity)
+	ob.orderMap[order.ID] = order
+	ob.addOpenOrder(order)
+
+	if order.Side {
+		ob.maxHeap.Add(order, order.ID, order.Price)
+	} else {
+		ob.minHeap.Add(order, order.ID, order.Price)
+	}
+}
+
+// addVolume and subVolume keep the volume of the level at [price] and of
+// [side] in step with the visible quantity resting there, and record the
+// level as touched for TakeLevelChanges.
+func (ob *Orderbook) addVolume(price uint64, side bool, quantity uint64) {
+	ob.volumeMap[price] += quantity
+	if side {
+		ob.buySideVolume += quantity
+	} else {
+		ob.sellSideVolume += quantity
+	}
+	ob.changedLevels[LevelKey{side, price}] = struct{}{}
+	ob.changed()
+}
+
+func (ob *Orderbook) subVolume(price uint64, side bool, quantity uint64) {
+	ob.volumeMap[price] -= quantity
+	if side {
+		ob.buySideVolume -= quantity
+	} else {
+		ob.sellSideVolume -= quantity
+	}
+	ob.changedLevels[LevelKey{side, price}] = struct{}{}
+	ob.changed()
+}
+
+// changed drops the cached hash of the book. Anything that modifies what
+// hash covers must call it, directly or through addVolume and subVolume.
+func (ob *Orderbook) changed() {
+	ob.bookHash = nil
+}
+
+func (ob *Orderbook) addOpenOrder(order *Order) {
+	if _, ok := ob.openOrders[order.User]; !ok {
+		ob.openOrders[order.User] = make(map[ids.ID]struct{})
+	}
+	ob.openOrders[order.User][order.ID] = struct{}{}
+}
+
 func (ob *Orderbook) Get(id ids.ID) *Order {
 	return ob.orderMap[id]
 }
 
+func (ob *Orderbook) Len() int {
+	return len(ob.orderMap)
+}
+
 func (ob *Orderbook) CancelAll(user crypto.PublicKey, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
 	if _, ok := ob.openOrders[user]; !ok {
 		return
 	}
 	for id := range ob.openOrders[user] {
-		order := ob.orderMap[id]
-		ob.Cancel(order, pendingAmounts, metrics)
+		if order := ob.orderMap[id]; order != nil {
+			ob.Cancel(order, pendingAmounts, metrics)
+		} else if stop := ob.stops.get(id); stop != nil {
+			ob.CancelStop(stop, pendingAmounts, metrics)
+		}
 	}
 }
 
 func (ob *Orderbook) Cancel(order *Order, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
+	ob.cancel(order, Cancelled, pendingAmounts, metrics)
+}
+
+func (ob *Orderbook) cancel(order *Order, status OrderStatus, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
+	ob.removeResting(order, metrics)
+	ob.refundAmount(order, order.Quantity+order.Hidden, pendingAmounts)
+	ob.close(order, status)
+	metrics.OrderCancelNum()
+}
+
+func (ob *Orderbook) removeResting(order *Order, metrics *metrics.Metrics) {
 	if order.Side {
 		ob.maxHeap.Remove(order.ID, order.Price)
 	} else {
 		ob.minHeap.Remove(order.ID, order.Price)
 	}
 	ob.Remove(order, metrics)
-	ob.refundAmount(order, order.Quantity, pendingAmounts)
-	metrics.OrderCancelNum()
 }
 
 func (ob *Orderbook) Remove(order *Order, metrics *metrics.Metrics) {
-	ob.volumeMap[order.Price] -= order.Quantity
-	if order.Side {
-		ob.buySideVolume -= order.Quantity
-	} else {
-		ob.sellSideVolume -= order.Quantity
-	}
+	ob.subVolume(order.Price, order.Side, order.Quantity)
 	delete(ob.orderMap, order.ID)
-	delete(ob.evictionMap[order.BlockExpiry], order.ID)
+	ob.removeFromEviction(order)
 	delete(ob.openOrders[order.User], order.ID)
 	metrics.OrderNumDec()
 	metrics.OrderAmountSub(order.Quantity)
@@ -157,16 +257,19 @@ func (obm *OrderbookManager) UpdateAllMidPrices(blockNumber uint64) {
 	}
 }
 
+// GetMidPriceBlk returns the mid price as of the pending window before
+// [blockHeight], which every node has accepted by the time it executes a
+// block at [blockHeight].
 func (ob *Orderbook) GetMidPriceBlk(blockHeight uint64) uint64 {
-	if blockHeight < consts.PendingBlockWindow {
+	if blockHeight < ob.pendingWindow {
 		return 0
 	}
-	mid, _ := ob.midPrice.Get(blockHeight - consts.PendingBlockWindow)
+	mid, _ := ob.midPrice.Get(blockHeight - ob.pendingWindow)
 	return mid
 }
 
 func (ob *Orderbook) AddMidPriceBlk(blockHeight uint64) {
-	ob.midPrice.Put(ob.GetMidPrice(), blockHeight)
+	ob.midPrice.Set(ob.GetMidPrice(), blockHeight)
 }
 
 func (ob *Orderbook) getPrices() []int {
@@ -180,71 +283,3 @@ func (ob *Orderbook) getPrices() []int {
 	sort.Ints(prices)
 	return prices
 }
-
-func (ob *Orderbook) GetBuySide(numPriceLevels int) [][]*Order {
-	var res [][]*Order
-	prices := ob.getPrices()
-	if ob.maxHeap.Len() == 0 {
-		return res
-	}
-	i := sort.SearchInts(prices, int(ob.maxHeap.Peek().Priority()))
-	if i == len(prices) {
-		return res
-	}
-	for ; i >= 0 && numPriceLevels > 0; i-- {
-		q, _ := ob.maxHeap.Get(uint64(prices[i]))
-		res = append(res, q.Values())
-		numPriceLevels -= 1
-	}
-	return res
-}
-
-func (ob *Orderbook) GetSellSide(numPriceLevels int) [][]*Order {
-	var res [][]*Order
-	prices := ob.getPrices()
-	if ob.minHeap.Len() == 0 {
-		return res
-	}
-	i := sort.SearchInts(prices, int(ob.minHeap.Peek().Priority()))
-	if i == len(prices) {
-		return res
-	}
-	for ; i < len(prices) && numPriceLevels > 0; i++ {
-		q, _ := ob.minHeap.Get(uint64(prices[i]))
-		res = append(res, q.Values())
-		numPriceLevels -= 1
-	}
-	return res
-}
-
-func (ob *Orderbook) GetVolumes(numPriceLevels int) string {
-	prices := ob.getPrices()
-	priceLevels := len(prices)
-	var outputStr string
-
-	if ob.minHeap.Len() == 0 {
-		return outputStr
-	}
-	i := sort.SearchInts(prices, int(ob.minHeap.Peek().Priority()))
-	sellFormat := "{{red}}%." + fmt.Sprint(consts.PriceDecimals) + "f : %." + fmt.Sprint(consts.QuantityDecimals) + "f{{/}}\n"
-	for k := i; k < priceLevels && k - i < numPriceLevels; k++ {
-		outputStr += fmt.Sprintf(sellFormat, utils.DisplayPrice(uint64(prices[k])), utils.DisplayQuantity(ob.volumeMap[uint64(prices[k])]))
-	}
-
-	if ob.maxHeap.Len() == 0 {
-		return outputStr
-	}
-
-	j := sort.SearchInts(prices, int(ob.maxHeap.Peek().Priority()))
-	if j == len(prices) {
-		return outputStr
-	}
-
-	buyFormat := "{{green}}%." + fmt.Sprint(consts.PriceDecimals) + "f : %." + fmt.Sprint(consts.QuantityDecimals) + "f{{/}}\n"
-	for m := math.Max(i, j - numPriceLevels); m >= 0 && j - m < numPriceLevels; m-- {
-		outputStr += fmt.Sprintf(buyFormat, utils.DisplayPrice(uint64(prices[m])), utils.DisplayQuantity(ob.volumeMap[uint64(prices[m])]))
-	}
-
-	return outputStr
-}
-
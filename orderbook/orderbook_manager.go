@@ -2,73 +2,193 @@ package orderbook
 
 import (
 	"fmt"
+	"math"
+	"sync"
 
 	"github.com/ava-labs/avalanchego/ids"
-	"github.com/jaimi-io/clobvm/consts"
 	"github.com/jaimi-io/hypersdk/crypto"
 )
 
+// OrderbookManager holds the books of every pair and everyone's pending funds.
+// Accepted blocks are applied under its write lock, so reads made outside of
+// block processing, such as by the RPC server, must hold its read lock.
 type OrderbookManager struct{
+	sync.RWMutex
 	orderbooks map[Pair]*Orderbook
 	pendingFunds map[crypto.PublicKey]map[ids.ID]*VersionedBalance
+	pendingFundsHashes map[crypto.PublicKey]ids.ID // cached by userFundsHash until the user's funds change
 	lastBlockHeight uint64
+	fees *FeeSchedule
+	pendingWindow uint64 // blocks before pending funds can be pulled
 }
 
-func NewOrderbookManager() *OrderbookManager {
+func NewOrderbookManager(fees *FeeSchedule, pendingWindow uint64) *OrderbookManager {
 	return &OrderbookManager{
 		orderbooks: make(map[Pair]*Orderbook),
 		pendingFunds: make(map[crypto.PublicKey]map[ids.ID]*VersionedBalance),
+		pendingFundsHashes: make(map[crypto.PublicKey]ids.ID),
+		fees: fees,
+		pendingWindow: pendingWindow,
 	}
 }
 
+func (obm *OrderbookManager) FeeSchedule() *FeeSchedule {
+	return obm.fees
+}
+
+func (obm *OrderbookManager) PendingWindow() uint64 {
+	return obm.pendingWindow
+}
+
 func (obm *OrderbookManager) GetOrderbook(pair Pair) *Orderbook {
 	if ob, ok := obm.orderbooks[pair]; ok {
 		return ob
 	}
-	ob := NewOrderbook(pair)
+	ob := NewOrderbook(pair, obm.fees, obm.pendingWindow)
 	obm.orderbooks[pair] = ob
 	return ob
 }
 
+// ViewOrderbook returns the book of [pair], or an empty book that is not added
+// to the manager if [pair] has never had an order. Reads go through it so they
+// never create books for pairs that are not listed.
+func (obm *OrderbookManager) ViewOrderbook(pair Pair) *Orderbook {
+	if ob, ok := obm.orderbooks[pair]; ok {
+		return ob
+	}
+	return NewOrderbook(pair, obm.fees, obm.pendingWindow)
+}
+
 func(obm *OrderbookManager) AddPendingFunds(user crypto.PublicKey, tokenID ids.ID, balance uint64, blockHeight uint64) {
+	delete(obm.pendingFundsHashes, user)
 	if _, ok := obm.pendingFunds[user]; !ok {
 		obm.pendingFunds[user] = make(map[ids.ID]*VersionedBalance)
 	}
 	if _, ok := obm.pendingFunds[user][tokenID]; !ok {
-		obm.pendingFunds[user][tokenID] = NewVersionedBalance(balance, blockHeight)
+		obm.pendingFunds[user][tokenID] = NewVersionedBalance(balance, blockHeight, obm.pendingWindow)
 		return
 	}
 	obm.pendingFunds[user][tokenID].Put(balance, blockHeight)
 }
 
-func (obm *OrderbookManager) PullPendingFunds(user crypto.PublicKey, tokenID ids.ID, blockHeight uint64) uint64 {
-	if blockHeight <= consts.PendingBlockWindow {
+// MaturedFunds returns the running total of [user]'s pending [tokenID] that
+// can be pulled at [blockHeight]. Pending funds are never taken out of the
+// manager; how much of the total a user has pulled is kept in state, so a
+// pull is rolled back with the tx that made it.
+func (obm *OrderbookManager) MaturedFunds(user crypto.PublicKey, tokenID ids.ID, blockHeight uint64) uint64 {
+	if blockHeight <= obm.pendingWindow {
 		return 0
 	}
-	if _, ok := obm.pendingFunds[user]; !ok {
+	vb, ok := obm.pendingFunds[user][tokenID]
+	if !ok {
 		return 0
 	}
-	if _, ok := obm.pendingFunds[user][tokenID]; !ok {
-		return 0
-	}
-	blockHeight -= consts.PendingBlockWindow
+	blockHeight -= obm.pendingWindow
 	if blockHeight > obm.lastBlockHeight {
 		s := fmt.Sprintf("blockHeight %d is greater than lastBlockHeight %d", blockHeight, obm.lastBlockHeight)
 		panic(s)
 	}
-	return obm.pendingFunds[user][tokenID].Pull(blockHeight)
+	total, _ := vb.Get(blockHeight)
+	return total
 }
 
+// GetPendingFunds returns the running total of [user]'s pending [tokenID] as
+// of [blockHeight].
 func (obm *OrderbookManager) GetPendingFunds(user crypto.PublicKey, tokenID ids.ID, blockHeight uint64) (uint64, uint64) {
-	if _, ok := obm.pendingFunds[user]; !ok {
+	vb, ok := obm.pendingFunds[user][tokenID]
+	if !ok {
 		return 0, blockHeight
 	}
-	if _, ok := obm.pendingFunds[user][tokenID]; !ok {
-		return 0, blockHeight
+	return vb.Get(blockHeight)
+}
+
+// Unsettled is how much of a running [total] of pending funds is left after
+// [settled] of it has been pulled. Totals wrap around, so only the difference
+// is meaningful.
+func Unsettled(total uint64, settled uint64) uint64 {
+	if diff := total - settled; int64(diff) > 0 {
+		return diff
+	}
+	return 0
+}
+
+// GetUnsettledFunds returns how much of [user]'s pending [tokenID] is left
+// after [settled] has been pulled, and how much of it can be pulled at
+// [blockHeight].
+func (obm *OrderbookManager) GetUnsettledFunds(user crypto.PublicKey, tokenID ids.ID, settled uint64, blockHeight uint64) (uint64, uint64) {
+	vb, ok := obm.pendingFunds[user][tokenID]
+	if !ok {
+		return 0, 0
+	}
+	total, _ := vb.Get(math.MaxUint64)
+	if blockHeight <= obm.pendingWindow {
+		return Unsettled(total, settled), 0
+	}
+	matured, _ := vb.Get(blockHeight - obm.pendingWindow)
+	return Unsettled(total, settled), Unsettled(matured, settled)
+}
+
+// PendingTokens returns the tokens [user] has ever had pending, in sorted order.
+func (obm *OrderbookManager) PendingTokens(user crypto.PublicKey) []ids.ID {
+	return sortedIDs(obm.pendingFunds[user])
+}
+
+// PendingFunds is an amount of a token added to a user's pending funds in one
+// block, which can be pulled from ClaimableHeight.
+type PendingFunds struct {
+	TokenID         ids.ID
+	Amount          uint64
+	ClaimableHeight uint64
+}
+
+// ListPendingFunds returns what [user] has not pulled of their pending funds,
+// token by token in sorted order and oldest first. [settled] is how much of
+// each token they have pulled. Funds put before the oldest version held are
+// folded into it, as they can be pulled by then anyway.
+func (obm *OrderbookManager) ListPendingFunds(user crypto.PublicKey, settled func(ids.ID) uint64) []*PendingFunds {
+	var funds []*PendingFunds
+	for _, tokenID := range obm.PendingTokens(user) {
+		prev := settled(tokenID)
+		for _, version := range obm.pendingFunds[user][tokenID].versions() {
+			if amount := Unsettled(version.bal, prev); amount > 0 {
+				funds = append(funds, &PendingFunds{tokenID, amount, version.blkHgt + obm.pendingWindow})
+				prev = version.bal
+			}
+		}
 	}
-	return obm.pendingFunds[user][tokenID].Get(blockHeight)
+	return funds
+}
+
+// MaturedFund is a user's pending funds of a token that can be pulled.
+type MaturedFund struct {
+	User    crypto.PublicKey
+	TokenID ids.ID
+	Amount  uint64
+}
+
+// ListMaturedFunds returns every user's pending funds that can be pulled at
+// [blockHeight], by user and then token in sorted order. [settled] is how much
+// of each token a user has pulled.
+func (obm *OrderbookManager) ListMaturedFunds(blockHeight uint64, settled func(crypto.PublicKey, ids.ID) uint64) []*MaturedFund {
+	var funds []*MaturedFund
+	for _, user := range sortedUsers(obm.pendingFunds) {
+		for _, tokenID := range obm.PendingTokens(user) {
+			if _, matured := obm.GetUnsettledFunds(user, tokenID, settled(user, tokenID), blockHeight); matured > 0 {
+				funds = append(funds, &MaturedFund{user, tokenID, matured})
+			}
+		}
+	}
+	return funds
 }
 
 func (obm *OrderbookManager) UpdateLastBlockHeight(blockHeight uint64) {
 	obm.lastBlockHeight = blockHeight
 }
+
+func (obm *OrderbookManager) GetLastBlockHeight() uint64 {
+	return obm.lastBlockHeight
+}
+
+func (obm *OrderbookManager) Pairs() []Pair {
+	return obm.sortedPairs()
+}
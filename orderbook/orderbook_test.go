@@ -0,0 +1,86 @@
+package orderbook
+
+import "testing"
+
+func TestMidPriceBlkLagsByPendingWindow(t *testing.T) {
+	tb := newTestBook(t)
+	tb.Orderbook = NewOrderbook(tb.pair, DefaultFeeSchedule(), 3)
+	for height := uint64(1); height <= 6; height++ {
+		// Each block's lowest ask, and so its mid price, is 1_000 lower
+		tb.place(bob, false, 20_000-height*1_000, 1)
+		tb.AddMidPriceBlk(height)
+
+		// The next block references the mid price of 3 blocks before it
+		var want uint64
+		if height > 2 {
+			want = 20_000 - (height-2)*1_000
+		}
+		if got := tb.GetMidPriceBlk(height + 1); got != want {
+			t.Fatalf("mid price referenced at %d is %d, want %d", height+1, got, want)
+		}
+	}
+}
+
+func TestReadOrdersAreCopies(t *testing.T) {
+	tb := newTestBook(t)
+	order := tb.place(alice, false, 10_000, 5)
+	open := tb.GetOpenOrders(alice)
+	_, asks := tb.GetDepth(1, true)
+
+	tb.place(bob, true, 10_000, 2)
+	if order.Quantity != 3 {
+		t.Fatalf("order shows %d, want 3", order.Quantity)
+	}
+	if len(open) != 1 || open[0].Quantity != 5 {
+		t.Fatal("open orders changed after they were read")
+	}
+	if len(asks) != 1 || len(asks[0].Orders) != 1 || asks[0].Orders[0].Quantity != 5 {
+		t.Fatal("depth orders changed after they were read")
+	}
+}
+
+func TestLevelChangesTrackLevels(t *testing.T) {
+	tb := newTestBook(t)
+	view := make(map[LevelKey]uint64)
+	check := func(step string) {
+		for _, level := range tb.TakeLevelChanges() {
+			key := LevelKey{level.Side, level.Price}
+			if level.Quantity == 0 {
+				delete(view, key)
+			} else {
+				view[key] = level.Quantity
+			}
+		}
+		levels := tb.Levels()
+		if len(levels) != len(view) {
+			t.Fatalf("%s: %d levels, changes give %d", step, len(levels), len(view))
+		}
+		for key, vol := range levels {
+			if view[key] != vol {
+				t.Fatalf("%s: level %v has %d, changes give %d", step, key, vol, view[key])
+			}
+		}
+	}
+
+	bid := tb.place(alice, true, 9_000, 4)
+	tb.place(alice, false, 11_000, 3)
+	tb.iceberg(bob, false, 12_000, 6, 2)
+	check("place")
+
+	// Take both asks, every peak of the iceberg, and rest 1 at 12_000
+	tb.place(carol, true, 12_000, 10)
+	check("fill")
+	if len(tb.resting(true)) != 2 || len(tb.resting(false)) != 0 {
+		t.Fatal("bid did not rest where the iceberg was")
+	}
+
+	tb.Cancel(bid, &tb.pendingAmounts, tb.m)
+	check("cancel")
+
+	// 12_000 turns from a bid into an ask
+	tb.place(alice, false, 12_000, 8)
+	check("cross")
+	if changes := tb.TakeLevelChanges(); changes != nil {
+		t.Fatalf("changes taken twice: %v", changes)
+	}
+}
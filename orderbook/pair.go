@@ -1,6 +1,10 @@
 package orderbook
 
-import "github.com/ava-labs/avalanchego/ids"
+import (
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/jaimi-io/hypersdk/codec"
+	"github.com/jaimi-io/hypersdk/crypto"
+)
 
 type Pair struct {
 	BaseTokenID ids.ID
@@ -12,4 +16,56 @@ func (p *Pair) TokenID(side bool) ids.ID {
 		return p.QuoteTokenID
 	} 
 	return p.BaseTokenID
-}
\ No newline at end of file
+}
+// PairStatus is whether a listed pair accepts new orders.
+type PairStatus byte
+
+const (
+	PairActive PairStatus = iota
+	PairHalted
+)
+
+func (s PairStatus) Valid() bool {
+	return s <= PairHalted
+}
+
+func (s PairStatus) String() string {
+	switch s {
+	case PairActive:
+		return "active"
+	case PairHalted:
+		return "halted"
+	default:
+		return "unknown"
+	}
+}
+
+// PairInfo is the listing of a pair in the on-chain registry. TickSize is in
+// price units, LotSize in balance units of the base token and MinNotional in
+// balance units of the quote token. Pairs listed at genesis have no Creator
+// and cannot be changed.
+type PairInfo struct {
+	Creator     crypto.PublicKey
+	TickSize    uint64
+	LotSize     uint64
+	MinNotional uint64
+	Status      PairStatus
+}
+
+func (pi *PairInfo) Marshal(p *codec.Packer) {
+	p.PackPublicKey(pi.Creator)
+	p.PackUint64(pi.TickSize)
+	p.PackUint64(pi.LotSize)
+	p.PackUint64(pi.MinNotional)
+	p.PackByte(byte(pi.Status))
+}
+
+func UnmarshalPairInfo(p *codec.Packer) *PairInfo {
+	var pi PairInfo
+	p.UnpackPublicKey(false, &pi.Creator)
+	pi.TickSize = p.UnpackUint64(true)
+	pi.LotSize = p.UnpackUint64(true)
+	pi.MinNotional = p.UnpackUint64(false)
+	pi.Status = PairStatus(p.UnpackByte())
+	return &pi
+}
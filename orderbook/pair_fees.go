@@ -0,0 +1,62 @@
+package orderbook
+
+import (
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/jaimi-io/hypersdk/codec"
+)
+
+// PairFees is what was collected in fees on a pair, in balance units of each
+// of its tokens.
+type PairFees struct {
+	Pair  Pair
+	Base  uint64
+	Quote uint64
+}
+
+func (pf *PairFees) Add(tokenID ids.ID, amount uint64) {
+	if tokenID == pf.Pair.BaseTokenID {
+		pf.Base += amount
+	} else {
+		pf.Quote += amount
+	}
+}
+
+func (pf *PairFees) Marshal(p *codec.Packer) {
+	p.PackUint64(pf.Base)
+	p.PackUint64(pf.Quote)
+}
+
+func UnmarshalPairFees(p *codec.Packer, pair Pair) *PairFees {
+	return &PairFees{
+		Pair:  pair,
+		Base:  p.UnpackUint64(false),
+		Quote: p.UnpackUint64(false),
+	}
+}
+
+// feeToken is the token an order on [side] of [pair] pays its fees in.
+func (fs *FeeSchedule) feeToken(pair Pair, side bool) ids.ID {
+	if !side && fs.upfront(side) {
+		return pair.BaseTokenID
+	}
+	return pair.QuoteTokenID
+}
+
+// CollectFees sums the maker and taker fees of [trades] per pair, in the
+// order the pairs first appear. Fees are recorded on trades rounded down, so
+// the sum never exceeds what was kept from the orders.
+func (fs *FeeSchedule) CollectFees(trades []*Trade) []*PairFees {
+	var collected []*PairFees
+	byPair := make(map[Pair]*PairFees)
+	for _, trade := range trades {
+		pf, ok := byPair[trade.Pair]
+		if !ok {
+			pf = &PairFees{Pair: trade.Pair}
+			byPair[trade.Pair] = pf
+			collected = append(collected, pf)
+		}
+		pf.Add(fs.feeToken(trade.Pair, !trade.TakerSide), trade.MakerFee)
+		pf.Add(fs.feeToken(trade.Pair, trade.TakerSide), trade.TakerFee)
+	}
+	return collected
+}
@@ -0,0 +1,132 @@
+package orderbook
+
+import (
+	"math"
+	"sort"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/hashing"
+	"github.com/jaimi-io/hypersdk/codec"
+	"github.com/jaimi-io/hypersdk/crypto"
+)
+
+// Leaves and inner nodes are hashed with different prefixes so a leaf can
+// never be passed off as an inner node.
+const (
+	leafPrefix  = byte(0x0)
+	innerPrefix = byte(0x1)
+)
+
+// Root commits to the full state of the manager. The first leaf covers the
+// last block height and pending funds, followed by one leaf per pair in
+// sorted order, so nodes holding the same books return the same root.
+func (obm *OrderbookManager) Root() ids.ID {
+	pairs := obm.sortedPairs()
+	leaves := make([]ids.ID, 0, len(pairs)+1)
+	leaves = append(leaves, obm.pendingFundsLeaf())
+	for _, pair := range pairs {
+		leaves = append(leaves, obm.orderbooks[pair].leaf())
+	}
+	return merkleRoot(leaves)
+}
+
+// pendingFundsLeaf hashes the last block height followed by the hash of each
+// user's pending funds. A user's hash is cached until their funds change, so
+// only users paid in the latest block are hashed again.
+func (obm *OrderbookManager) pendingFundsLeaf() ids.ID {
+	users := sortedUsers(obm.pendingFunds)
+	p := codec.NewWriter(math.MaxInt)
+	p.PackByte(leafPrefix)
+	p.PackUint64(obm.lastBlockHeight)
+	p.PackInt(len(users))
+	for _, user := range users {
+		p.PackPublicKey(user)
+		p.PackID(obm.userFundsHash(user))
+	}
+	return hashing.ComputeHash256Array(p.Bytes())
+}
+
+func (obm *OrderbookManager) userFundsHash(user crypto.PublicKey) ids.ID {
+	if h, ok := obm.pendingFundsHashes[user]; ok {
+		return h
+	}
+	p := codec.NewWriter(math.MaxInt)
+	tokens := sortedIDs(obm.pendingFunds[user])
+	p.PackInt(len(tokens))
+	for _, tokenID := range tokens {
+		p.PackID(tokenID)
+		obm.pendingFunds[user][tokenID].Marshal(p)
+	}
+	h := ids.ID(hashing.ComputeHash256Array(p.Bytes()))
+	obm.pendingFundsHashes[user] = h
+	return h
+}
+
+// leaf hashes the book followed by its mid price, which moves every block.
+// The rest of the book is hashed once after each change, see hash.
+func (ob *Orderbook) leaf() ids.ID {
+	p := codec.NewWriter(math.MaxInt)
+	p.PackByte(leafPrefix)
+	p.PackID(ob.hash())
+	ob.midPrice.Marshal(p)
+	return hashing.ComputeHash256Array(p.Bytes())
+}
+
+// hash commits to the canonical serialization of the book, less its mid
+// price, followed by its aggregate volumes. The volumes are derived from the
+// resting orders but are committed to separately so drift in the bookkeeping
+// is also detected. The hash is cached until changed is called.
+func (ob *Orderbook) hash() ids.ID {
+	if ob.bookHash != nil {
+		return *ob.bookHash
+	}
+	p := codec.NewWriter(math.MaxInt)
+	p.PackID(ob.pair.BaseTokenID)
+	p.PackID(ob.pair.QuoteTokenID)
+	marshalOrders(p, ob.restingOrders())
+	ob.marshalExecutionHistory(p)
+	marshalOrders(p, ob.stops.stopOrders())
+
+	p.PackUint64(ob.buySideVolume)
+	p.PackUint64(ob.sellSideVolume)
+	prices := make([]uint64, 0, len(ob.volumeMap))
+	for price, volume := range ob.volumeMap {
+		if volume > 0 {
+			prices = append(prices, price)
+		}
+	}
+	sort.Slice(prices, func(i, j int) bool { return prices[i] < prices[j] })
+	p.PackInt(len(prices))
+	for _, price := range prices {
+		p.PackUint64(price)
+		p.PackUint64(ob.volumeMap[price])
+	}
+	h := ids.ID(hashing.ComputeHash256Array(p.Bytes()))
+	ob.bookHash = &h
+	return h
+}
+
+// merkleRoot folds [leaves] pairwise until one node is left. An unpaired node
+// is carried up to the next level as is rather than hashed with itself.
+func merkleRoot(leaves []ids.ID) ids.ID {
+	if len(leaves) == 0 {
+		return ids.Empty
+	}
+	level := leaves
+	for len(level) > 1 {
+		next := make([]ids.ID, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			buf := make([]byte, 0, 1+2*hashing.HashLen)
+			buf = append(buf, innerPrefix)
+			buf = append(buf, level[i][:]...)
+			buf = append(buf, level[i+1][:]...)
+			next = append(next, hashing.ComputeHash256Array(buf))
+		}
+		level = next
+	}
+	return level[0]
+}
@@ -0,0 +1,107 @@
+package orderbook
+
+import (
+	"github.com/jaimi-io/clobvm/metrics"
+	"github.com/jaimi-io/clobvm/queue"
+)
+
+// SelfTradePrevention is what happens when an incoming order would match a
+// resting order of the same user. The mode of the incoming order applies and,
+// unless it is AllowSelfTrade, the two orders never trade.
+type SelfTradePrevention byte
+
+const (
+	// AllowSelfTrade lets the orders trade like any other two, as orders did
+	// before self-trade prevention. It is the zero value so orders that do not
+	// ask for prevention keep doing so.
+	AllowSelfTrade SelfTradePrevention = iota
+	// CancelNewest cancels what is left of the incoming order.
+	CancelNewest
+	// CancelOldest cancels the resting order and keeps matching.
+	CancelOldest
+	// CancelBoth cancels the resting order and what is left of the incoming
+	// order.
+	CancelBoth
+	// DecrementAndCancel takes the smaller of the two orders off both of them,
+	// cancelling the smaller one and keeping the larger one in play.
+	DecrementAndCancel
+)
+
+func (stp SelfTradePrevention) Valid() bool {
+	return stp <= DecrementAndCancel
+}
+
+// prevents reports whether an incoming order is kept from trading with
+// resting orders of its own user.
+func (stp SelfTradePrevention) prevents() bool {
+	return stp != AllowSelfTrade
+}
+
+// stopsMatching reports whether meeting a resting order of its own user can
+// end the matching of an incoming order.
+func (stp SelfTradePrevention) stopsMatching() bool {
+	return stp.prevents() && stp != CancelOldest
+}
+
+// preventSelfTrade applies the mode of [order] to [resting], the order at the
+// front of [level], which belongs to the same user. Quantity taken off
+// [resting] is refunded here; quantity taken off [order] is left for the
+// caller to refund.
+func (ob *Orderbook) preventSelfTrade(level *queue.LinkedMapQueue[*Order, uint64], resting *Order, order *Order, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
+	switch order.SelfTradePrevention {
+	case CancelOldest:
+		ob.cancelFront(level, resting, pendingAmounts, metrics)
+	case CancelBoth:
+		ob.cancelFront(level, resting, pendingAmounts, metrics)
+		order.selfTradeCancelled = true
+	case DecrementAndCancel:
+		if total := resting.Quantity + resting.Hidden; total <= order.Quantity {
+			ob.cancelFront(level, resting, pendingAmounts, metrics)
+			order.Quantity -= total
+			order.selfTraded += total
+			order.selfTradeCancelled = order.Quantity == 0
+		} else {
+			ob.decrementFront(level, resting, order.Quantity, pendingAmounts, metrics)
+			order.selfTraded += order.Quantity
+			order.Quantity = 0
+			order.selfTradeCancelled = true
+		}
+	case CancelNewest:
+		order.selfTradeCancelled = true
+	}
+}
+
+// cancelFront cancels [resting], the order at the front of [level].
+func (ob *Orderbook) cancelFront(level *queue.LinkedMapQueue[*Order, uint64], resting *Order, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
+	ob.Remove(level.Pop(), metrics)
+	ob.refundAmount(resting, resting.Quantity+resting.Hidden, pendingAmounts)
+	ob.close(resting, Cancelled)
+	metrics.OrderCancelNum()
+}
+
+// decrementFront takes [quantity], less than all of it, off [resting], the
+// order at the front of [level]. The visible quantity goes first, and an
+// iceberg shows its next peak if that runs out.
+func (ob *Orderbook) decrementFront(level *queue.LinkedMapQueue[*Order, uint64], resting *Order, quantity uint64, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
+	visible := min(resting.Quantity, quantity)
+	resting.Quantity -= visible
+	resting.Hidden -= quantity - visible
+	ob.subVolume(resting.Price, resting.Side, visible)
+	metrics.OrderAmountSub(visible)
+	if resting.Quantity == 0 {
+		ob.refreshIceberg(level.Pop(), level, metrics)
+	}
+	ob.refundAmount(resting, quantity, pendingAmounts)
+}
+
+// takeSelfTraded returns the quantity of [order] that self-trade prevention
+// took off without filling, including what is left of it if it was
+// cancelled, and resets it.
+func (o *Order) takeSelfTraded() uint64 {
+	quantity := o.selfTraded
+	if o.selfTradeCancelled {
+		quantity += o.Quantity
+	}
+	o.selfTraded = 0
+	return quantity
+}
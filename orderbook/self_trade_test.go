@@ -0,0 +1,135 @@
+package orderbook
+
+import (
+	"testing"
+
+	"github.com/jaimi-io/clobvm/utils"
+)
+
+// selfTradeBook rests an own order of 2 ahead of an order of 5 from another
+// user on the 10_000 ask and returns both.
+func selfTradeBook(t *testing.T) (*testBook, *Order, *Order) {
+	tb := newTestBook(t)
+	self := tb.place(alice, false, 10_000, 2)
+	other := tb.place(bob, false, 10_000, 5)
+	tb.pendingAmounts = nil
+	return tb, self, other
+}
+
+// selfTrade adds a buy of alice for [quantity] with [stp] to the book.
+func (tb *testBook) selfTrade(stp SelfTradePrevention, quantity uint64) *Order {
+	order := tb.order(alice, true, 10_000, quantity)
+	order.SelfTradePrevention = stp
+	return tb.add(order)
+}
+
+func TestSelfTradePreventionValid(t *testing.T) {
+	for _, stp := range []SelfTradePrevention{AllowSelfTrade, CancelNewest, CancelOldest, CancelBoth, DecrementAndCancel} {
+		if !stp.Valid() {
+			t.Fatalf("mode %d is not valid", stp)
+		}
+	}
+	if (DecrementAndCancel + 1).Valid() {
+		t.Fatalf("mode %d is valid", DecrementAndCancel+1)
+	}
+}
+
+func TestAllowSelfTrade(t *testing.T) {
+	tb, self, other := selfTradeBook(t)
+	order := tb.selfTrade(AllowSelfTrade, 3)
+	if order.Status != Filled || self.Status != Filled {
+		t.Fatalf("order is %s and own order %s, want both filled", order.Status, self.Status)
+	}
+	if other.Quantity != 4 {
+		t.Fatalf("other order shows %d, want 4", other.Quantity)
+	}
+	if len(tb.trades) != 2 {
+		t.Fatal("self-trade was not recorded as a trade")
+	}
+}
+
+func TestCancelNewest(t *testing.T) {
+	tb, self, other := selfTradeBook(t)
+	order := tb.selfTrade(CancelNewest, 3)
+	if order.Status != Cancelled {
+		t.Fatalf("order is %s, want cancelled", order.Status)
+	}
+	if tb.Get(self.ID) != self || self.Quantity != 2 || other.Quantity != 5 {
+		t.Fatal("resting orders changed")
+	}
+	if got, want := tb.pending(alice, tb.pair.QuoteTokenID), tb.unfilledRefund(order, 10_000, 3); got != want {
+		t.Fatalf("refunded %d, want %d", got, want)
+	}
+}
+
+func TestCancelOldest(t *testing.T) {
+	tb, self, other := selfTradeBook(t)
+	order := tb.selfTrade(CancelOldest, 3)
+	if order.Status != Filled {
+		t.Fatalf("order is %s, want filled past its own order", order.Status)
+	}
+	if self.Status != Cancelled || tb.Get(self.ID) != nil {
+		t.Fatalf("own order is %s, want cancelled", self.Status)
+	}
+	if other.Quantity != 2 {
+		t.Fatalf("other order shows %d, want 2", other.Quantity)
+	}
+	if got, want := tb.pending(alice, tb.pair.BaseTokenID), utils.QuantityToBalance(3)+tb.lockedRefund(self, 2); got != want {
+		t.Fatalf("received %d of the base token, want %d", got, want)
+	}
+}
+
+func TestCancelBoth(t *testing.T) {
+	tb, self, other := selfTradeBook(t)
+	order := tb.selfTrade(CancelBoth, 3)
+	if order.Status != Cancelled || self.Status != Cancelled {
+		t.Fatalf("order is %s and own order %s, want both cancelled", order.Status, self.Status)
+	}
+	if other.Quantity != 5 {
+		t.Fatalf("other order shows %d, want 5", other.Quantity)
+	}
+	if got, want := tb.pending(alice, tb.pair.BaseTokenID), tb.lockedRefund(self, 2); got != want {
+		t.Fatalf("refunded %d of the own order, want %d", got, want)
+	}
+	if got, want := tb.pending(alice, tb.pair.QuoteTokenID), tb.unfilledRefund(order, 10_000, 3); got != want {
+		t.Fatalf("refunded %d of the order, want %d", got, want)
+	}
+}
+
+func TestDecrementAndCancelSmallerResting(t *testing.T) {
+	tb, self, other := selfTradeBook(t)
+	order := tb.selfTrade(DecrementAndCancel, 3)
+	if self.Status != Cancelled {
+		t.Fatalf("own order is %s, want cancelled", self.Status)
+	}
+	// 2 is taken off both orders and the last 1 fills against the other user
+	if order.Status != Filled || other.Quantity != 4 {
+		t.Fatalf("order is %s with other order showing %d, want filled and 4", order.Status, other.Quantity)
+	}
+	if got, want := tb.pending(alice, tb.pair.BaseTokenID), utils.QuantityToBalance(1)+tb.lockedRefund(self, 2); got != want {
+		t.Fatalf("received %d of the base token, want %d", got, want)
+	}
+	if got, want := tb.pending(alice, tb.pair.QuoteTokenID), tb.unfilledRefund(order, 10_000, 2); got != want {
+		t.Fatalf("refunded %d of the quote token, want %d", got, want)
+	}
+}
+
+func TestDecrementAndCancelSmallerIncoming(t *testing.T) {
+	tb, self, other := selfTradeBook(t)
+	order := tb.selfTrade(DecrementAndCancel, 1)
+	if order.Status != Cancelled {
+		t.Fatalf("order is %s, want cancelled", order.Status)
+	}
+	if tb.Get(self.ID) != self || self.Quantity != 1 || tb.volumeMap[10_000] != 6 {
+		t.Fatalf("own order shows %d with level volume %d, want 1 and 6", self.Quantity, tb.volumeMap[10_000])
+	}
+	if other.Quantity != 5 {
+		t.Fatalf("other order shows %d, want 5", other.Quantity)
+	}
+	if got, want := tb.pending(alice, tb.pair.BaseTokenID), tb.lockedRefund(self, 1); got != want {
+		t.Fatalf("refunded %d of the own order, want %d", got, want)
+	}
+	if got, want := tb.pending(alice, tb.pair.QuoteTokenID), tb.unfilledRefund(order, 10_000, 1); got != want {
+		t.Fatalf("refunded %d of the order, want %d", got, want)
+	}
+}
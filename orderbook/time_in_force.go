@@ -0,0 +1,67 @@
+package orderbook
+
+// TimeInForce controls what happens to the part of a limit order that does
+// not match on entry.
+type TimeInForce byte
+
+const (
+	// GoodTillCancel rests the unmatched quantity until it is cancelled or
+	// evicted.
+	GoodTillCancel TimeInForce = iota
+	// PostOnly rejects the order if any of it would match on entry, so it only
+	// ever pays the maker fee.
+	PostOnly
+	// ImmediateOrCancel matches what it can and refunds the rest.
+	ImmediateOrCancel
+	// FillOrKill matches the full quantity on entry or rejects the order.
+	FillOrKill
+)
+
+func (tif TimeInForce) Valid() bool {
+	return tif <= FillOrKill
+}
+
+// crosses reports whether [order] would match against the opposite side.
+func (ob *Orderbook) crosses(order *Order) bool {
+	heap := ob.getOppositeHeap(order.Side)
+	return heap.Len() > 0 && getMatchPriceFn(order.Side)(heap.Peek().Priority(), order.Price)
+}
+
+// crossingVolume returns how much of [order] would fill on entry, walking the
+// opposite side in price-time order until it is covered. Orders of the same
+// user only fill it if it allows self-trades, and otherwise meeting one ends
+// the walk if it would stop its matching. Iceberg reserves refresh behind the
+// rest of their level, so they only count once every order on it has been
+// passed.
+func (ob *Orderbook) crossingVolume(order *Order) uint64 {
+	matchPriceFn := getMatchPriceFn(order.Side)
+	var volume uint64
+	for _, level := range ob.restingLevels(!order.Side) {
+		if !matchPriceFn(level[0].Price, order.Price) {
+			break
+		}
+		var hidden uint64
+		for _, resting := range level {
+			if volume >= order.Quantity {
+				return volume
+			}
+			if resting.User != order.User || !order.SelfTradePrevention.prevents() {
+				volume += resting.Quantity
+				hidden += resting.Hidden
+			} else if order.SelfTradePrevention.stopsMatching() {
+				return volume
+			}
+		}
+		volume += hidden
+	}
+	return volume
+}
+
+// refundUnfilled returns [quantity] of an order that will not rest along with
+// the taker fee charged for it.
+func (ob *Orderbook) refundUnfilled(order *Order, blockTs int64, quantity uint64, pendingAmounts *[]PendingAmt) {
+	if quantity == 0 {
+		return
+	}
+	ob.refundAmount(order, quantity+ob.RefundMarketOrderFee(order.User, order.Side, blockTs, quantity), pendingAmounts)
+}
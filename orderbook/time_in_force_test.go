@@ -0,0 +1,220 @@
+package orderbook
+
+import (
+	"testing"
+
+	ametrics "github.com/ava-labs/avalanchego/api/metrics"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/jaimi-io/clobvm/consts"
+	"github.com/jaimi-io/clobvm/metrics"
+	"github.com/jaimi-io/clobvm/utils"
+	"github.com/jaimi-io/hypersdk/crypto"
+)
+
+var (
+	alice = crypto.PublicKey{1}
+	bob   = crypto.PublicKey{2}
+	carol = crypto.PublicKey{3}
+)
+
+// testBook is an empty book with the default fees that orders are added to
+// one block at a time.
+type testBook struct {
+	*Orderbook
+	t              *testing.T
+	m              *metrics.Metrics
+	height         uint64
+	pendingAmounts []PendingAmt
+}
+
+func newTestBook(t *testing.T) *testBook {
+	m, err := metrics.NewMetrics(ametrics.NewMultiGatherer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testBook{
+		Orderbook: NewOrderbook(Pair{ids.GenerateTestID(), ids.GenerateTestID()}, DefaultFeeSchedule(), consts.PendingBlockWindow),
+		t:         t,
+		m:         m,
+		height:    1,
+	}
+}
+
+// order returns a limit order of [user] for [quantity] whole quantity units.
+func (tb *testBook) order(user crypto.PublicKey, side bool, price uint64, quantity uint64) *Order {
+	return NewOrder(ids.GenerateTestID(), user, price, utils.QuantityToBalance(quantity), side, tb.height, 100)
+}
+
+// add adds [order] to the book and returns it.
+func (tb *testBook) add(order *Order) *Order {
+	tb.Add(order, tb.height, 0, &tb.pendingAmounts, tb.m)
+	return order
+}
+
+// place adds a good till cancel limit order to the book and returns it.
+func (tb *testBook) place(user crypto.PublicKey, side bool, price uint64, quantity uint64) *Order {
+	return tb.add(tb.order(user, side, price, quantity))
+}
+
+// resting returns the quantity of each order resting on [side],
+// including iceberg reserves.
+func (tb *testBook) resting(side bool) map[ids.ID]uint64 {
+	resting := make(map[ids.ID]uint64)
+	for _, level := range tb.getOppositeHeap(!side).Values() {
+		for _, order := range level {
+			resting[order.ID] = order.Quantity + order.Hidden
+		}
+	}
+	return resting
+}
+
+// pending returns what was added to [user]'s pending funds of [tokenID] and
+// resets it.
+func (tb *testBook) pending(user crypto.PublicKey, tokenID ids.ID) uint64 {
+	var amount uint64
+	var rest []PendingAmt
+	for _, pendingAmt := range tb.pendingAmounts {
+		if pendingAmt.User == user && pendingAmt.TokenID == tokenID {
+			amount += pendingAmt.Amount
+		} else {
+			rest = append(rest, pendingAmt)
+		}
+	}
+	tb.pendingAmounts = rest
+	return amount
+}
+
+func TestFillOrKillCoveredBeforeSelfOrder(t *testing.T) {
+	for _, stp := range []SelfTradePrevention{CancelNewest, CancelBoth, DecrementAndCancel} {
+		tb := newTestBook(t)
+		tb.place(bob, false, 10_000, 2)
+		self := tb.place(alice, false, 10_000, 1)
+
+		order := tb.order(alice, true, 10_100, 2)
+		order.TimeInForce = FillOrKill
+		order.SelfTradePrevention = stp
+		tb.add(order)
+		if order.Status != Filled {
+			t.Fatalf("mode %d: order is %s, want filled before reaching its own order", stp, order.Status)
+		}
+		if tb.Get(self.ID) == nil {
+			t.Fatalf("mode %d: own order was taken off the book", stp)
+		}
+	}
+}
+
+func TestFillOrKillStopsAtSelfOrder(t *testing.T) {
+	for _, stp := range []SelfTradePrevention{CancelNewest, CancelBoth, DecrementAndCancel} {
+		tb := newTestBook(t)
+		tb.place(bob, false, 10_000, 2)
+		tb.place(alice, false, 10_000, 1)
+		tb.place(bob, false, 10_050, 5)
+		before := tb.resting(false)
+
+		order := tb.order(alice, true, 10_100, 3)
+		order.TimeInForce = FillOrKill
+		order.SelfTradePrevention = stp
+		tb.add(order)
+		if order.Status != Cancelled {
+			t.Fatalf("mode %d: order is %s, want killed at its own order", stp, order.Status)
+		}
+		if after := tb.resting(false); len(after) != len(before) {
+			t.Fatalf("mode %d: killed order changed the book", stp)
+		}
+	}
+}
+
+func TestFillOrKillCancelOldestSkipsSelfOrder(t *testing.T) {
+	tb := newTestBook(t)
+	tb.place(bob, false, 10_000, 2)
+	self := tb.place(alice, false, 10_000, 1)
+	tb.place(bob, false, 10_050, 5)
+
+	order := tb.order(alice, true, 10_100, 3)
+	order.TimeInForce = FillOrKill
+	order.SelfTradePrevention = CancelOldest
+	tb.add(order)
+	if order.Status != Filled {
+		t.Fatalf("order is %s, want filled past its own order", order.Status)
+	}
+	if self.Status != Cancelled {
+		t.Fatalf("own order is %s, want cancelled", self.Status)
+	}
+}
+
+func TestFillOrKillCountsSelfOrderAtWorsePrice(t *testing.T) {
+	tb := newTestBook(t)
+	tb.place(alice, false, 10_050, 1)
+	tb.place(bob, false, 10_000, 2)
+
+	order := tb.order(alice, true, 10_100, 2)
+	order.TimeInForce = FillOrKill
+	order.SelfTradePrevention = CancelNewest
+	tb.add(order)
+	if order.Status != Filled {
+		t.Fatalf("order is %s, want filled at the better price", order.Status)
+	}
+}
+
+func TestFillOrKillIcebergRefreshesBehindLevel(t *testing.T) {
+	tb := newTestBook(t)
+	iceberg := tb.order(bob, false, 10_000, 5)
+	iceberg.DisplayQuantity = 1
+	tb.add(iceberg)
+	tb.place(alice, false, 10_000, 1)
+
+	// Only the peak is ahead of the own order
+	order := tb.order(alice, true, 10_000, 2)
+	order.TimeInForce = FillOrKill
+	order.SelfTradePrevention = CancelNewest
+	tb.add(order)
+	if order.Status != Cancelled {
+		t.Fatalf("order is %s, want killed at its own order", order.Status)
+	}
+
+	order = tb.order(alice, true, 10_000, 1)
+	order.TimeInForce = FillOrKill
+	order.SelfTradePrevention = CancelNewest
+	tb.add(order)
+	if order.Status != Filled {
+		t.Fatalf("order is %s, want filled by the peak", order.Status)
+	}
+}
+
+func TestImmediateOrCancelStopsAtSelfOrder(t *testing.T) {
+	tb := newTestBook(t)
+	tb.place(bob, false, 10_000, 2)
+	self := tb.place(alice, false, 10_000, 1)
+	tb.place(bob, false, 10_050, 5)
+	tb.pendingAmounts = nil
+
+	order := tb.order(alice, true, 10_100, 4)
+	order.TimeInForce = ImmediateOrCancel
+	order.SelfTradePrevention = CancelNewest
+	tb.add(order)
+	if order.Status != Cancelled {
+		t.Fatalf("order is %s, want cancelled", order.Status)
+	}
+	if got := tb.pending(alice, tb.pair.BaseTokenID); got != utils.QuantityToBalance(2) {
+		t.Fatalf("filled %d, want %d", got, utils.QuantityToBalance(2))
+	}
+	if tb.Get(self.ID) == nil {
+		t.Fatal("own order was taken off the book")
+	}
+	if tb.resting(true)[order.ID] != 0 {
+		t.Fatal("order rests on the book")
+	}
+}
+
+func TestFillOrKillCountsSelfOrderWhenSelfTradesAllowed(t *testing.T) {
+	tb := newTestBook(t)
+	tb.place(bob, false, 10_000, 2)
+	self := tb.place(alice, false, 10_000, 1)
+
+	order := tb.order(alice, true, 10_000, 3)
+	order.TimeInForce = FillOrKill
+	tb.add(order)
+	if order.Status != Filled || self.Status != Filled {
+		t.Fatalf("order is %s and own order %s, want both filled", order.Status, self.Status)
+	}
+}
@@ -0,0 +1,107 @@
+package orderbook
+
+import (
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/jaimi-io/clobvm/utils"
+	"github.com/jaimi-io/hypersdk/codec"
+	"github.com/jaimi-io/hypersdk/crypto"
+)
+
+// Trade is a fill between a resting maker order and an incoming taker order.
+// Quantity is in quantity units and fees are in balance units of the token
+// each side paid with.
+type Trade struct {
+	Pair         Pair
+	BlockHeight  uint64
+	Index        uint32 // position within the block
+	Timestamp    int64
+	MakerOrderID ids.ID
+	Maker        crypto.PublicKey
+	TakerOrderID ids.ID
+	Taker        crypto.PublicKey
+	TakerSide    bool
+	Price        uint64
+	Quantity     uint64
+	MakerFee     uint64
+	TakerFee     uint64
+}
+
+// feeAmount is the fee at [rate] on [quantity] of [order] traded at [price],
+// in the token [order] pays its fees with.
+func (ob *Orderbook) feeAmount(order *Order, quantity uint64, price uint64, rate uint64) uint64 {
+	getAmount := GetAmountFn(order.Side, !ob.fees.upfront(order.Side), ob.pair)
+	amount, _ := getAmount(applyRate(quantity, rate), price)
+	return amount * utils.MinQuantity()
+}
+
+// recordTrade notes that [taker] filled [quantity] of the resting [maker].
+// Trades are collected until the end of the block by TakeTrades.
+func (ob *Orderbook) recordTrade(maker *Order, taker *Order, quantity uint64, blockTs int64) *Trade {
+	trade := &Trade{
+		Pair:         ob.pair,
+		Timestamp:    blockTs,
+		MakerOrderID: maker.ID,
+		Maker:        maker.User,
+		TakerOrderID: taker.ID,
+		Taker:        taker.User,
+		TakerSide:    taker.Side,
+		Price:        maker.Price,
+		Quantity:     quantity,
+		MakerFee:     ob.feeAmount(maker, quantity, maker.Price, maker.Fee),
+		TakerFee:     ob.feeAmount(taker, quantity, maker.Price, ob.GetTakerFeeRate(taker.User, blockTs)),
+	}
+	ob.trades = append(ob.trades, trade)
+	return trade
+}
+
+// TakeTrades returns the trades made since it was last called, pair by pair
+// in sorted order, numbered in that order as trades of [blockHeight].
+func (obm *OrderbookManager) TakeTrades(blockHeight uint64) []*Trade {
+	var trades []*Trade
+	for _, pair := range obm.sortedPairs() {
+		ob := obm.orderbooks[pair]
+		trades = append(trades, ob.trades...)
+		ob.trades = nil
+	}
+	for i, trade := range trades {
+		trade.BlockHeight = blockHeight
+		trade.Index = uint32(i)
+	}
+	return trades
+}
+
+func (t *Trade) Marshal(p *codec.Packer) {
+	p.PackID(t.Pair.BaseTokenID)
+	p.PackID(t.Pair.QuoteTokenID)
+	p.PackUint64(t.BlockHeight)
+	p.PackInt(int(t.Index))
+	p.PackInt64(t.Timestamp)
+	p.PackID(t.MakerOrderID)
+	p.PackPublicKey(t.Maker)
+	p.PackID(t.TakerOrderID)
+	p.PackPublicKey(t.Taker)
+	p.PackBool(t.TakerSide)
+	p.PackUint64(t.Price)
+	p.PackUint64(t.Quantity)
+	p.PackUint64(t.MakerFee)
+	p.PackUint64(t.TakerFee)
+}
+
+func UnmarshalTrade(p *codec.Packer) *Trade {
+	var t Trade
+	p.UnpackID(true, &t.Pair.BaseTokenID)
+	p.UnpackID(true, &t.Pair.QuoteTokenID)
+	t.BlockHeight = p.UnpackUint64(true)
+	t.Index = uint32(p.UnpackInt(false))
+	t.Timestamp = p.UnpackInt64(false)
+	p.UnpackID(true, &t.MakerOrderID)
+	p.UnpackPublicKey(true, &t.Maker)
+	p.UnpackID(true, &t.TakerOrderID)
+	p.UnpackPublicKey(true, &t.Taker)
+	t.TakerSide = p.UnpackBool()
+	t.Price = p.UnpackUint64(true)
+	t.Quantity = p.UnpackUint64(true)
+	t.MakerFee = p.UnpackUint64(false)
+	t.TakerFee = p.UnpackUint64(false)
+	return &t
+}
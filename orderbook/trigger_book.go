@@ -0,0 +1,152 @@
+package orderbook
+
+import (
+	"sort"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/jaimi-io/clobvm/heap"
+	"github.com/jaimi-io/clobvm/metrics"
+)
+
+// TriggerBook holds the stop orders of a pair until the mid price reaches
+// their trigger price. Buy stops fire once the mid price rises to their
+// trigger and sell stops once it falls to theirs, so the lowest buy trigger
+// and the highest sell trigger are the next to fire.
+type TriggerBook struct {
+	buyStops  *heap.PriorityQueueHeap[*Order, uint64]
+	sellStops *heap.PriorityQueueHeap[*Order, uint64]
+	orders    map[ids.ID]*Order
+}
+
+func NewTriggerBook() *TriggerBook {
+	return &TriggerBook{
+		buyStops:  heap.NewPriorityQueueHeap[*Order, uint64](64, true),
+		sellStops: heap.NewPriorityQueueHeap[*Order, uint64](64, false),
+		orders:    make(map[ids.ID]*Order),
+	}
+}
+
+func (tb *TriggerBook) getHeap(side bool) *heap.PriorityQueueHeap[*Order, uint64] {
+	if side {
+		return tb.buyStops
+	}
+	return tb.sellStops
+}
+
+func (tb *TriggerBook) add(order *Order) {
+	tb.orders[order.ID] = order
+	tb.getHeap(order.Side).Add(order, order.ID, order.TriggerPrice)
+}
+
+func (tb *TriggerBook) get(id ids.ID) *Order {
+	return tb.orders[id]
+}
+
+func (tb *TriggerBook) remove(order *Order) {
+	tb.getHeap(order.Side).Remove(order.ID, order.TriggerPrice)
+	delete(tb.orders, order.ID)
+}
+
+func (tb *TriggerBook) Len() int {
+	return len(tb.orders)
+}
+
+// next returns the stop order that fires first at [midPrice], or nil if none
+// do. Buy stops are checked before sell stops.
+func (tb *TriggerBook) next(midPrice uint64) *Order {
+	if tb.buyStops.Len() > 0 && tb.buyStops.Peek().Priority() <= midPrice {
+		return tb.buyStops.Peek().Peek()
+	}
+	if tb.sellStops.Len() > 0 && tb.sellStops.Peek().Priority() >= midPrice {
+		return tb.sellStops.Peek().Peek()
+	}
+	return nil
+}
+
+// stopOrders returns every stop order in the order they would fire: buy
+// stops from lowest to highest trigger followed by sell stops from highest to
+// lowest trigger.
+func (tb *TriggerBook) stopOrders() []*Order {
+	orders := make([]*Order, 0, len(tb.orders))
+	for _, side := range []bool{true, false} {
+		var levels [][]*Order
+		for _, level := range tb.getHeap(side).Values() {
+			if len(level) > 0 {
+				levels = append(levels, level)
+			}
+		}
+		sort.Slice(levels, func(i, j int) bool {
+			if side {
+				return levels[i][0].TriggerPrice < levels[j][0].TriggerPrice
+			}
+			return levels[i][0].TriggerPrice > levels[j][0].TriggerPrice
+		})
+		for _, level := range levels {
+			orders = append(orders, level...)
+		}
+	}
+	return orders
+}
+
+// AddStop holds [order] until its trigger price is reached. The taker rate is
+// recorded as the order's fee so cancelling it refunds the full taker fee
+// charged when it was submitted.
+func (ob *Orderbook) AddStop(order *Order, blockHeight uint64, blockTs int64, metrics *metrics.Metrics) {
+	ob.AddToEviction(order)
+	order.Fee = ob.GetTakerFeeRate(order.User, blockTs)
+	ob.stops.add(order)
+	ob.changed()
+	ob.addOpenOrder(order)
+	metrics.StopOrder()
+}
+
+func (ob *Orderbook) GetStop(id ids.ID) *Order {
+	return ob.stops.get(id)
+}
+
+func (ob *Orderbook) CancelStop(order *Order, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
+	ob.cancelStop(order, Cancelled, pendingAmounts, metrics)
+}
+
+func (ob *Orderbook) cancelStop(order *Order, status OrderStatus, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
+	ob.stops.remove(order)
+	ob.changed()
+	ob.removeFromEviction(order)
+	delete(ob.openOrders[order.User], order.ID)
+	refund := *order
+	if refund.Price == 0 {
+		refund.Price = order.WorstPrice
+	}
+	ob.refundAmount(&refund, order.Quantity, pendingAmounts)
+	ob.close(order, status)
+	metrics.OrderCancelNum()
+}
+
+// Trigger enters every stop order whose trigger price has been reached. Each
+// order entered can move the mid price, so stops are fired one at a time
+// until none are left at the current mid price.
+func (ob *Orderbook) Trigger(blockHeight uint64, blockTs int64, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
+	for {
+		midPrice := ob.GetMidPrice()
+		if midPrice == 0 {
+			return
+		}
+		order := ob.stops.next(midPrice)
+		if order == nil {
+			return
+		}
+		ob.stops.remove(order)
+		ob.changed()
+		ob.removeFromEviction(order)
+		delete(ob.openOrders[order.User], order.ID)
+		order.Fee = 0
+		ob.Add(order, blockHeight, blockTs, pendingAmounts, metrics)
+		metrics.StopOrderTriggered()
+	}
+}
+
+func (obm *OrderbookManager) TriggerAllPairs(blockHeight uint64, blockTs int64, pendingAmounts *[]PendingAmt, metrics *metrics.Metrics) {
+	for _, pair := range obm.sortedPairs() {
+		obm.orderbooks[pair].Trigger(blockHeight, blockTs, pendingAmounts, metrics)
+	}
+}
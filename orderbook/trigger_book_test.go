@@ -0,0 +1,117 @@
+package orderbook
+
+import (
+	"testing"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/jaimi-io/hypersdk/crypto"
+)
+
+// stop holds a stop limit order of [user] for [quantity] whole quantity units
+// until the mid price reaches [trigger], and returns it.
+func (tb *testBook) stop(user crypto.PublicKey, side bool, price uint64, trigger uint64, quantity uint64) *Order {
+	order := tb.order(user, side, price, quantity)
+	order.TriggerPrice = trigger
+	tb.AddStop(order, tb.height, 0, tb.m)
+	return order
+}
+
+// trigger fires the stops reached at the current mid price and returns the
+// stops that left the book, in the order they did.
+func (tb *testBook) trigger() []*Order {
+	tb.closed = nil
+	tb.Trigger(tb.height, 0, &tb.pendingAmounts, tb.m)
+	var fired []*Order
+	for _, order := range tb.closed {
+		if order.TriggerPrice > 0 {
+			fired = append(fired, order)
+		}
+	}
+	return fired
+}
+
+func orderIDs(orders []*Order) []ids.ID {
+	orderIDs := make([]ids.ID, 0, len(orders))
+	for _, order := range orders {
+		orderIDs = append(orderIDs, order.ID)
+	}
+	return orderIDs
+}
+
+func equalIDs(a []ids.ID, b []ids.ID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestStopOrdersInFiringOrder(t *testing.T) {
+	tb := newTestBook(t)
+	buyHigh := tb.stop(alice, true, 10_500, 10_300, 1)
+	buyLow := tb.stop(alice, true, 10_500, 10_100, 1)
+	buyLowLater := tb.stop(bob, true, 10_500, 10_100, 1)
+	sellLow := tb.stop(alice, false, 9_500, 9_700, 1)
+	sellHigh := tb.stop(bob, false, 9_500, 9_900, 1)
+
+	want := orderIDs([]*Order{buyLow, buyLowLater, buyHigh, sellHigh, sellLow})
+	if got := orderIDs(tb.stops.stopOrders()); !equalIDs(got, want) {
+		t.Fatalf("stop orders %v, want %v", got, want)
+	}
+}
+
+func TestTriggerFiresBestTriggerFirst(t *testing.T) {
+	tb := newTestBook(t)
+	tb.place(carol, true, 9_000, 10)
+	tb.place(carol, false, 10_400, 10)
+	low := tb.stop(alice, false, 9_000, 9_800, 1)
+	high := tb.stop(alice, false, 9_000, 9_950, 1)
+	mid := tb.stop(alice, false, 9_000, 9_900, 1)
+	midLater := tb.stop(bob, false, 9_000, 9_900, 1)
+	untriggered := tb.stop(alice, false, 9_000, 9_600, 1)
+
+	// The mid price is 9_700 and stays there as every stop fills at 9_000
+	want := orderIDs([]*Order{high, mid, midLater, low})
+	fired := tb.trigger()
+	if got := orderIDs(fired); !equalIDs(got, want) {
+		t.Fatalf("fired %v, want %v", got, want)
+	}
+	for _, order := range fired {
+		if order.Status != Filled {
+			t.Fatalf("stop %s is %s, want filled", order.ID, order.Status)
+		}
+	}
+	if tb.GetStop(untriggered.ID) == nil {
+		t.Fatal("stop below the mid price fired")
+	}
+}
+
+func TestTriggeredStopTriggersAnotherStop(t *testing.T) {
+	tb := newTestBook(t)
+	tb.place(bob, true, 9_900, 10)
+	tb.place(bob, false, 10_100, 1)
+	tb.place(bob, false, 10_500, 10)
+	first := tb.stop(alice, true, 10_100, 10_000, 1)
+	second := tb.stop(alice, true, 10_500, 10_200, 1)
+	third := tb.stop(alice, true, 10_500, 10_400, 1)
+
+	// The first stop takes the 10_100 ask, moving the mid price from 10_000
+	// to 10_200 within the same block
+	fired := tb.trigger()
+	if got, want := orderIDs(fired), orderIDs([]*Order{first, second}); !equalIDs(got, want) {
+		t.Fatalf("fired %v, want %v", got, want)
+	}
+	if first.Status != Filled || second.Status != Filled {
+		t.Fatalf("stops are %s and %s, want filled", first.Status, second.Status)
+	}
+	if tb.GetStop(third.ID) == nil {
+		t.Fatal("stop above the mid price fired")
+	}
+	if mid := tb.GetMidPrice(); mid != 10_200 {
+		t.Fatalf("mid price %d, want 10200", mid)
+	}
+}
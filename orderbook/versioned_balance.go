@@ -2,8 +2,6 @@ package orderbook
 
 import (
 	"container/ring"
-
-	"github.com/jaimi-io/clobvm/consts"
 )
 
 type VersionedItem struct {
@@ -18,8 +16,9 @@ type VersionedBalance struct {
 }
 
 
-func NewVersionedBalance(balance uint64, blockHeight uint64) *VersionedBalance {
-	items := ring.New(int(consts.PendingBlockWindow+1))
+// NewVersionedBalance holds enough versions to go back [window] blocks.
+func NewVersionedBalance(balance uint64, blockHeight uint64, window uint64) *VersionedBalance {
+	items := ring.New(int(window+1))
 	items.Value = &VersionedItem{balance, blockHeight}
 	return &VersionedBalance{
 		items: items,
@@ -28,60 +27,48 @@ func NewVersionedBalance(balance uint64, blockHeight uint64) *VersionedBalance {
 	}
 }
 
+// Get returns the balance as of [blockHeight], or 0 if it is older than every
+// version held.
 func (vb *VersionedBalance) Get(blockHeight uint64) (uint64, uint64) {
 	if blockHeight > vb.lastBlockHeight {
 		return vb.lastBalance, vb.lastBlockHeight
 	}
-	var balance uint64
 
 	cur := vb.items
-	for i := 0; i < vb.items.Len() && cur.Value != nil && cur.Value.(*VersionedItem).blkHgt > blockHeight; i++ {
+	for i := 0; i < vb.items.Len() && cur.Value != nil; i++ {
+		if item := cur.Value.(*VersionedItem); item.blkHgt <= blockHeight {
+			return item.bal, blockHeight
+		}
 		cur = cur.Prev()
 	}
+	return 0, blockHeight
+}
 
-	if cur.Value != nil {
-		balance = cur.Value.(*VersionedItem).bal
-	}
-
-	return balance, blockHeight
+// Put adds [amount] to the balance as of [blockHeight].
+func (vb *VersionedBalance) Put(amount uint64, blockHeight uint64) {
+	vb.Set(vb.lastBalance + amount, blockHeight)
 }
 
-func (vb *VersionedBalance) Pull(blockHeight uint64) uint64 {
-	if blockHeight >= vb.lastBlockHeight {
-		vb.items.Value = nil
-		res := vb.lastBalance
-		vb.lastBalance = 0
-		return res
-	}
-	var balance uint64
+// Set replaces the balance as of [blockHeight] with [balance].
+func (vb *VersionedBalance) Set(balance uint64, blockHeight uint64) {
+	next := vb.items.Next()
+	next.Value = &VersionedItem{balance, blockHeight}
+
+	vb.items = next
+	vb.lastBalance = balance
+	vb.lastBlockHeight = blockHeight
+}
 
+// versions returns the versions held, oldest first.
+func (vb *VersionedBalance) versions() []VersionedItem {
+	var versions []VersionedItem
 	cur := vb.items
-	for i := 0; i < vb.items.Len() && cur.Value != nil && cur.Value.(*VersionedItem).blkHgt > blockHeight; i++ {
+	for i := 0; i < vb.items.Len() && cur.Value != nil; i++ {
+		versions = append(versions, *cur.Value.(*VersionedItem))
 		cur = cur.Prev()
 	}
-
-	if cur.Value != nil {
-		balance = cur.Value.(*VersionedItem).bal
-		cur.Value = nil
-	}
-
-	cur = vb.items
-	for cur.Value != nil {
-		item := cur.Value.(*VersionedItem)
-		item.bal -= balance
-		cur = cur.Prev()
+	for i, j := 0, len(versions)-1; i < j; i, j = i+1, j-1 {
+		versions[i], versions[j] = versions[j], versions[i]
 	}
-
-	vb.lastBalance -= balance
-	return balance
+	return versions
 }
-
-func (vb *VersionedBalance) Put(amount uint64, blockHeight uint64) {
-	newBalance := vb.lastBalance + amount
-	next := vb.items.Next()
-	next.Value = &VersionedItem{newBalance, blockHeight}
-
-	vb.items = next
-	vb.lastBalance = newBalance
-	vb.lastBlockHeight = blockHeight
-}
\ No newline at end of file
@@ -54,9 +54,13 @@ func (lq *LinkedMapQueue[V, S]) Push(val V, id ids.ID) error {
 	item := &Item[V]{
 		value: val,
 	}
-	prevTail := lq.tail
-	prevTail.nextItem = item
-	item.prevItem = prevTail
+	if lq.head == nil {
+		lq.head = item
+	} else {
+		prevTail := lq.tail
+		prevTail.nextItem = item
+		item.prevItem = prevTail
+	}
 	lq.tail = item
 	lq.hashMap[id] = item
 	lq.length++
@@ -74,6 +78,9 @@ func (lq *LinkedMapQueue[V, S]) Pop() V {
 	}
 	prevHead := lq.head
 	lq.head = nextHead
+	if nextHead == nil {
+		lq.tail = nil
+	}
 	res := prevHead.value
 	delete(lq.hashMap, res.GetID())
 	lq.length--
@@ -102,9 +109,11 @@ func (lq *LinkedMapQueue[V, S]) Remove(id ids.ID) error {
 
 	if item == lq.head {
 		lq.head = nextItem
-	} else if item == lq.tail {
+	}
+	if item == lq.tail {
 		lq.tail = prevItem
 	}
+	delete(lq.hashMap, id)
 	lq.length--
 	return nil
 }
@@ -20,5 +20,13 @@ func init() {
 	_ = ActionRegistry.Register(&actions.Transfer{}, actions.UnmarshalTransfer, false)
 	_ = ActionRegistry.Register(&actions.AddOrder{}, actions.UnmarshalAddOrder, false)
 	_ = ActionRegistry.Register(&actions.CancelOrder{}, actions.UnmarshalCancelOrder, false)
+	_ = ActionRegistry.Register(&actions.ReplaceOrder{}, actions.UnmarshalReplaceOrder, false)
+	_ = ActionRegistry.Register(&actions.BatchOrders{}, actions.UnmarshalBatchOrders, false)
+	_ = ActionRegistry.Register(&actions.CreatePair{}, actions.UnmarshalCreatePair, false)
+	_ = ActionRegistry.Register(&actions.CreateToken{}, actions.UnmarshalCreateToken, false)
+	_ = ActionRegistry.Register(&actions.MintToken{}, actions.UnmarshalMintToken, false)
+	_ = ActionRegistry.Register(&actions.BurnToken{}, actions.UnmarshalBurnToken, false)
+	_ = ActionRegistry.Register(&actions.ClaimFunds{}, actions.UnmarshalClaimFunds, false)
+	_ = ActionRegistry.Register(&actions.SettleFunds{}, actions.UnmarshalSettleFunds, false)
 	_ = AuthRegistry.Register(&auth.ED25519{}, auth.UnmarshalEIP712, false)
 }
\ No newline at end of file
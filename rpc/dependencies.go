@@ -7,6 +7,7 @@ import (
 	"github.com/ava-labs/avalanchego/trace"
 	"github.com/jaimi-io/clobvm/genesis"
 	"github.com/jaimi-io/clobvm/orderbook"
+	"github.com/jaimi-io/clobvm/storage"
 	"github.com/jaimi-io/hypersdk/crypto"
 )
 
@@ -14,8 +15,18 @@ type Controller interface {
 	Genesis() (*genesis.Genesis)
 	GetBalance(ctx context.Context, address crypto.PublicKey, tokenID ids.ID) (uint64, error)
 	GetMidPrice(ctx context.Context, pair orderbook.Pair) (uint64, error)
-	GetOrderbook(ctx context.Context, pair orderbook.Pair, numPriceLevels int) (string, string, error)
+	GetDepth(ctx context.Context, pair orderbook.Pair, numPriceLevels int, includeOrders bool) ([]orderbook.PriceLevel, []orderbook.PriceLevel, error)
 	GetPendingFunds(ctx context.Context, user crypto.PublicKey, tokenID ids.ID, blockHeight uint64) (uint64, uint64)
-	GetVolumes(ctx context.Context, pair orderbook.Pair, numPriceLevels int) (string, error)
+	GetAllPendingFunds(ctx context.Context, user crypto.PublicKey) ([]*orderbook.PendingFunds, uint64)
+	GetUnsettledFunds(ctx context.Context, user crypto.PublicKey, tokenID ids.ID) (uint64, uint64)
+	GetMaturedFunds(ctx context.Context) ([]*orderbook.MaturedFund, uint64)
+	GetOrderbookRoot(ctx context.Context, blockHeight uint64) (ids.ID, uint64, error)
+	GetOpenOrders(ctx context.Context, user crypto.PublicKey, pair orderbook.Pair) ([]*orderbook.Order, error)
+	GetOrderStatus(ctx context.Context, orderID ids.ID) (*orderbook.Order, error)
+	GetCandles(ctx context.Context, pair orderbook.Pair, interval int64, start int64, limit int) ([]*orderbook.Candle, error)
+	GetTrades(ctx context.Context, pair orderbook.Pair, user crypto.PublicKey, blockHeight uint64, index uint32, limit int) ([]*orderbook.Trade, error)
+	GetFees(ctx context.Context, pair orderbook.Pair) (*orderbook.PairFees, error)
+	GetPair(ctx context.Context, pair orderbook.Pair) (*orderbook.PairInfo, error)
+	GetToken(ctx context.Context, tokenID ids.ID) (*storage.TokenInfo, error)
 	Tracer() trace.Tracer
 }
\ No newline at end of file
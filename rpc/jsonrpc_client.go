@@ -47,14 +47,16 @@ func (cli *JSONRPCClient) Genesis(ctx context.Context) (*genesis.Genesis, error)
 	return resp.Genesis, nil
 }
 
-func (j *JSONRPCClient) Balance(ctx context.Context, address string, tokenID ids.ID) (float64, error) {
+// Balance returns the settled balance of [address], what it has pending and
+// how much of that can be settled by the next block.
+func (j *JSONRPCClient) Balance(ctx context.Context, address string, tokenID ids.ID) (float64, float64, float64, error) {
 	args := &BalanceArgs{
 		Address: address,
 		TokenID: tokenID,
 	}
 	var reply BalanceReply
 	err := j.requester.SendRequest(ctx, "balance", args, &reply)
-	return reply.Balance, err
+	return reply.Balance, reply.Pending, reply.Claimable, err
 }
 
 func (j *JSONRPCClient) MidPrice(ctx context.Context, pair orderbook.Pair) (float64, error) {
@@ -66,7 +68,7 @@ func (j *JSONRPCClient) MidPrice(ctx context.Context, pair orderbook.Pair) (floa
 	return reply.MidPrice, err
 }
 
-func (j *JSONRPCClient) AllOrders(ctx context.Context, pair orderbook.Pair, numPriceLevels int) (string, string, error) {
+func (j *JSONRPCClient) AllOrders(ctx context.Context, pair orderbook.Pair, numPriceLevels int) ([]PriceLevel, []PriceLevel, error) {
 	args := &AllOrdersArgs{
 		Pair: pair,
 		NumPriceLevels: numPriceLevels,
@@ -87,14 +89,110 @@ func (j *JSONRPCClient) PendingFunds(ctx context.Context, user crypto.PublicKey,
 	return reply.Balance, reply.BlockHeight, err
 }
 
-func (j *JSONRPCClient) Volumes(ctx context.Context, pair orderbook.Pair, numPriceLevels int) (string, error) {
+func (j *JSONRPCClient) AllPendingFunds(ctx context.Context, user crypto.PublicKey) ([]PendingFund, uint64, error) {
+	args := &AllPendingFundsArgs{
+		User: user,
+	}
+	var reply AllPendingFundsReply
+	err := j.requester.SendRequest(ctx, "allPendingFunds", args, &reply)
+	return reply.Funds, reply.BlockHeight, err
+}
+
+func (j *JSONRPCClient) MaturedFunds(ctx context.Context) ([]MaturedFund, uint64, error) {
+	var reply MaturedFundsReply
+	err := j.requester.SendRequest(ctx, "maturedFunds", nil, &reply)
+	return reply.Funds, reply.BlockHeight, err
+}
+
+func (j *JSONRPCClient) Volumes(ctx context.Context, pair orderbook.Pair, numPriceLevels int) ([]PriceLevel, []PriceLevel, error) {
 	args := &VolumesArgs{
 		Pair: pair,
 		NumPriceLevels: numPriceLevels,
 	}
 	var reply VolumesReply
 	err := j.requester.SendRequest(ctx, "volumes", args, &reply)
-	return reply.Volumes, err
+	return reply.BuySide, reply.SellSide, err
+}
+
+func (j *JSONRPCClient) OrderbookRoot(ctx context.Context, blockHeight uint64) (ids.ID, uint64, error) {
+	args := &OrderbookRootArgs{
+		BlockHeight: blockHeight,
+	}
+	var reply OrderbookRootReply
+	err := j.requester.SendRequest(ctx, "orderbookRoot", args, &reply)
+	return reply.Root, reply.BlockHeight, err
+}
+
+func (j *JSONRPCClient) OpenOrders(ctx context.Context, address string, pair orderbook.Pair) ([]OrderStatus, error) {
+	args := &OpenOrdersArgs{
+		Address: address,
+		Pair:    pair,
+	}
+	var reply OpenOrdersReply
+	err := j.requester.SendRequest(ctx, "openOrders", args, &reply)
+	return reply.Orders, err
+}
+
+func (j *JSONRPCClient) OrderStatus(ctx context.Context, orderID ids.ID) (OrderStatus, error) {
+	args := &OrderStatusArgs{
+		OrderID: orderID,
+	}
+	var reply OrderStatusReply
+	err := j.requester.SendRequest(ctx, "orderStatus", args, &reply)
+	return reply.Order, err
+}
+
+// Trades returns a page of the trades of [pair], of only [address] if it is
+// not empty, starting at [start]. The returned cursor is nil on the last page.
+func (j *JSONRPCClient) Trades(ctx context.Context, pair orderbook.Pair, address string, start TradeCursor, limit int) ([]Trade, *TradeCursor, error) {
+	args := &TradesArgs{
+		Pair:    pair,
+		Address: address,
+		Start:   start,
+		Limit:   limit,
+	}
+	var reply TradesReply
+	err := j.requester.SendRequest(ctx, "trades", args, &reply)
+	return reply.Trades, reply.Next, err
+}
+
+func (j *JSONRPCClient) Candles(ctx context.Context, pair orderbook.Pair, interval int64, start int64, limit int) ([]Candle, error) {
+	args := &CandlesArgs{
+		Pair:     pair,
+		Interval: interval,
+		Start:    start,
+		Limit:    limit,
+	}
+	var reply CandlesReply
+	err := j.requester.SendRequest(ctx, "candles", args, &reply)
+	return reply.Candles, err
+}
+
+func (j *JSONRPCClient) Fees(ctx context.Context, pair orderbook.Pair) (*FeesReply, error) {
+	args := &FeesArgs{
+		Pair: pair,
+	}
+	var reply FeesReply
+	err := j.requester.SendRequest(ctx, "fees", args, &reply)
+	return &reply, err
+}
+
+func (j *JSONRPCClient) Pair(ctx context.Context, pair orderbook.Pair) (*PairReply, error) {
+	args := &PairArgs{
+		Pair: pair,
+	}
+	var reply PairReply
+	err := j.requester.SendRequest(ctx, "pair", args, &reply)
+	return &reply, err
+}
+
+func (j *JSONRPCClient) Token(ctx context.Context, tokenID ids.ID) (*TokenReply, error) {
+	args := &TokenArgs{
+		TokenID: tokenID,
+	}
+	var reply TokenReply
+	err := j.requester.SendRequest(ctx, "token", args, &reply)
+	return &reply, err
 }
 
 type Parser struct {
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/ava-labs/avalanchego/ids"
+	"github.com/jaimi-io/clobvm/consts"
 	"github.com/jaimi-io/clobvm/genesis"
 	"github.com/jaimi-io/clobvm/orderbook"
 	"github.com/jaimi-io/clobvm/utils"
@@ -35,7 +36,9 @@ type BalanceArgs struct {
 }
 
 type BalanceReply struct {
-	Balance float64 `json:"balance"`
+	Balance   float64 `json:"balance"`
+	Pending   float64 `json:"pending"`
+	Claimable float64 `json:"claimable"`
 }
 
 func (j *JSONRPCServer) Balance(req *http.Request, args *BalanceArgs, reply *BalanceReply) error {
@@ -50,7 +53,9 @@ func (j *JSONRPCServer) Balance(req *http.Request, args *BalanceArgs, reply *Bal
 	if err != nil {
 		return err
 	}
+	pending, claimable := j.c.GetUnsettledFunds(ctx, address, args.TokenID)
 	reply.Balance = utils.DisplayBalance(bal)
+	reply.Pending, reply.Claimable = utils.DisplayBalance(pending), utils.DisplayBalance(claimable)
 	return nil
 }
 
@@ -74,21 +79,60 @@ func (j *JSONRPCServer) MidPrice(req *http.Request, args *MidPriceArgs, reply *M
 	return nil
 }
 
+type OrderInfo struct {
+	ID       ids.ID  `json:"id"`
+	User     string  `json:"user"`
+	Quantity float64 `json:"quantity"`
+}
+
+// PriceLevel is the visible resting quantity at one price, best price first
+// within each side.
+type PriceLevel struct {
+	Price     float64     `json:"price"`
+	Quantity  float64     `json:"quantity"`
+	NumOrders int         `json:"numOrders"`
+	Orders    []OrderInfo `json:"orders,omitempty"`
+}
+
+func newPriceLevels(levels []orderbook.PriceLevel) []PriceLevel {
+	res := make([]PriceLevel, 0, len(levels))
+	for _, level := range levels {
+		var orders []OrderInfo
+		for _, order := range level.Orders {
+			orders = append(orders, OrderInfo{
+				ID:       order.ID,
+				User:     crypto.Address("clob", order.User),
+				Quantity: utils.DisplayQuantity(order.Quantity),
+			})
+		}
+		res = append(res, PriceLevel{
+			Price:     utils.DisplayPrice(level.Price),
+			Quantity:  utils.DisplayQuantity(level.Quantity),
+			NumOrders: level.NumOrders,
+			Orders:    orders,
+		})
+	}
+	return res
+}
+
 type AllOrdersArgs struct {
 	Pair      orderbook.Pair `json:"pair"`
 	NumPriceLevels int `json:"numPriceLevels"`
 }
 type AllOrdersReply struct {
-	BuySide  string `json:"buySide"`
-	SellSide string `json:"sellSide"`
+	BuySide  []PriceLevel `json:"buySide"`
+	SellSide []PriceLevel `json:"sellSide"`
 }
 func (j *JSONRPCServer) AllOrders(req *http.Request, args *AllOrdersArgs, reply *AllOrdersReply) error {
 	ctx, span := j.c.Tracer().Start(req.Context(), "Server.AllOrders")
 	defer span.End()
 
-	var err error
-	reply.BuySide, reply.SellSide, err = j.c.GetOrderbook(ctx, args.Pair, args.NumPriceLevels)
-	return err
+	bids, asks, err := j.c.GetDepth(ctx, args.Pair, args.NumPriceLevels, true)
+	if err != nil {
+		return err
+	}
+	reply.BuySide, reply.SellSide = newPriceLevels(bids), newPriceLevels(asks)
+	return nil
 }
 
 type PendingFundsArgs struct {
@@ -109,18 +153,365 @@ func (j *JSONRPCServer) PendingFunds(req *http.Request, args *PendingFundsArgs,
 	return nil
 }
 
+type AllPendingFundsArgs struct {
+	User crypto.PublicKey `json:"user"`
+}
+type PendingFund struct {
+	TokenID         ids.ID  `json:"tokenID"`
+	Balance         float64 `json:"balance"`
+	ClaimableHeight uint64  `json:"claimableHeight"`
+}
+type AllPendingFundsReply struct {
+	Funds       []PendingFund `json:"funds"`
+	BlockHeight uint64        `json:"blockHeight"`
+}
+func (j *JSONRPCServer) AllPendingFunds(req *http.Request, args *AllPendingFundsArgs, reply *AllPendingFundsReply) error {
+	ctx, span := j.c.Tracer().Start(req.Context(), "Server.AllPendingFunds")
+	defer span.End()
+
+	funds, blkHgt := j.c.GetAllPendingFunds(ctx, args.User)
+	reply.Funds = make([]PendingFund, 0, len(funds))
+	for _, f := range funds {
+		reply.Funds = append(reply.Funds, PendingFund{f.TokenID, utils.DisplayBalance(f.Amount), f.ClaimableHeight})
+	}
+	reply.BlockHeight = blkHgt
+	return nil
+}
+
+type MaturedFund struct {
+	User    string  `json:"user"`
+	TokenID ids.ID  `json:"tokenID"`
+	Balance float64 `json:"balance"`
+}
+type MaturedFundsReply struct {
+	Funds       []MaturedFund `json:"funds"`
+	BlockHeight uint64        `json:"blockHeight"`
+}
+func (j *JSONRPCServer) MaturedFunds(req *http.Request, _ *struct{}, reply *MaturedFundsReply) error {
+	ctx, span := j.c.Tracer().Start(req.Context(), "Server.MaturedFunds")
+	defer span.End()
+
+	funds, blkHgt := j.c.GetMaturedFunds(ctx)
+	reply.Funds = make([]MaturedFund, 0, len(funds))
+	for _, f := range funds {
+		reply.Funds = append(reply.Funds, MaturedFund{crypto.Address("clob", f.User), f.TokenID, utils.DisplayBalance(f.Amount)})
+	}
+	reply.BlockHeight = blkHgt
+	return nil
+}
+
 type VolumesArgs struct {
 	Pair orderbook.Pair `json:"pair"`
 	NumPriceLevels int `json:"numPriceLevels"`
 }
 type VolumesReply struct {
-	Volumes  string `json:"volumes"`
+	BuySide  []PriceLevel `json:"buySide"`
+	SellSide []PriceLevel `json:"sellSide"`
 }
 func (j *JSONRPCServer) Volumes(req *http.Request, args *VolumesArgs, reply *VolumesReply) error {
 	ctx, span := j.c.Tracer().Start(req.Context(), "Server.Volumes")
 	defer span.End()
 
+	bids, asks, err := j.c.GetDepth(ctx, args.Pair, args.NumPriceLevels, false)
+	if err != nil {
+		return err
+	}
+	reply.BuySide, reply.SellSide = newPriceLevels(bids), newPriceLevels(asks)
+	return nil
+}
+
+type OrderbookRootArgs struct {
+	BlockHeight uint64 `json:"blockHeight"`
+}
+type OrderbookRootReply struct {
+	Root        ids.ID `json:"root"`
+	BlockHeight uint64 `json:"blockHeight"`
+}
+func (j *JSONRPCServer) OrderbookRoot(req *http.Request, args *OrderbookRootArgs, reply *OrderbookRootReply) error {
+	ctx, span := j.c.Tracer().Start(req.Context(), "Server.OrderbookRoot")
+	defer span.End()
+
 	var err error
-	reply.Volumes, err = j.c.GetVolumes(ctx, args.Pair, args.NumPriceLevels)
+	reply.Root, reply.BlockHeight, err = j.c.GetOrderbookRoot(ctx, args.BlockHeight)
 	return err
-}
\ No newline at end of file
+}
+
+type OrderStatus struct {
+	ID               ids.ID  `json:"id"`
+	Price            float64 `json:"price"`
+	TriggerPrice     float64 `json:"triggerPrice"`
+	WorstPrice       float64 `json:"worstPrice"`
+	Quantity         float64 `json:"quantity"`
+	OriginalQuantity float64 `json:"originalQuantity"`
+	Side             bool    `json:"side"`
+	FeeRate          uint64  `json:"feeRate"` // basis points
+	BlockExpiry      uint64  `json:"blockExpiry"`
+	TimeExpiry       int64   `json:"timeExpiry"`
+	Status           string  `json:"status"`
+}
+
+func newOrderStatus(order *orderbook.Order) OrderStatus {
+	return OrderStatus{
+		ID:               order.ID,
+		Price:            utils.DisplayPrice(order.Price),
+		TriggerPrice:     utils.DisplayPrice(order.TriggerPrice),
+		WorstPrice:       utils.DisplayPrice(order.WorstPrice),
+		Quantity:         utils.DisplayQuantity(order.Quantity + order.Hidden),
+		OriginalQuantity: utils.DisplayQuantity(order.OriginalQuantity),
+		Side:             order.Side,
+		FeeRate:          order.Fee,
+		BlockExpiry:      order.BlockExpiry,
+		TimeExpiry:       order.TimeExpiry,
+		Status:           order.Status.String(),
+	}
+}
+
+type OpenOrdersArgs struct {
+	Address string         `json:"address"`
+	Pair    orderbook.Pair `json:"pair"`
+}
+type OpenOrdersReply struct {
+	Orders []OrderStatus `json:"orders"`
+}
+func (j *JSONRPCServer) OpenOrders(req *http.Request, args *OpenOrdersArgs, reply *OpenOrdersReply) error {
+	ctx, span := j.c.Tracer().Start(req.Context(), "Server.OpenOrders")
+	defer span.End()
+
+	user, err := crypto.ParseAddress("clob", args.Address)
+	if err != nil {
+		return err
+	}
+	orders, err := j.c.GetOpenOrders(ctx, user, args.Pair)
+	if err != nil {
+		return err
+	}
+	reply.Orders = make([]OrderStatus, 0, len(orders))
+	for _, order := range orders {
+		reply.Orders = append(reply.Orders, newOrderStatus(order))
+	}
+	return nil
+}
+
+type OrderStatusArgs struct {
+	OrderID ids.ID `json:"orderID"`
+}
+type OrderStatusReply struct {
+	Order OrderStatus `json:"order"`
+}
+func (j *JSONRPCServer) OrderStatus(req *http.Request, args *OrderStatusArgs, reply *OrderStatusReply) error {
+	ctx, span := j.c.Tracer().Start(req.Context(), "Server.OrderStatus")
+	defer span.End()
+
+	order, err := j.c.GetOrderStatus(ctx, args.OrderID)
+	if err != nil {
+		return err
+	}
+	reply.Order = newOrderStatus(order)
+	return nil
+}
+
+type Trade struct {
+	BlockHeight  uint64  `json:"blockHeight"`
+	Index        uint32  `json:"index"`
+	Timestamp    int64   `json:"timestamp"`
+	MakerOrderID ids.ID  `json:"makerOrderID"`
+	Maker        string  `json:"maker"`
+	TakerOrderID ids.ID  `json:"takerOrderID"`
+	Taker        string  `json:"taker"`
+	TakerSide    bool    `json:"takerSide"`
+	Price        float64 `json:"price"`
+	Quantity     float64 `json:"quantity"`
+	MakerFee     float64 `json:"makerFee"`
+	TakerFee     float64 `json:"takerFee"`
+}
+
+// TradeCursor is the position of the first trade of the next page.
+type TradeCursor struct {
+	BlockHeight uint64 `json:"blockHeight"`
+	Index       uint32 `json:"index"`
+}
+
+type TradesArgs struct {
+	Pair    orderbook.Pair `json:"pair"`
+	Address string         `json:"address"` // optional, only trades of this user
+	Start   TradeCursor    `json:"start"`
+	Limit   int            `json:"limit"`
+}
+type TradesReply struct {
+	Trades []Trade      `json:"trades"`
+	Next   *TradeCursor `json:"next"`
+}
+func (j *JSONRPCServer) Trades(req *http.Request, args *TradesArgs, reply *TradesReply) error {
+	ctx, span := j.c.Tracer().Start(req.Context(), "Server.Trades")
+	defer span.End()
+
+	user := crypto.EmptyPublicKey
+	if args.Address != "" {
+		var err error
+		user, err = crypto.ParseAddress("clob", args.Address)
+		if err != nil {
+			return err
+		}
+	}
+	limit := args.Limit
+	if limit <= 0 || limit > consts.MaxTradesPageSize {
+		limit = consts.MaxTradesPageSize
+	}
+	// Fetch one more trade than asked for to find where the next page starts.
+	trades, err := j.c.GetTrades(ctx, args.Pair, user, args.Start.BlockHeight, args.Start.Index, limit+1)
+	if err != nil {
+		return err
+	}
+	if len(trades) > limit {
+		reply.Next = &TradeCursor{trades[limit].BlockHeight, trades[limit].Index}
+		trades = trades[:limit]
+	}
+	reply.Trades = make([]Trade, 0, len(trades))
+	for _, trade := range trades {
+		reply.Trades = append(reply.Trades, Trade{
+			BlockHeight:  trade.BlockHeight,
+			Index:        trade.Index,
+			Timestamp:    trade.Timestamp,
+			MakerOrderID: trade.MakerOrderID,
+			Maker:        crypto.Address("clob", trade.Maker),
+			TakerOrderID: trade.TakerOrderID,
+			Taker:        crypto.Address("clob", trade.Taker),
+			TakerSide:    trade.TakerSide,
+			Price:        utils.DisplayPrice(trade.Price),
+			Quantity:     utils.DisplayQuantity(trade.Quantity),
+			MakerFee:     utils.DisplayBalance(trade.MakerFee),
+			TakerFee:     utils.DisplayBalance(trade.TakerFee),
+		})
+	}
+	return nil
+}
+
+type Candle struct {
+	Start     int64   `json:"start"`
+	Open      float64 `json:"open"`
+	High      float64 `json:"high"`
+	Low       float64 `json:"low"`
+	Close     float64 `json:"close"`
+	Volume    float64 `json:"volume"`
+	NumTrades uint64  `json:"numTrades"`
+}
+
+type CandlesArgs struct {
+	Pair     orderbook.Pair `json:"pair"`
+	Interval int64          `json:"interval"` // seconds: 60, 300, 3600 or 86400
+	Start    int64          `json:"start"`    // unix seconds
+	Limit    int            `json:"limit"`
+}
+type CandlesReply struct {
+	Candles []Candle `json:"candles"`
+}
+func (j *JSONRPCServer) Candles(req *http.Request, args *CandlesArgs, reply *CandlesReply) error {
+	ctx, span := j.c.Tracer().Start(req.Context(), "Server.Candles")
+	defer span.End()
+
+	limit := args.Limit
+	if limit <= 0 || limit > consts.MaxCandlesPageSize {
+		limit = consts.MaxCandlesPageSize
+	}
+	start := args.Start
+	if start < 0 {
+		start = 0
+	}
+	candles, err := j.c.GetCandles(ctx, args.Pair, args.Interval, start, limit)
+	if err != nil {
+		return err
+	}
+	reply.Candles = make([]Candle, 0, len(candles))
+	for _, candle := range candles {
+		reply.Candles = append(reply.Candles, Candle{
+			Start:     candle.Start,
+			Open:      utils.DisplayPrice(candle.Open),
+			High:      utils.DisplayPrice(candle.High),
+			Low:       utils.DisplayPrice(candle.Low),
+			Close:     utils.DisplayPrice(candle.Close),
+			Volume:    utils.DisplayQuantity(candle.Volume),
+			NumTrades: candle.NumTrades,
+		})
+	}
+	return nil
+}
+
+type FeesArgs struct {
+	Pair orderbook.Pair `json:"pair"`
+}
+type FeesReply struct {
+	Recipient string  `json:"recipient"` // empty if fees are burned
+	Base      float64 `json:"base"`
+	Quote     float64 `json:"quote"`
+}
+func (j *JSONRPCServer) Fees(req *http.Request, args *FeesArgs, reply *FeesReply) error {
+	ctx, span := j.c.Tracer().Start(req.Context(), "Server.Fees")
+	defer span.End()
+
+	fees, err := j.c.GetFees(ctx, args.Pair)
+	if err != nil {
+		return err
+	}
+	if recipient := j.c.Genesis().GetRules().GetFeeRecipient(); recipient != crypto.EmptyPublicKey {
+		reply.Recipient = crypto.Address("clob", recipient)
+	}
+	reply.Base = utils.DisplayBalance(fees.Base)
+	reply.Quote = utils.DisplayBalance(fees.Quote)
+	return nil
+}
+
+type PairArgs struct {
+	Pair orderbook.Pair `json:"pair"`
+}
+type PairReply struct {
+	Listed      bool    `json:"listed"`
+	Creator     string  `json:"creator"`
+	TickSize    float64 `json:"tickSize"`
+	LotSize     float64 `json:"lotSize"`
+	MinNotional float64 `json:"minNotional"`
+	Status      string  `json:"status"`
+}
+func (j *JSONRPCServer) Pair(req *http.Request, args *PairArgs, reply *PairReply) error {
+	ctx, span := j.c.Tracer().Start(req.Context(), "Server.Pair")
+	defer span.End()
+
+	info, err := j.c.GetPair(ctx, args.Pair)
+	if err != nil || info == nil {
+		return err
+	}
+	reply.Listed = true
+	reply.Creator = crypto.Address("clob", info.Creator)
+	reply.TickSize = utils.DisplayPrice(info.TickSize)
+	reply.LotSize = utils.DisplayBalance(info.LotSize)
+	reply.MinNotional = utils.DisplayBalance(info.MinNotional)
+	reply.Status = info.Status.String()
+	return nil
+}
+
+type TokenArgs struct {
+	TokenID ids.ID `json:"tokenID"`
+}
+type TokenReply struct {
+	Exists    bool    `json:"exists"`
+	Symbol    string  `json:"symbol"`
+	Decimals  uint8   `json:"decimals"`
+	MaxSupply float64 `json:"maxSupply"`
+	Supply    float64 `json:"supply"`
+	Owner     string  `json:"owner"`
+}
+func (j *JSONRPCServer) Token(req *http.Request, args *TokenArgs, reply *TokenReply) error {
+	ctx, span := j.c.Tracer().Start(req.Context(), "Server.Token")
+	defer span.End()
+
+	info, err := j.c.GetToken(ctx, args.TokenID)
+	if err != nil || info == nil {
+		return err
+	}
+	reply.Exists = true
+	reply.Symbol = info.Symbol
+	reply.Decimals = info.Decimals
+	reply.MaxSupply = utils.DisplayBalance(info.MaxSupply)
+	reply.Supply = utils.DisplayBalance(info.Supply)
+	reply.Owner = crypto.Address("clob", info.Owner)
+	return nil
+}
@@ -0,0 +1,171 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/ava-labs/avalanchego/utils/logging"
+	"github.com/gorilla/websocket"
+	"github.com/jaimi-io/clobvm/consts"
+	"github.com/jaimi-io/clobvm/orderbook"
+	"github.com/jaimi-io/hypersdk/pubsub"
+	"github.com/jaimi-io/hypersdk/utils"
+)
+
+// marketSequence is where a pair's stream is at.
+type marketSequence struct {
+	epoch    uint64
+	sequence uint64
+}
+
+type MarketDataClient struct {
+	cl   sync.Once
+	conn *websocket.Conn
+
+	mb           *pubsub.MessageBuffer
+	writeStopped chan struct{}
+	readStopped  chan struct{}
+
+	pending chan []byte
+
+	// sequences is the epoch and last sequence seen per subscribed pair.
+	// Pairs waiting on a snapshot after a gap are not present.
+	sequences map[orderbook.Pair]marketSequence
+
+	err error
+}
+
+// NewMarketDataClient dials the market data server of the node at [uri].
+func NewMarketDataClient(uri string, pending int, maxSize int) (*MarketDataClient, error) {
+	uri = strings.ReplaceAll(uri, "http://", "ws://")
+	uri = strings.ReplaceAll(uri, "https://", "wss://")
+	if !strings.HasPrefix(uri, "ws") {
+		uri = "ws://" + uri
+	}
+	uri = strings.TrimSuffix(uri, "/")
+	uri += consts.MarketDataEndpoint
+	conn, resp, err := websocket.DefaultDialer.Dial(uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	mc := &MarketDataClient{
+		conn:         conn,
+		mb:           pubsub.NewMessageBuffer(&logging.NoLog{}, pending, maxSize, pubsub.MaxMessageWait),
+		readStopped:  make(chan struct{}),
+		writeStopped: make(chan struct{}),
+		pending:      make(chan []byte, pending),
+		sequences:    make(map[orderbook.Pair]marketSequence),
+	}
+	go func() {
+		defer close(mc.readStopped)
+		for {
+			_, msgBatch, err := conn.ReadMessage()
+			if err != nil {
+				mc.err = err
+				return
+			}
+			if len(msgBatch) == 0 {
+				utils.Outf("{{orange}}got empty message{{/}}\n")
+				continue
+			}
+			msgs, err := pubsub.ParseBatchMessage(pubsub.MaxWriteMessageSize, msgBatch)
+			if err != nil {
+				utils.Outf("{{orange}}received invalid message:{{/}} %v\n", err)
+				continue
+			}
+			for _, msg := range msgs {
+				mc.pending <- msg
+			}
+		}
+	}()
+	go func() {
+		defer close(mc.writeStopped)
+		for {
+			select {
+			case msg, ok := <-mc.mb.Queue:
+				if !ok {
+					return
+				}
+				if err := mc.conn.WriteMessage(websocket.BinaryMessage, msg); err != nil {
+					utils.Outf("{{orange}}unable to write message:{{/}} %v\n", err)
+				}
+			case <-mc.readStopped:
+				_ = mc.mb.Close()
+				return
+			}
+		}
+	}()
+	return mc, nil
+}
+
+// Subscribe asks for a snapshot of [pair] followed by its updates.
+func (c *MarketDataClient) Subscribe(pair orderbook.Pair) error {
+	return c.mb.Send(append([]byte{SubscribeMode}, PackSubscribeMessage(pair)...))
+}
+
+func (c *MarketDataClient) Unsubscribe(pair orderbook.Pair) error {
+	return c.mb.Send(append([]byte{UnsubscribeMode}, PackSubscribeMessage(pair)...))
+}
+
+// Listen returns the next snapshot or update, exactly one of which is non-nil.
+// If an update of a pair skips a sequence number or comes from another epoch
+// the pair is resubscribed and its updates are dropped until the new snapshot
+// arrives, so every returned update applies on top of what was returned
+// before it.
+func (c *MarketDataClient) Listen(ctx context.Context) (*MarketSnapshot, *MarketUpdate, error) {
+	for {
+		var msg []byte
+		select {
+		case msg = <-c.pending:
+		case <-c.readStopped:
+			return nil, nil, c.err
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+		if len(msg) == 0 {
+			continue
+		}
+		switch msg[0] {
+		case SnapshotMode:
+			snapshot, err := UnpackSnapshotMessage(msg[1:])
+			if err != nil {
+				return nil, nil, err
+			}
+			c.sequences[snapshot.Pair] = marketSequence{snapshot.Epoch, snapshot.Sequence}
+			return snapshot, nil, nil
+		case UpdateMode:
+			update, err := UnpackUpdateMessage(msg[1:])
+			if err != nil {
+				return nil, nil, err
+			}
+			last, ok := c.sequences[update.Pair]
+			if !ok || update.Epoch == last.epoch && update.Sequence <= last.sequence {
+				continue
+			}
+			if update.Epoch != last.epoch || update.Sequence != last.sequence+1 {
+				delete(c.sequences, update.Pair)
+				if err := c.Subscribe(update.Pair); err != nil {
+					return nil, nil, err
+				}
+				continue
+			}
+			c.sequences[update.Pair] = marketSequence{update.Epoch, update.Sequence}
+			return nil, update, nil
+		default:
+			utils.Outf("{{orange}}unexpected message mode:{{/}} %x\n", msg[0])
+		}
+	}
+}
+
+// Close closes [c]'s connection to the market data server.
+func (c *MarketDataClient) Close() error {
+	var err error
+	c.cl.Do(func() {
+		_ = c.mb.Close()
+		<-c.writeStopped
+		err = c.conn.Close()
+	})
+	return err
+}
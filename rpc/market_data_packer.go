@@ -0,0 +1,160 @@
+package rpc
+
+import (
+	"github.com/jaimi-io/clobvm/orderbook"
+	"github.com/jaimi-io/hypersdk/chain"
+	"github.com/jaimi-io/hypersdk/codec"
+	"github.com/jaimi-io/hypersdk/consts"
+)
+
+const (
+	// Sent by clients
+	SubscribeMode   byte = 0
+	UnsubscribeMode byte = 1
+
+	// Sent by the server
+	SnapshotMode byte = 2
+	UpdateMode   byte = 3
+)
+
+// MarketSnapshot is the full visible book of a pair as of [Sequence]. Updates
+// of the same [Epoch] with a higher sequence apply on top of it.
+type MarketSnapshot struct {
+	Pair        orderbook.Pair
+	Epoch       uint64
+	Sequence    uint64
+	BlockHeight uint64
+	Levels      []orderbook.Level
+}
+
+// MarketUpdate is what changed in the book of a pair in one accepted block.
+// Levels holds the new visible quantity of every changed level, trades are in
+// the order they were made and Orders are the orders that left the book.
+// Orders that stay open are reported through the trades that fill them.
+type MarketUpdate struct {
+	Pair        orderbook.Pair
+	Epoch       uint64
+	Sequence    uint64
+	BlockHeight uint64
+	Timestamp   int64
+	Levels      []orderbook.Level
+	Trades      []*orderbook.Trade
+	Orders      []*orderbook.Order
+}
+
+func packPair(p *codec.Packer, pair orderbook.Pair) {
+	p.PackID(pair.BaseTokenID)
+	p.PackID(pair.QuoteTokenID)
+}
+
+func unpackPair(p *codec.Packer) orderbook.Pair {
+	var pair orderbook.Pair
+	p.UnpackID(true, &pair.BaseTokenID)
+	p.UnpackID(true, &pair.QuoteTokenID)
+	return pair
+}
+
+func packLevels(p *codec.Packer, levels []orderbook.Level) {
+	p.PackInt(len(levels))
+	for _, level := range levels {
+		p.PackBool(level.Side)
+		p.PackUint64(level.Price)
+		p.PackUint64(level.Quantity)
+	}
+}
+
+func unpackLevels(p *codec.Packer) []orderbook.Level {
+	numLevels := p.UnpackInt(false)
+	levels := make([]orderbook.Level, 0, numLevels)
+	for i := 0; i < numLevels && p.Err() == nil; i++ {
+		levels = append(levels, orderbook.Level{
+			Side:     p.UnpackBool(),
+			Price:    p.UnpackUint64(true),
+			Quantity: p.UnpackUint64(false),
+		})
+	}
+	return levels
+}
+
+func PackSubscribeMessage(pair orderbook.Pair) []byte {
+	p := codec.NewWriter(consts.MaxInt)
+	packPair(p, pair)
+	return p.Bytes()
+}
+
+func UnpackSubscribeMessage(msg []byte) (orderbook.Pair, error) {
+	p := codec.NewReader(msg, consts.MaxInt)
+	pair := unpackPair(p)
+	if !p.Empty() {
+		return pair, chain.ErrInvalidObject
+	}
+	return pair, p.Err()
+}
+
+func PackSnapshotMessage(snapshot *MarketSnapshot) ([]byte, error) {
+	p := codec.NewWriter(consts.MaxInt)
+	packPair(p, snapshot.Pair)
+	p.PackUint64(snapshot.Epoch)
+	p.PackUint64(snapshot.Sequence)
+	p.PackUint64(snapshot.BlockHeight)
+	packLevels(p, snapshot.Levels)
+	return p.Bytes(), p.Err()
+}
+
+func UnpackSnapshotMessage(msg []byte) (*MarketSnapshot, error) {
+	p := codec.NewReader(msg, consts.MaxInt)
+	snapshot := &MarketSnapshot{
+		Pair:        unpackPair(p),
+		Epoch:       p.UnpackUint64(true),
+		Sequence:    p.UnpackUint64(false),
+		BlockHeight: p.UnpackUint64(false),
+		Levels:      unpackLevels(p),
+	}
+	if !p.Empty() {
+		return nil, chain.ErrInvalidObject
+	}
+	return snapshot, p.Err()
+}
+
+func PackUpdateMessage(update *MarketUpdate) ([]byte, error) {
+	p := codec.NewWriter(consts.MaxInt)
+	packPair(p, update.Pair)
+	p.PackUint64(update.Epoch)
+	p.PackUint64(update.Sequence)
+	p.PackUint64(update.BlockHeight)
+	p.PackInt64(update.Timestamp)
+	packLevels(p, update.Levels)
+	p.PackInt(len(update.Trades))
+	for _, trade := range update.Trades {
+		trade.Marshal(p)
+	}
+	p.PackInt(len(update.Orders))
+	for _, order := range update.Orders {
+		order.Marshal(p)
+	}
+	return p.Bytes(), p.Err()
+}
+
+func UnpackUpdateMessage(msg []byte) (*MarketUpdate, error) {
+	p := codec.NewReader(msg, consts.MaxInt)
+	update := &MarketUpdate{
+		Pair:        unpackPair(p),
+		Epoch:       p.UnpackUint64(true),
+		Sequence:    p.UnpackUint64(true),
+		BlockHeight: p.UnpackUint64(false),
+		Timestamp:   p.UnpackInt64(false),
+		Levels:      unpackLevels(p),
+	}
+	numTrades := p.UnpackInt(false)
+	for i := 0; i < numTrades && p.Err() == nil; i++ {
+		update.Trades = append(update.Trades, orderbook.UnmarshalTrade(p))
+	}
+	numOrders := p.UnpackInt(false)
+	for i := 0; i < numOrders && p.Err() == nil; i++ {
+		update.Orders = append(update.Orders, orderbook.UnmarshalOrder(p))
+	}
+	if !p.Empty() {
+		return nil, chain.ErrInvalidObject
+	}
+	return update, p.Err()
+}
@@ -0,0 +1,221 @@
+package rpc
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ava-labs/avalanchego/utils/logging"
+	"github.com/jaimi-io/clobvm/orderbook"
+	"github.com/jaimi-io/hypersdk/pubsub"
+	"go.uber.org/zap"
+)
+
+var ErrPairNotListed = errors.New("pair is not listed")
+
+// marketBook is the last published view of a pair's book.
+type marketBook struct {
+	sequence uint64
+	levels   map[orderbook.LevelKey]uint64
+}
+
+// MarketDataServer streams per pair book updates to subscribed connections
+// after each accepted block. Every update of a pair carries the next sequence
+// number, and subscribing sends a snapshot first, so a client that sees a gap
+// can resubscribe to resync. Sequences start over when the node restarts, so
+// every message also carries the epoch of the process that sent it and a
+// client that sees it change must resubscribe too.
+type MarketDataServer struct {
+	logger logging.Logger
+	s      *pubsub.Server
+	epoch  uint64
+	listed func(orderbook.Pair) bool
+
+	l           sync.Mutex
+	blockHeight uint64
+	books       map[orderbook.Pair]*marketBook
+	listeners   map[orderbook.Pair]*pubsub.Connections
+}
+
+// NewMarketDataServer starts from the visible [levels] of every pair as of
+// [blockHeight]. Only pairs for which [listed] holds can be subscribed to.
+func NewMarketDataServer(
+	logger logging.Logger,
+	levels map[orderbook.Pair]map[orderbook.LevelKey]uint64,
+	blockHeight uint64,
+	maxPendingMessages int,
+	listed func(orderbook.Pair) bool,
+) (*MarketDataServer, *pubsub.Server) {
+	m := &MarketDataServer{
+		logger:      logger,
+		epoch:       uint64(time.Now().UnixNano()),
+		listed:      listed,
+		blockHeight: blockHeight,
+		books:       make(map[orderbook.Pair]*marketBook, len(levels)),
+		listeners:   make(map[orderbook.Pair]*pubsub.Connections),
+	}
+	for pair, pairLevels := range levels {
+		m.books[pair] = &marketBook{levels: pairLevels}
+	}
+	cfg := pubsub.NewDefaultServerConfig()
+	cfg.MaxPendingMessages = maxPendingMessages
+	m.s = pubsub.New(logger, cfg, m.MessageCallback())
+	return m, m.s
+}
+
+func (m *MarketDataServer) book(pair orderbook.Pair) *marketBook {
+	book, ok := m.books[pair]
+	if !ok {
+		book = &marketBook{levels: make(map[orderbook.LevelKey]uint64)}
+		m.books[pair] = book
+	}
+	return book
+}
+
+// AcceptBlock publishes an update for every pair whose book changed in the
+// block at [blockHeight]. [levels] are the levels of each pair touched in the
+// block with their visible quantity after it, as returned by
+// TakeLevelChanges. Only those that differ from the last published view are
+// sent.
+func (m *MarketDataServer) AcceptBlock(
+	blockHeight uint64,
+	timestamp int64,
+	levels map[orderbook.Pair][]orderbook.Level,
+	trades []*orderbook.Trade,
+	closed []*orderbook.ClosedOrder,
+) error {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	updates := make(map[orderbook.Pair]*MarketUpdate)
+	update := func(pair orderbook.Pair) *MarketUpdate {
+		u, ok := updates[pair]
+		if !ok {
+			u = &MarketUpdate{Pair: pair, Epoch: m.epoch, BlockHeight: blockHeight, Timestamp: timestamp}
+			updates[pair] = u
+		}
+		return u
+	}
+	for pair, pairLevels := range levels {
+		book := m.book(pair)
+		var changed []orderbook.Level
+		for _, level := range pairLevels {
+			key := orderbook.LevelKey{Side: level.Side, Price: level.Price}
+			if book.levels[key] == level.Quantity {
+				continue
+			}
+			changed = append(changed, level)
+			if level.Quantity == 0 {
+				delete(book.levels, key)
+			} else {
+				book.levels[key] = level.Quantity
+			}
+		}
+		if len(changed) > 0 {
+			update(pair).Levels = changed
+		}
+	}
+	for _, trade := range trades {
+		u := update(trade.Pair)
+		u.Trades = append(u.Trades, trade)
+	}
+	for _, order := range closed {
+		u := update(order.Pair)
+		u.Orders = append(u.Orders, order.Order)
+	}
+	m.blockHeight = blockHeight
+
+	for pair, u := range updates {
+		book := m.book(pair)
+		book.sequence++
+		u.Sequence = book.sequence
+		listeners, ok := m.listeners[pair]
+		if !ok {
+			continue
+		}
+		bytes, err := PackUpdateMessage(u)
+		if err != nil {
+			return err
+		}
+		for _, conn := range m.s.Publish(append([]byte{UpdateMode}, bytes...), listeners) {
+			listeners.Remove(conn)
+		}
+	}
+	return nil
+}
+
+// subscribe adds [c] as a listener of [pair] and sends it a snapshot of the
+// book. Holding the lock keeps the snapshot ahead of the next update.
+func (m *MarketDataServer) subscribe(pair orderbook.Pair, c *pubsub.Connection) error {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	if _, ok := m.books[pair]; !ok && !m.listed(pair) {
+		return ErrPairNotListed
+	}
+	if _, ok := m.listeners[pair]; !ok {
+		m.listeners[pair] = pubsub.NewConnections()
+	}
+	m.listeners[pair].Add(c)
+	book := m.book(pair)
+	bytes, err := PackSnapshotMessage(&MarketSnapshot{
+		Pair:        pair,
+		Epoch:       m.epoch,
+		Sequence:    book.sequence,
+		BlockHeight: m.blockHeight,
+		Levels:      orderbook.SortedLevels(book.levels),
+	})
+	if err != nil {
+		return err
+	}
+	c.Send(append([]byte{SnapshotMode}, bytes...))
+	return nil
+}
+
+func (m *MarketDataServer) unsubscribe(pair orderbook.Pair, c *pubsub.Connection) {
+	m.l.Lock()
+	defer m.l.Unlock()
+
+	if listeners, ok := m.listeners[pair]; ok {
+		listeners.Remove(c)
+	}
+}
+
+func (m *MarketDataServer) MessageCallback() pubsub.Callback {
+	return func(msgBytes []byte, c *pubsub.Connection) {
+		if len(msgBytes) == 0 {
+			m.logger.Error("failed to unmarshal msg",
+				zap.Int("len", len(msgBytes)),
+			)
+			return
+		}
+		pair, err := UnpackSubscribeMessage(msgBytes[1:])
+		if err != nil {
+			m.logger.Error("failed to unmarshal pair",
+				zap.Int("len", len(msgBytes)),
+				zap.Error(err),
+			)
+			return
+		}
+		switch msgBytes[0] {
+		case SubscribeMode:
+			if err := m.subscribe(pair, c); err != nil {
+				m.logger.Debug("failed to subscribe market data listener",
+					zap.Stringer("base", pair.BaseTokenID),
+					zap.Stringer("quote", pair.QuoteTokenID),
+					zap.Error(err),
+				)
+				return
+			}
+			m.logger.Debug("added market data listener", zap.Stringer("base", pair.BaseTokenID), zap.Stringer("quote", pair.QuoteTokenID))
+		case UnsubscribeMode:
+			m.unsubscribe(pair, c)
+			m.logger.Debug("removed market data listener", zap.Stringer("base", pair.BaseTokenID), zap.Stringer("quote", pair.QuoteTokenID))
+		default:
+			m.logger.Error("unexpected message type",
+				zap.Int("len", len(msgBytes)),
+				zap.Uint8("mode", msgBytes[0]),
+			)
+		}
+	}
+}
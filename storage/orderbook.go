@@ -0,0 +1,395 @@
+package storage
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/jaimi-io/clobvm/orderbook"
+	"github.com/jaimi-io/hypersdk/chain"
+	"github.com/jaimi-io/hypersdk/codec"
+	"github.com/jaimi-io/hypersdk/consts"
+	"github.com/jaimi-io/hypersdk/crypto"
+)
+
+// Keys below live in the orderbook database opened by the controller, not in
+// the merkleized chain state.
+var (
+	checkpointPrefix  = byte(0x0)
+	snapshotPrefix    = byte(0x1)
+	resultsPrefix     = byte(0x2)
+	rootPrefix        = byte(0x3)
+	closedOrderPrefix = byte(0x4)
+	tradePrefix       = byte(0x5)
+	pairTradePrefix   = byte(0x6)
+	userTradePrefix   = byte(0x7)
+	candlePrefix      = byte(0x8)
+	feesPrefix        = byte(0x9)
+)
+
+var ErrInvalidCheckpoint = errors.New("invalid orderbook checkpoint")
+
+func CheckpointKey() []byte {
+	return []byte{checkpointPrefix}
+}
+
+func heightKey(prefix byte, blockHeight uint64) []byte {
+	key := make([]byte, 1+consts.Uint64Len)
+	key[0] = prefix
+	binary.BigEndian.PutUint64(key[1:], blockHeight)
+	return key
+}
+
+func SnapshotKey(blockHeight uint64) []byte {
+	return heightKey(snapshotPrefix, blockHeight)
+}
+
+func ResultsKey(blockHeight uint64) []byte {
+	return heightKey(resultsPrefix, blockHeight)
+}
+
+func RootKey(blockHeight uint64) []byte {
+	return heightKey(rootPrefix, blockHeight)
+}
+
+func ClosedOrderKey(orderID ids.ID) []byte {
+	key := make([]byte, 1+consts.IDLen)
+	key[0] = closedOrderPrefix
+	copy(key[1:], orderID[:])
+	return key
+}
+
+func tradePosition(blockHeight uint64, index uint32) []byte {
+	pos := make([]byte, consts.Uint64Len+consts.IntLen)
+	binary.BigEndian.PutUint64(pos, blockHeight)
+	binary.BigEndian.PutUint32(pos[consts.Uint64Len:], index)
+	return pos
+}
+
+func TradeKey(blockHeight uint64, index uint32) []byte {
+	return append([]byte{tradePrefix}, tradePosition(blockHeight, index)...)
+}
+
+func pairTradesPrefix(pair orderbook.Pair) []byte {
+	key := make([]byte, 1+2*consts.IDLen)
+	key[0] = pairTradePrefix
+	copy(key[1:], pair.BaseTokenID[:])
+	copy(key[1+consts.IDLen:], pair.QuoteTokenID[:])
+	return key
+}
+
+func userTradesPrefix(user crypto.PublicKey) []byte {
+	key := make([]byte, 1+crypto.PublicKeyLen)
+	key[0] = userTradePrefix
+	copy(key[1:], user[:])
+	return key
+}
+
+func candlesPrefix(pair orderbook.Pair, interval int64) []byte {
+	key := make([]byte, 1+2*consts.IDLen+consts.Uint64Len)
+	key[0] = candlePrefix
+	copy(key[1:], pair.BaseTokenID[:])
+	copy(key[1+consts.IDLen:], pair.QuoteTokenID[:])
+	binary.BigEndian.PutUint64(key[1+2*consts.IDLen:], uint64(interval))
+	return key
+}
+
+func CandleKey(pair orderbook.Pair, interval int64, start int64) []byte {
+	key := candlesPrefix(pair, interval)
+	return binary.BigEndian.AppendUint64(key, uint64(start))
+}
+
+func FeesKey(pair orderbook.Pair) []byte {
+	key := make([]byte, 1+2*consts.IDLen)
+	key[0] = feesPrefix
+	copy(key[1:], pair.BaseTokenID[:])
+	copy(key[1+consts.IDLen:], pair.QuoteTokenID[:])
+	return key
+}
+
+func packCheckpoint(obm *orderbook.OrderbookManager, blockHeight uint64) ([]byte, error) {
+	p := codec.NewWriter(math.MaxInt)
+	p.PackUint64(blockHeight)
+	obm.Marshal(p)
+	return p.Bytes(), p.Err()
+}
+
+func unpackCheckpoint(fees *orderbook.FeeSchedule, pendingWindow uint64, v []byte, err error) (*orderbook.OrderbookManager, uint64, error) {
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	p := codec.NewReader(v, math.MaxInt)
+	blockHeight := p.UnpackUint64(false)
+	obm, err := orderbook.UnmarshalOrderbookManager(p, fees, pendingWindow)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !p.Empty() {
+		return nil, 0, ErrInvalidCheckpoint
+	}
+	return obm, blockHeight, nil
+}
+
+// StoreCheckpoint persists [obm] as it stands after accepting [blockHeight].
+func StoreCheckpoint(db database.KeyValueWriter, obm *orderbook.OrderbookManager, blockHeight uint64) error {
+	v, err := packCheckpoint(obm, blockHeight)
+	if err != nil {
+		return err
+	}
+	return db.Put(CheckpointKey(), v)
+}
+
+// GetCheckpoint returns the last persisted orderbook manager, charging [fees]
+// and holding funds pending for [pendingWindow] blocks, and the height it was
+// taken at. A nil manager is returned if no checkpoint exists.
+func GetCheckpoint(db database.KeyValueReader, fees *orderbook.FeeSchedule, pendingWindow uint64) (*orderbook.OrderbookManager, uint64, error) {
+	v, err := db.Get(CheckpointKey())
+	return unpackCheckpoint(fees, pendingWindow, v, err)
+}
+
+// StoreSnapshot keeps a copy of [obm] at [blockHeight] that is never
+// overwritten, so replays can start from that height.
+func StoreSnapshot(db database.KeyValueWriter, obm *orderbook.OrderbookManager, blockHeight uint64) error {
+	v, err := packCheckpoint(obm, blockHeight)
+	if err != nil {
+		return err
+	}
+	return db.Put(SnapshotKey(blockHeight), v)
+}
+
+func GetSnapshot(db database.KeyValueReader, blockHeight uint64, fees *orderbook.FeeSchedule, pendingWindow uint64) (*orderbook.OrderbookManager, error) {
+	v, err := db.Get(SnapshotKey(blockHeight))
+	obm, _, err := unpackCheckpoint(fees, pendingWindow, v, err)
+	return obm, err
+}
+
+// StoreResults records the tx results of an accepted block. Results are not
+// part of the block bytes, so they are needed to replay which txs succeeded.
+func StoreResults(db database.KeyValueWriter, blockHeight uint64, results []*chain.Result) error {
+	v, err := chain.MarshalResults(results)
+	if err != nil {
+		return err
+	}
+	return db.Put(ResultsKey(blockHeight), v)
+}
+
+func GetResults(db database.KeyValueReader, blockHeight uint64) ([]*chain.Result, error) {
+	v, err := db.Get(ResultsKey(blockHeight))
+	if err != nil {
+		return nil, err
+	}
+	return chain.UnmarshalResults(v)
+}
+
+// StoreRoot records the orderbook root computed after accepting [blockHeight].
+func StoreRoot(db database.KeyValueWriter, blockHeight uint64, root ids.ID) error {
+	return db.Put(RootKey(blockHeight), root[:])
+}
+
+func GetRoot(db database.KeyValueReader, blockHeight uint64) (ids.ID, error) {
+	v, err := db.Get(RootKey(blockHeight))
+	if err != nil {
+		return ids.Empty, err
+	}
+	return ids.ToID(v)
+}
+
+// StoreClosedOrder records the final state of an order that has left the book,
+// so its status can be looked up after it is no longer in memory.
+func StoreClosedOrder(db database.KeyValueWriter, order *orderbook.Order) error {
+	p := codec.NewWriter(math.MaxInt)
+	order.Marshal(p)
+	if err := p.Err(); err != nil {
+		return err
+	}
+	return db.Put(ClosedOrderKey(order.ID), p.Bytes())
+}
+
+func GetClosedOrder(db database.KeyValueReader, orderID ids.ID) (*orderbook.Order, error) {
+	v, err := db.Get(ClosedOrderKey(orderID))
+	if err != nil {
+		return nil, err
+	}
+	p := codec.NewReader(v, math.MaxInt)
+	order := orderbook.UnmarshalOrder(p)
+	return order, p.Err()
+}
+
+// StoreTrades records [trades] and indexes each by its pair and by the users
+// on both sides of it.
+func StoreTrades(db database.KeyValueWriter, trades []*orderbook.Trade) error {
+	for _, trade := range trades {
+		p := codec.NewWriter(math.MaxInt)
+		trade.Marshal(p)
+		if err := p.Err(); err != nil {
+			return err
+		}
+		pos := tradePosition(trade.BlockHeight, trade.Index)
+		if err := db.Put(TradeKey(trade.BlockHeight, trade.Index), p.Bytes()); err != nil {
+			return err
+		}
+		if err := db.Put(append(pairTradesPrefix(trade.Pair), pos...), nil); err != nil {
+			return err
+		}
+		if err := db.Put(append(userTradesPrefix(trade.Maker), pos...), nil); err != nil {
+			return err
+		}
+		if trade.Taker != trade.Maker {
+			if err := db.Put(append(userTradesPrefix(trade.Taker), pos...), nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func GetTrade(db database.KeyValueReader, blockHeight uint64, index uint32) (*orderbook.Trade, error) {
+	v, err := db.Get(TradeKey(blockHeight, index))
+	if err != nil {
+		return nil, err
+	}
+	p := codec.NewReader(v, math.MaxInt)
+	trade := orderbook.UnmarshalTrade(p)
+	return trade, p.Err()
+}
+
+// GetTrades returns up to [limit] trades of [pair] from the trade at
+// [blockHeight] and [index] onwards, oldest first. If [user] is not empty only
+// trades [user] was a side of are returned.
+func GetTrades(
+	db database.Database,
+	pair orderbook.Pair,
+	user crypto.PublicKey,
+	blockHeight uint64,
+	index uint32,
+	limit int,
+) ([]*orderbook.Trade, error) {
+	prefix := pairTradesPrefix(pair)
+	if user != crypto.EmptyPublicKey {
+		prefix = userTradesPrefix(user)
+	}
+	it := db.NewIteratorWithStartAndPrefix(append(prefix, tradePosition(blockHeight, index)...), prefix)
+	defer it.Release()
+
+	var trades []*orderbook.Trade
+	for len(trades) < limit && it.Next() {
+		pos := it.Key()[len(prefix):]
+		trade, err := GetTrade(db, binary.BigEndian.Uint64(pos), binary.BigEndian.Uint32(pos[consts.Uint64Len:]))
+		if err != nil {
+			return nil, err
+		}
+		if trade.Pair == pair {
+			trades = append(trades, trade)
+		}
+	}
+	return trades, it.Error()
+}
+
+func getCandle(db database.KeyValueReader, key []byte) (*orderbook.Candle, error) {
+	v, err := db.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	p := codec.NewReader(v, math.MaxInt)
+	candle := orderbook.UnmarshalCandle(p)
+	return candle, p.Err()
+}
+
+// StoreCandles folds [trades], in the order they were made, into the candles
+// of every interval read from [db] and writes the updated candles to [batch].
+func StoreCandles(db database.KeyValueReader, batch database.KeyValueWriter, trades []*orderbook.Trade) error {
+	candles := make(map[string]*orderbook.Candle)
+	var keys []string
+	for _, trade := range trades {
+		for _, interval := range orderbook.CandleIntervals {
+			key := string(CandleKey(trade.Pair, interval, orderbook.CandleStart(trade.Timestamp, interval)))
+			candle, ok := candles[key]
+			if !ok {
+				stored, err := getCandle(db, []byte(key))
+				if err != nil && !errors.Is(err, database.ErrNotFound) {
+					return err
+				}
+				candle = stored
+				keys = append(keys, key)
+			}
+			if candle == nil {
+				candle = orderbook.NewCandle(trade, interval)
+			} else {
+				candle.Add(trade)
+			}
+			candles[key] = candle
+		}
+	}
+	for _, key := range keys {
+		p := codec.NewWriter(math.MaxInt)
+		candles[key].Marshal(p)
+		if err := p.Err(); err != nil {
+			return err
+		}
+		if err := batch.Put([]byte(key), p.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetCandles returns up to [limit] [interval] candles of [pair] from the one
+// covering [start] onwards, oldest first. Intervals without trades have no
+// candle.
+func GetCandles(db database.Database, pair orderbook.Pair, interval int64, start int64, limit int) ([]*orderbook.Candle, error) {
+	prefix := candlesPrefix(pair, interval)
+	it := db.NewIteratorWithStartAndPrefix(CandleKey(pair, interval, orderbook.CandleStart(start, interval)), prefix)
+	defer it.Release()
+
+	var candles []*orderbook.Candle
+	for len(candles) < limit && it.Next() {
+		p := codec.NewReader(it.Value(), math.MaxInt)
+		candle := orderbook.UnmarshalCandle(p)
+		if err := p.Err(); err != nil {
+			return nil, err
+		}
+		candles = append(candles, candle)
+	}
+	return candles, it.Error()
+}
+
+// GetFees returns the fees collected on [pair] since genesis.
+func GetFees(db database.KeyValueReader, pair orderbook.Pair) (*orderbook.PairFees, error) {
+	v, err := db.Get(FeesKey(pair))
+	if errors.Is(err, database.ErrNotFound) {
+		return &orderbook.PairFees{Pair: pair}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p := codec.NewReader(v, math.MaxInt)
+	pf := orderbook.UnmarshalPairFees(p, pair)
+	return pf, p.Err()
+}
+
+// StoreFees adds the fees collected in a block to the totals read from [db]
+// and writes the new totals to [batch].
+func StoreFees(db database.KeyValueReader, batch database.KeyValueWriter, fees []*orderbook.PairFees) error {
+	for _, collected := range fees {
+		pf, err := GetFees(db, collected.Pair)
+		if err != nil {
+			return err
+		}
+		pf.Add(collected.Pair.BaseTokenID, collected.Base)
+		pf.Add(collected.Pair.QuoteTokenID, collected.Quote)
+		p := codec.NewWriter(math.MaxInt)
+		pf.Marshal(p)
+		if err := p.Err(); err != nil {
+			return err
+		}
+		if err := batch.Put(FeesKey(collected.Pair), p.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/ava-labs/avalanchego/utils/math"
 	"github.com/jaimi-io/clobvm/orderbook"
 	"github.com/jaimi-io/hypersdk/chain"
+	"github.com/jaimi-io/hypersdk/codec"
 	"github.com/jaimi-io/hypersdk/consts"
 	"github.com/jaimi-io/hypersdk/crypto"
 )
@@ -20,6 +21,9 @@ type ReadState func(context.Context, [][]byte) ([][]byte, []error)
 var (
 	balancePrefix = byte(0x1)
 	orderPrefix   = byte(0x2)
+	pairPrefix    = byte(0x3)
+	tokenPrefix   = byte(0x4)
+	settledPrefix = byte(0x5)
 )
 
 func BalanceKey(pk crypto.PublicKey, tokenID ids.ID) []byte {
@@ -88,11 +92,137 @@ func DecBalance(ctx context.Context, db chain.Database, pk crypto.PublicKey, tok
 	return newBal, err
 }
 
+// SettledKey holds the running total of [pk]'s pending [tokenID] that has
+// been pulled into their balance.
+func SettledKey(pk crypto.PublicKey, tokenID ids.ID) []byte {
+	key := make([]byte, 1+crypto.PublicKeyLen+consts.IDLen)
+	key[0] = settledPrefix
+	copy(key[1:1+crypto.PublicKeyLen], pk[:])
+	copy(key[1+crypto.PublicKeyLen:1+crypto.PublicKeyLen+consts.IDLen], tokenID[:])
+	return key
+}
+
+func GetSettledFromState(ctx context.Context, f ReadState, pk crypto.PublicKey, tokenID ids.ID) (uint64, error) {
+	values, errs := f(ctx, [][]byte{SettledKey(pk, tokenID)})
+	return innerGetBalance(values[0], errs[0])
+}
+
+// PullPendingBalance credits [pk] with their pending [tokenID] that can be
+// pulled at [blockHeight] and has not been yet. It returns the new balance,
+// or 0 if there was nothing to pull. Actions calling it must list both the
+// BalanceKey and SettledKey of [pk] and [tokenID].
 func PullPendingBalance(ctx context.Context, db chain.Database, obm *orderbook.OrderbookManager, pk crypto.PublicKey, tokenID ids.ID, blockHeight uint64) (uint64, error) {
-	amount := obm.PullPendingFunds(pk, tokenID, blockHeight)
-	if amount > 0 {
-		bal, err := IncBalance(ctx, db, pk, tokenID, amount)
-		return bal, err
+	key := SettledKey(pk, tokenID)
+	settled, err := innerGetBalance(db.GetValue(ctx, key))
+	if err != nil {
+		return 0, err
+	}
+	matured := obm.MaturedFunds(pk, tokenID, blockHeight)
+	amount := orderbook.Unsettled(matured, settled)
+	if amount == 0 {
+		return 0, nil
+	}
+	if err := db.Insert(ctx, key, binary.BigEndian.AppendUint64(nil, matured)); err != nil {
+		return 0, err
+	}
+	return IncBalance(ctx, db, pk, tokenID, amount)
+}
+
+func PairKey(pair orderbook.Pair) []byte {
+	key := make([]byte, 1+2*consts.IDLen)
+	key[0] = pairPrefix
+	copy(key[1:], pair.BaseTokenID[:])
+	copy(key[1+consts.IDLen:], pair.QuoteTokenID[:])
+	return key
+}
+
+func SetPair(ctx context.Context, db chain.Database, pair orderbook.Pair, info *orderbook.PairInfo) error {
+	p := codec.NewWriter(consts.MaxInt)
+	info.Marshal(p)
+	if err := p.Err(); err != nil {
+		return err
+	}
+	return db.Insert(ctx, PairKey(pair), p.Bytes())
+}
+
+// innerGetPair returns a nil listing if the pair is not in the registry.
+func innerGetPair(v []byte, err error) (*orderbook.PairInfo, error) {
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil
 	}
-	return 0, nil
+	if err != nil {
+		return nil, err
+	}
+	p := codec.NewReader(v, consts.MaxInt)
+	info := orderbook.UnmarshalPairInfo(p)
+	return info, p.Err()
+}
+
+func GetPair(ctx context.Context, db chain.Database, pair orderbook.Pair) (*orderbook.PairInfo, error) {
+	return innerGetPair(db.GetValue(ctx, PairKey(pair)))
+}
+
+func GetPairFromState(ctx context.Context, f ReadState, pair orderbook.Pair) (*orderbook.PairInfo, error) {
+	values, errs := f(ctx, [][]byte{PairKey(pair)})
+	return innerGetPair(values[0], errs[0])
+}
+
+// TokenInfo is the metadata of a token created with CreateToken. Balances of
+// every token are kept with consts.BalanceDecimals decimals; Decimals is how
+// many of them the token is meant to be displayed with. A MaxSupply of 0
+// means the supply is uncapped. Genesis tokens may have no Owner, in which
+// case no more can be minted.
+type TokenInfo struct {
+	Symbol    string
+	Decimals  uint8
+	MaxSupply uint64
+	Supply    uint64
+	Owner     crypto.PublicKey
+}
+
+func TokenKey(tokenID ids.ID) []byte {
+	key := make([]byte, 1+consts.IDLen)
+	key[0] = tokenPrefix
+	copy(key[1:], tokenID[:])
+	return key
+}
+
+func SetToken(ctx context.Context, db chain.Database, tokenID ids.ID, info *TokenInfo) error {
+	p := codec.NewWriter(consts.MaxInt)
+	p.PackString(info.Symbol)
+	p.PackByte(info.Decimals)
+	p.PackUint64(info.MaxSupply)
+	p.PackUint64(info.Supply)
+	p.PackPublicKey(info.Owner)
+	if err := p.Err(); err != nil {
+		return err
+	}
+	return db.Insert(ctx, TokenKey(tokenID), p.Bytes())
+}
+
+// innerGetToken returns nil metadata if the token does not exist.
+func innerGetToken(v []byte, err error) (*TokenInfo, error) {
+	if errors.Is(err, database.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p := codec.NewReader(v, consts.MaxInt)
+	var info TokenInfo
+	info.Symbol = p.UnpackString(true)
+	info.Decimals = p.UnpackByte()
+	info.MaxSupply = p.UnpackUint64(false)
+	info.Supply = p.UnpackUint64(false)
+	p.UnpackPublicKey(false, &info.Owner)
+	return &info, p.Err()
+}
+
+func GetToken(ctx context.Context, db chain.Database, tokenID ids.ID) (*TokenInfo, error) {
+	return innerGetToken(db.GetValue(ctx, TokenKey(tokenID)))
+}
+
+func GetTokenFromState(ctx context.Context, f ReadState, tokenID ids.ID) (*TokenInfo, error) {
+	values, errs := f(ctx, [][]byte{TokenKey(tokenID)})
+	return innerGetToken(values[0], errs[0])
 }